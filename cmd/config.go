@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cf-furnace/k8s-stager/lib/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Subcommands for the stager configuration.",
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the configuration the run command would use and prints it with secrets masked.",
+	Run: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlags(cmd.Flags())
+
+		// Only the stager settings, not the flags every command has
+		serverFlags := pflag.NewFlagSet("server", pflag.ContinueOnError)
+		addServerFlags(serverFlags)
+
+		for _, setting := range config.Effective(viper.GetViper(), serverFlags) {
+			fmt.Printf("%s: %s\n", setting.Key, setting.Value)
+		}
+		fmt.Println()
+
+		if _, err := config.Load(viper.GetViper()); err != nil {
+			fmt.Println("Invalid configuration:")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Configuration is valid.")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	addServerFlags(configValidateCmd.Flags())
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cf-furnace/k8s-stager/lib/config"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/swagger"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Prints the Kubernetes objects a staging would create, without talking to Kubernetes.",
+	Long: `Renders the staging namespace, secret and job the stager would create for a
+staging request with the configuration run would use, and prints them as a
+Kubernetes List. Nothing is created, and neither Kubernetes nor a stager is
+contacted. The staging request is read from a StagingRequestFromCC JSON file,
+or built from flags like the stage command does. Docker stagings don't create
+any objects. The private key in the secret is redacted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		viper.BindPFlags(flags)
+
+		output, _ := flags.GetString("output")
+		if output != k8s.RenderYAML && output != k8s.RenderJSON {
+			fmt.Printf("Unknown output format %q, expected %s or %s\n", output, k8s.RenderYAML, k8s.RenderJSON)
+			os.Exit(1)
+		}
+
+		serverConfig, err := config.Load(viper.GetViper())
+		if err != nil {
+			fmt.Println("Invalid configuration:")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		request, err := stagingRequest(cmd)
+		if err != nil {
+			fmt.Println("Invalid staging request:", err)
+			os.Exit(1)
+		}
+
+		stagingGuid, _ := flags.GetString("staging-guid")
+		if stagingGuid == "" {
+			stagingGuid = request.AppID + "-" + newGuid()
+		}
+
+		objects, err := swagger.RenderStaging(serverConfig, stagingGuid, request, "")
+		if err != nil {
+			fmt.Println("Error rendering staging:", err)
+			os.Exit(1)
+		}
+
+		rendered, err := k8s.RenderStagingObjects(objects, output)
+		if err != nil {
+			fmt.Println("Error encoding staging:", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(strings.TrimSuffix(string(rendered), "\n"))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(renderCmd)
+
+	flags := renderCmd.Flags()
+	addServerFlags(flags)
+	addStagingRequestFlags(flags)
+	flags.StringP("output", "o", k8s.RenderYAML, "Output format, yaml or json.")
+}
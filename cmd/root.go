@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/cf-furnace/k8s-stager/lib/config"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -31,21 +33,27 @@ func Execute(version string) {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.k8s-stager.yaml)")
+	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $STAGER_CONFIG or $HOME/.k8s-stager.yaml)")
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	if cfgFile == "" {
+		cfgFile = os.Getenv(config.EnvPrefix + "_CONFIG")
+	}
+
+	// Setting a config name discards the config file, so only one of them is
+	// set
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
+	} else {
+		viper.SetConfigName(".k8s-stager") // name of config file (without extension)
+		viper.AddConfigPath("$HOME")       // adding home directory as first search path
 	}
-
-	viper.SetConfigName(".k8s-stager") // name of config file (without extension)
-	viper.AddConfigPath("$HOME")       // adding home directory as first search path
-	viper.AutomaticEnv()               // read in environment variables that match
+	config.BindEnv(viper.GetViper()) // read in STAGER_ environment variables that match
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -1,15 +1,29 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/audit"
+	"github.com/cf-furnace/k8s-stager/lib/auth"
+	"github.com/cf-furnace/k8s-stager/lib/config"
+	"github.com/cf-furnace/k8s-stager/lib/discovery"
+	"github.com/cf-furnace/k8s-stager/lib/health"
 	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/local"
 	"github.com/cf-furnace/k8s-stager/lib/logger"
+	"github.com/cf-furnace/k8s-stager/lib/loggregator"
+	"github.com/cf-furnace/k8s-stager/lib/metrics"
 	"github.com/cf-furnace/k8s-stager/lib/swagger"
 	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+	"github.com/cf-furnace/k8s-stager/lib/tlsconfig"
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/consuladapter"
@@ -17,7 +31,9 @@ import (
 	"github.com/cloudfoundry-incubator/locket"
 	"github.com/go-openapi/loads"
 	"github.com/hashicorp/consul/api"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
@@ -25,58 +41,124 @@ import (
 	"github.com/tedsuo/ifrit/sigmon"
 )
 
+const (
+	// readinessCheckTimeout bounds how long /readyz waits for its checks
+	readinessCheckTimeout = 2 * time.Second
+
+	// tlsReloadInterval is how often the TLS files are checked for changes
+	tlsReloadInterval = 10 * time.Second
+
+	// endpointsRefreshInterval is how often the stager's address and
+	// readiness are updated in the Endpoints of the kubernetes discovery
+	endpointsRefreshInterval = 10 * time.Second
+)
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Runs the Kubernetes Cloud Foundry Stager",
 	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-		serverConfig := &lib.ServerConfig{}
-
-		// Load configuration
-		serverConfig.LogLevel = viper.GetString("log-level")
-		serverConfig.Listen = viper.GetString("listen")
-		serverConfig.Port = viper.GetInt("port")
-		serverConfig.AdvertiseAddress = viper.GetString("advertise-address")
-		serverConfig.StagerId = viper.GetString("id")
-		serverConfig.StagingImage = viper.GetString("staging-image")
-		serverConfig.K8SAPIEndpoint = viper.GetString("k8s-endpoint")
-		serverConfig.K8SNamespace = viper.GetString("k8s-namespace")
-		serverConfig.StagingStopGracePeriodSeconds = int64(viper.GetDuration("k8s-endpoint").Seconds())
-		serverConfig.SkipCertVerification = viper.GetBool("skip-cert-verify")
-		serverConfig.AppLifecycleURL = viper.GetString("app-lifecycle-url")
-		serverConfig.CustomImageCommand = viper.GetString("custom-image-command")
-		serverConfig.KBSClientCertFile = viper.GetString("k8s-client-cert")
-		serverConfig.K8SClientKeyFile = viper.GetString("k8s-client-key")
-		serverConfig.K8SCACertFile = viper.GetString("k8s-cacert")
-		serverConfig.CCBaseURL = viper.GetString("cc-baseurl")
-		serverConfig.CCUsername = viper.GetString("cc-username")
-		serverConfig.CCPassword = viper.GetString("cc-password")
-		serverConfig.ConsulCluster = viper.GetString("consul-cluster")
+		viper.BindPFlags(cmd.Flags())
+
+		serverConfig, err := config.Load(viper.GetViper())
+		if err != nil {
+			fmt.Println("Invalid configuration:")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		serverConfig.Authenticators = initializeAuthenticators(serverConfig)
 
 		// Create a logger
-		serverConfig.Logger = logger.NewLogger(serverConfig.LogLevel)
-
-		// Connect to Kubernetes
-		serverConfig.K8SClient, err = k8s.NewStager(
-			serverConfig.K8SAPIEndpoint,
-			serverConfig.StagerId,
-			serverConfig.KBSClientCertFile,
-			serverConfig.K8SClientKeyFile,
-			serverConfig.K8SCACertFile,
-			serverConfig.Logger,
-		)
+		redactor, err := logger.NewRedactor(serverConfig.LogRedactEnvPatterns)
+		if err != nil {
+			fmt.Println("Invalid log-redact-env-patterns:", err)
+			os.Exit(1)
+		}
 
+		var logLevelSink *lager.ReconfigurableSink
+		serverConfig.Logger, logLevelSink, err = logger.NewLogger(logger.Config{
+			Level:          serverConfig.LogLevel,
+			Sinks:          serverConfig.LogSinks,
+			File:           serverConfig.LogFile,
+			FileMaxSizeMB:  serverConfig.LogFileMaxSizeMB,
+			FileMaxBackups: serverConfig.LogFileMaxBackups,
+			SyslogAddress:  serverConfig.SyslogAddress,
+			SyslogTag:      serverConfig.SyslogTag,
+			Redactor:       redactor,
+		})
 		if err != nil {
-			serverConfig.Logger.Fatal(
-				"Could not connect to Kubernetes",
-				err,
-				lager.Data{
-					"K8SEndpoint": serverConfig.K8SAPIEndpoint,
-				},
+			fmt.Println("Invalid logging configuration:", err)
+			os.Exit(1)
+		}
+
+		// Open the audit log
+		if serverConfig.AuditLogFile != "" {
+			serverConfig.AuditLog, err = audit.NewFileLogger(serverConfig.AuditLogFile, serverConfig.AuditLogHashChain)
+			if err != nil {
+				serverConfig.Logger.Fatal(
+					"opening-audit-log-failed",
+					err,
+					lager.Data{
+						"AuditLogFile": serverConfig.AuditLogFile,
+					},
+				)
+			}
+		}
+
+		// Stagings run as Kubernetes jobs, or as local processes during
+		// development
+		var stager *k8s.Stager
+		var localStager *local.Stager
+		var stagingClient k8s.K8SStagingClient
+		var stagingCollectors []prometheus.Collector
+		dockerStagings := swagger.NewDockerStagings()
+
+		switch serverConfig.StagingBackend {
+		case lib.StagingBackendLocal:
+			localStager, err = local.NewStager(
+				serverConfig.LocalStagingDir,
+				strings.Fields(serverConfig.LocalStagingCommand),
+				serverConfig.StagerId,
+				serverConfig.Logger,
 			)
+
+			if err != nil {
+				serverConfig.Logger.Fatal(
+					"Could not create the local staging directory",
+					err,
+					lager.Data{
+						"LocalStagingDir": serverConfig.LocalStagingDir,
+					},
+				)
+			}
+
+			stagingClient = metrics.InstrumentK8SClient(localStager)
+		default:
+			stager, err = k8s.NewStager(
+				serverConfig.K8SConnectionConfig(),
+				serverConfig.StagerId,
+				serverConfig.Logger,
+			)
+
+			if err != nil {
+				serverConfig.Logger.Fatal(
+					"Could not connect to Kubernetes",
+					err,
+					lager.Data{
+						"K8SConnection": serverConfig.K8SConnection,
+						"K8SEndpoint":   serverConfig.K8SAPIEndpoint,
+						"Kubeconfig":    serverConfig.Kubeconfig,
+					},
+				)
+			}
+
+			stagingClient = metrics.InstrumentK8SClient(stager)
+			stagingCollectors = append(stagingCollectors, metrics.NewStagingCollector(stager.Cache(), dockerStagings.InFlight))
 		}
 
+		metrics.Register(stagingCollectors...)
+
 		// Load swagger spec
 		swaggerSpec, err := loads.Analyzed(swagger.SwaggerJSON, "")
 		if err != nil {
@@ -93,21 +175,131 @@ var runCmd = &cobra.Command{
 
 		api := operations.NewK8sSwaggerAPI(swaggerSpec)
 
-		stagerServer := swagger.ConfigureAPI(api, serverConfig)
+		// Metrics and health are served next to the API, outside of its base path
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/healthz", health.LivenessHandler())
+		mux.Handle("/readyz", health.ReadinessHandler(readinessChecks(serverConfig, stager), readinessCheckTimeout))
+
+		stagerServer := swagger.ConfigureAPI(api, serverConfig, swagger.Dependencies{
+			K8SClient:      stagingClient,
+			DockerStagings: dockerStagings,
+		})
+		if serverConfig.TLSClientCAFile != "" {
+			stagerServer = tlsconfig.RequireClientCertificate(stagerServer)
+		}
+		mux.Handle("/", stagerServer)
+
+		listenAddress := fmt.Sprintf("%s:%d", serverConfig.Listen, serverConfig.Port)
+		server := http_server.New(listenAddress, mux)
+
+		var tlsReloader *tlsconfig.Reloader
+		if serverConfig.TLSCertFile != "" {
+			tlsReloader, err = tlsconfig.NewReloader(
+				serverConfig.TLSCertFile,
+				serverConfig.TLSKeyFile,
+				serverConfig.TLSClientCAFile,
+				tlsReloadInterval,
+				serverConfig.Logger,
+			)
+			if err != nil {
+				serverConfig.Logger.Fatal("loading-tls-files-failed", err)
+			}
 
-		consulClient, err := consuladapter.NewClientFromUrl(serverConfig.ConsulCluster)
-		if err != nil {
-			serverConfig.Logger.Fatal("new-consul-client-failed", err)
+			server = http_server.NewTLSServer(listenAddress, mux, tlsReloader.TLSConfig())
+		}
+
+		var consulClient consuladapter.Client
+		if serverConfig.Discovery == discovery.BackendConsul || serverConfig.LeaderElection {
+			consulClient, err = consuladapter.NewClientFromUrl(serverConfig.ConsulCluster)
+			if err != nil {
+				serverConfig.Logger.Fatal("new-consul-client-failed", err)
+			}
 		}
 
 		clock := clock.NewClock()
-		registrationRunner := initializeRegistrationRunner(serverConfig.Logger, consulClient, serverConfig.Port, clock)
 
-		members := grouper.Members{
-			{"server", http_server.New(fmt.Sprintf("%s:%d", serverConfig.Listen, serverConfig.Port), stagerServer)},
-			{"registration-runner", registrationRunner},
+		// Local staging processes are stopped after the server
+		members := grouper.Members{}
+		if localStager != nil {
+			members = append(members, grouper.Member{"local-stagings", localStager})
+		} else {
+			members = append(members, grouper.Member{"k8s-cache", stager.Cache()})
+		}
+		members = append(members, grouper.Member{"server", server})
+
+		switch serverConfig.Discovery {
+		case discovery.BackendConsul:
+			members = append(members, grouper.Member{
+				"registration-runner",
+				initializeRegistrationRunner(serverConfig, consulClient, clock),
+			})
+		case discovery.BackendKubernetes:
+			members = append(members, grouper.Member{
+				"endpoints-registration",
+				initializeEndpointsRegistration(serverConfig, stager),
+			})
+		}
+
+		if tlsReloader != nil {
+			members = append(members, grouper.Member{"tls-reloader", tlsReloader})
+		}
+
+		if viper.ConfigFileUsed() != "" && serverConfig.ConfigReloadInterval > 0 {
+			reloadFlags := pflag.NewFlagSet("reload", pflag.ContinueOnError)
+			addServerFlags(reloadFlags)
+
+			configReloader := config.NewReloader(
+				viper.GetViper(),
+				reloadFlags,
+				serverConfig,
+				logLevelSink,
+				serverConfig.ConfigReloadInterval,
+				serverConfig.Logger,
+			)
+			members = append(members, grouper.Member{"config-reloader", configReloader})
+		}
+
+		if serverConfig.DebugAddress != "" {
+			debugMux := http.NewServeMux()
+			debugMux.Handle("/log-level", logger.NewLevelHandler(logLevelSink, serverConfig.Logger))
+
+			members = append(members, grouper.Member{"debug-server", http_server.New(serverConfig.DebugAddress, debugMux)})
 		}
 
+		// Background loops must not run on more than one stager at a time.
+		// When leader election is enabled they only start once this instance
+		// holds the lock, while the HTTP server keeps serving on all instances.
+		backgroundMembers := grouper.Members{}
+
+		if stager != nil {
+			backgroundMembers = append(backgroundMembers,
+				grouper.Member{"staging-start-observer", metrics.NewStagingStartObserver(stager.Cache())},
+				grouper.Member{"staging-event-recorder", k8s.NewStagingEventRecorder(stager, serverConfig.Logger)},
+			)
+		}
+
+		if serverConfig.MetronAddress != "" && stager == nil {
+			serverConfig.Logger.Info("Staging logs are not forwarded to Metron by the local staging backend.")
+		} else if serverConfig.MetronAddress != "" {
+			logEmitter, err := loggregator.NewUdpEmitter(serverConfig.MetronAddress)
+			if err != nil {
+				serverConfig.Logger.Fatal("new-log-emitter-failed", err, lager.Data{"MetronAddress": serverConfig.MetronAddress})
+			}
+
+			backgroundMembers = append(backgroundMembers, grouper.Member{
+				"staging-log-forwarder",
+				loggregator.NewStagingLogForwarder(stager, logEmitter, serverConfig.Logger),
+			})
+		}
+
+		if serverConfig.LeaderElection {
+			lockRunner := initializeLockRunner(serverConfig, consulClient, clock)
+			members = append(members, grouper.Member{"leader-lock", lockRunner})
+		}
+
+		members = append(members, backgroundMembers...)
+
 		group := grouper.NewOrdered(os.Interrupt, members)
 
 		monitor := ifrit.Invoke(sigmon.New(group))
@@ -124,156 +316,621 @@ var runCmd = &cobra.Command{
 	},
 }
 
+// The stager is only reported healthy to Consul while it is ready to stage.
+// It is deregistered when it stops.
 func initializeRegistrationRunner(
-	logger lager.Logger,
+	serverConfig *lib.ServerConfig,
 	consulClient consuladapter.Client,
-	port int,
 	clock clock.Clock) ifrit.Runner {
+	// The Consul agent has to trust the stager's certificate when TLS is on
+	scheme := "http"
+	if serverConfig.TLSCertFile != "" {
+		scheme = "https"
+	}
+
+	// Unique per stager, several of them can share a Consul agent
+	serviceID := fmt.Sprintf("%s-%s", serverConfig.DiscoveryServiceName, serverConfig.StagerId)
+
 	registration := &api.AgentServiceRegistration{
-		Name: "stager",
-		Port: port,
+		ID:      serviceID,
+		Name:    serverConfig.DiscoveryServiceName,
+		Tags:    serverConfig.DiscoveryServiceTags,
+		Address: serverConfig.AdvertiseAddress,
+		Port:    serverConfig.Port,
 		Check: &api.AgentServiceCheck{
-			TTL: "3s",
+			HTTP:     fmt.Sprintf("%s://%s:%d/readyz", scheme, serverConfig.AdvertiseAddress, serverConfig.Port),
+			Interval: "3s",
+			Timeout:  readinessCheckTimeout.String(),
 		},
 	}
-	return locket.NewRegistrationRunner(logger, registration, consulClient, locket.RetryInterval, clock)
+	registrationRunner := locket.NewRegistrationRunner(serverConfig.Logger, registration, consulClient, locket.RetryInterval, clock)
+
+	return discovery.NewConsulDeregistration(registrationRunner, consulClient, serviceID, serverConfig.Logger)
+}
+
+// The stager's address is listed as ready in the Endpoints while /readyz
+// passes.
+func initializeEndpointsRegistration(serverConfig *lib.ServerConfig, stager *k8s.Stager) ifrit.Runner {
+	namespace := serverConfig.DiscoveryNamespace
+	if namespace == "" {
+		var err error
+		namespace, err = k8s.PodNamespace()
+		if err != nil {
+			serverConfig.Logger.Fatal("discovery-namespace-unknown", err)
+		}
+	}
+
+	checks := readinessChecks(serverConfig, stager)
+
+	return k8s.NewEndpointsRegistration(
+		stager,
+		namespace,
+		serverConfig.DiscoveryServiceName,
+		serverConfig.AdvertiseAddress,
+		serverConfig.Port,
+		func() error { return health.Ready(checks, readinessCheckTimeout) },
+		endpointsRefreshInterval,
+		serverConfig.Logger,
+	)
+}
+
+// Kubernetes is only checked if stagings run there, stager is nil with the
+// local staging backend.
+func readinessChecks(serverConfig *lib.ServerConfig, stager *k8s.Stager) []health.Check {
+	checks := []health.Check{}
+
+	if stager != nil {
+		checks = append(checks,
+			health.Check{Name: "k8s", Func: stager.Ping},
+			health.Check{Name: "k8s-cache", Func: func() error {
+				if !stager.Cache().HasSynced() {
+					return fmt.Errorf("staging cache has not synced yet")
+				}
+				return nil
+			}},
+		)
+	}
+
+	if serverConfig.ReadinessCheckCC {
+		checks = append(checks, health.CCReachable(serverConfig.CCBaseURL, serverConfig.SkipCertVerification, readinessCheckTimeout))
+	}
+
+	return checks
+}
+
+// Authentication is only enforced when at least one method is configured
+func initializeAuthenticators(serverConfig *lib.ServerConfig) []auth.Authenticator {
+	authenticators := []auth.Authenticator{}
+
+	if subject := serverConfig.AuthCCCertSubject; subject != "" {
+		authenticators = append(authenticators, auth.NewCertificateAuthenticator(map[string]auth.Role{subject: auth.RoleCC}))
+	}
+
+	if jwksURL := serverConfig.AuthJWKSURL; jwksURL != "" {
+		scopeRoles := map[string]auth.Role{}
+		if scope := serverConfig.AuthJWTCCScope; scope != "" {
+			scopeRoles[scope] = auth.RoleCC
+		}
+
+		// The keys tokens are verified with are always fetched over a
+		// verified connection, whatever skip-cert-verify says about CC
+		tlsConfig := &tls.Config{}
+		if caFile := serverConfig.AuthJWKSCAFile; caFile != "" {
+			rootCAs, err := tlsconfig.LoadCertPool(caFile)
+			if err != nil {
+				serverConfig.Logger.Fatal("loading-jwks-ca-failed", err, lager.Data{"AuthJWKSCAFile": caFile})
+			}
+			tlsConfig.RootCAs = rootCAs
+		}
+
+		httpClient := &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+
+		authenticators = append(authenticators, auth.NewJWTAuthenticator(
+			auth.NewRemoteKeySet(jwksURL, httpClient),
+			serverConfig.AuthJWTIssuer,
+			serverConfig.AuthJWTAudience,
+			scopeRoles,
+		))
+	}
+
+	if serverConfig.AuthBasic {
+		authenticators = append(authenticators, auth.NewRotatingBasicAuthenticator(serverConfig.CCCredentials))
+	}
+
+	// Last, so that an unreadable staging CA only affects staging pods
+	if caFile := serverConfig.StagingClientCACertFile; caFile != "" {
+		authenticators = append(authenticators, auth.NewStagingCertificateAuthenticator(func() (*x509.CertPool, error) {
+			return tlsconfig.LoadCertPool(caFile)
+		}))
+	}
+
+	return authenticators
+}
+
+func initializeLockRunner(
+	serverConfig *lib.ServerConfig,
+	consulClient consuladapter.Client,
+	clock clock.Clock) ifrit.Runner {
+	lockValue, err := json.Marshal(map[string]interface{}{
+		"id":      serverConfig.StagerId,
+		"address": serverConfig.AdvertiseAddress,
+		"port":    serverConfig.Port,
+	})
+	if err != nil {
+		serverConfig.Logger.Fatal("marshal-leader-lock-value-failed", err)
+	}
+
+	return locket.NewLock(
+		serverConfig.Logger,
+		consulClient,
+		locket.LockSchemaPath("k8s_stager_lock"),
+		lockValue,
+		clock,
+		serverConfig.LeaderLockRetryInterval,
+		serverConfig.LeaderLockTTL,
+	)
 }
 
 func init() {
 	RootCmd.AddCommand(runCmd)
 
-	runCmd.PersistentFlags().StringP(
+	addServerFlags(runCmd.PersistentFlags())
+}
+
+// addServerFlags defines the settings of the stager. They are bound to viper
+// when a command runs, so that several commands can share them.
+func addServerFlags(flags *pflag.FlagSet) {
+	addKubernetesFlags(flags)
+
+	flags.StringP(
 		"listen",
 		"l",
 		"0.0.0.0",
 		"Address to listen on.",
 	)
 
-	runCmd.PersistentFlags().IntP(
+	flags.IntP(
 		"port",
 		"",
 		8080,
 		"Port to listen on.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
 		"advertise-address",
 		"",
 		"",
 		"Address of stager, as used by other components.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
 		"log-level",
 		"L",
 		"info",
-		"Logging level.",
+		"Logging level, one of debug, info, error or fatal.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"id",
-		"i",
-		"stager-0",
-		"Identifier of the stager.",
+	flags.StringSliceP(
+		"log-sinks",
+		"",
+		[]string{logger.StdoutSink},
+		"Where logs are written, any of stdout, file and syslog.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"staging-image",
-		"s",
-		"cffurnace/stager",
-		"Image to use for staging.",
+	flags.StringP(
+		"log-file",
+		"",
+		"",
+		"Path of the log file used by the file log sink.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"k8s-endpoint",
-		"k",
+	flags.IntP(
+		"log-file-max-size",
 		"",
-		"Kubernetes HTTP API endpoint.",
+		100,
+		"Size in megabytes at which the log file is rotated. Never rotated if 0.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"k8s-namespace",
-		"n",
-		"furnace-staging",
-		"Kubernetes namespace to use for staging.",
+	flags.IntP(
+		"log-file-max-backups",
+		"",
+		5,
+		"Number of rotated log files kept.",
+	)
+
+	flags.StringP(
+		"syslog-address",
+		"",
+		"",
+		"Syslog server used by the syslog log sink, e.g. udp://localhost:514. The local syslog daemon is used if empty.",
 	)
 
-	runCmd.PersistentFlags().DurationP(
+	flags.StringP(
+		"syslog-tag",
+		"",
+		"k8s-stager",
+		"Tag of the messages sent to syslog.",
+	)
+
+	flags.StringP(
+		"debug-address",
+		"",
+		"",
+		"Address of the debug server, e.g. 127.0.0.1:17017. It serves /log-level to read and change the log level at runtime and must not be reachable from outside. Disabled if empty.",
+	)
+
+	flags.StringP(
+		"staging-image",
+		"s",
+		"cffurnace/stager",
+		"Image to use for staging.",
+	)
+
+	flags.DurationP(
 		"stage-stop-grace",
 		"g",
 		time.Second*60,
 		"Grace period for staging stop.",
 	)
 
-	runCmd.PersistentFlags().BoolP(
+	flags.BoolP(
 		"skip-cert-verify",
 		"S",
 		false,
 		"Skip certificate validation when staging.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
 		"app-lifecycle-url",
 		"a",
 		"",
 		"Application lifecycle URL.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
+		"staging-backend",
+		"",
+		lib.StagingBackendKubernetes,
+		"Where stagings run: kubernetes runs them as jobs, local runs them as child processes of the stager, for development and tests.",
+	)
+
+	flags.StringP(
+		"local-staging-dir",
+		"",
+		"",
+		"Directory the sandboxes of local stagings are created in. A directory in the system's temporary directory if empty.",
+	)
+
+	flags.StringP(
+		"local-staging-command",
+		"",
+		"",
+		"Command local stagings run, with the environment of the staging container. custom-image-command takes precedence.",
+	)
+
+	flags.StringP(
 		"custom-image-command",
 		"c",
 		"",
 		"Custom entrypoint to use when running the staging command.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
+		"cc-baseurl",
+		"",
+		"",
+		"Cloud controller API location.",
+	)
+
+	flags.StringP(
+		"cc-username",
+		"",
+		"",
+		"Cloud Controller internal API username.",
+	)
+
+	flags.StringP(
+		"cc-password",
+		"",
+		"",
+		"Cloud Controller internal API password. Prefer cc-password-file or the STAGER_CC_PASSWORD environment variable, flags show up in the process list.",
+	)
+
+	flags.StringP(
+		"cc-username-file",
+		"",
+		"",
+		"File holding the Cloud Controller internal API username, read again when it changes.",
+	)
+
+	flags.StringP(
+		"cc-password-file",
+		"",
+		"",
+		"File holding the Cloud Controller internal API password, read again when it changes.",
+	)
+
+	flags.StringP(
+		"consul-cluster",
+		"",
+		"",
+		"Consul used by the consul discovery and leader election.",
+	)
+
+	flags.StringP(
+		"discovery",
+		"",
+		discovery.BackendConsul,
+		"How other components find the stager: consul registers a Consul service, kubernetes lists the stager in the Endpoints of a Kubernetes Service, none doesn't register it anywhere.",
+	)
+
+	flags.StringP(
+		"discovery-service-name",
+		"",
+		"k8s-stager",
+		"Name of the Consul service or Kubernetes Service the stager registers under.",
+	)
+
+	flags.StringSliceP(
+		"discovery-service-tags",
+		"",
+		[]string{},
+		"Tags of the Consul service.",
+	)
+
+	flags.StringP(
+		"discovery-namespace",
+		"",
+		"",
+		"Namespace of the Kubernetes Service. Defaults to the namespace of the stager's pod.",
+	)
+
+	flags.BoolP(
+		"leader-election",
+		"",
+		false,
+		"Only run background loops while holding a leader lock in Consul.",
+	)
+
+	flags.DurationP(
+		"leader-lock-ttl",
+		"",
+		locket.LockTTL,
+		"TTL of the Consul session backing the leader lock.",
+	)
+
+	flags.DurationP(
+		"leader-lock-retry-interval",
+		"",
+		locket.RetryInterval,
+		"Interval between attempts to acquire the leader lock.",
+	)
+
+	flags.StringP(
+		"metron-address",
+		"",
+		"",
+		"Address of the metron agent staging logs are sent to, e.g. localhost:3457. Staging logs aren't forwarded if empty.",
+	)
+
+	flags.BoolP(
+		"readiness-check-cc",
+		"",
+		false,
+		"Only report the stager as ready while the Cloud Controller can be reached.",
+	)
+
+	flags.StringP(
+		"tls-cert-file",
+		"",
+		"",
+		"Certificate the stager serves HTTPS with. The stager serves plain HTTP if empty.",
+	)
+
+	flags.StringP(
+		"tls-key-file",
+		"",
+		"",
+		"Private key of the certificate the stager serves HTTPS with.",
+	)
+
+	flags.StringP(
+		"tls-client-ca-file",
+		"",
+		"",
+		"CA bundle client certificates must be signed by to call the staging API. Client certificates aren't required if empty.",
+	)
+
+	flags.StringP(
+		"staging-client-ca-cert-file",
+		"",
+		"",
+		"CA the stager issues each staging pod a client certificate for its staging with, to present when reporting completion. It has to be in tls-client-ca-file.",
+	)
+
+	flags.StringP(
+		"staging-client-ca-key-file",
+		"",
+		"",
+		"Private key of the CA staging pod client certificates are issued with.",
+	)
+
+	flags.StringP(
+		"staging-server-ca-file",
+		"",
+		"",
+		"CA bundle staging pods verify the stager's certificate with when reporting completion. The system CAs are used if empty.",
+	)
+
+	flags.StringSliceP(
+		"log-redact-env-patterns",
+		"",
+		logger.DefaultSecretEnvPatterns,
+		"Regular expressions matched against NAME=value of environment variables whose values are masked in logs.",
+	)
+
+	flags.BoolP(
+		"auth-basic",
+		"",
+		false,
+		"Require callers to authenticate with the Cloud Controller internal API credentials.",
+	)
+
+	flags.StringP(
+		"auth-jwks-url",
+		"",
+		"",
+		"URL of the UAA token keys bearer tokens are verified with, e.g. https://uaa.example.com/token_keys. Bearer tokens aren't accepted if empty.",
+	)
+
+	flags.StringP(
+		"auth-jwks-ca-file",
+		"",
+		"",
+		"CA certificates the server of auth-jwks-url is verified with. The system CA certificates are used if empty; skip-cert-verify doesn't apply.",
+	)
+
+	flags.StringP(
+		"auth-jwt-issuer",
+		"",
+		"",
+		"Issuer bearer tokens must have. Not checked if empty.",
+	)
+
+	flags.StringP(
+		"auth-jwt-audience",
+		"",
+		"",
+		"Audience bearer tokens must have. Not checked if empty.",
+	)
+
+	flags.StringP(
+		"auth-jwt-cc-scope",
+		"",
+		"cloud_controller.admin",
+		"Bearer token scope that grants access to all staging operations.",
+	)
+
+	flags.StringP(
+		"auth-cc-cert-subject",
+		"",
+		"",
+		"Common name of the client certificate that grants access to all staging operations.",
+	)
+
+	flags.StringP(
+		"audit-log-file",
+		"",
+		"",
+		"File the audit log of staging operations and CC callbacks is appended to. No audit log is written if empty.",
+	)
+
+	flags.BoolP(
+		"audit-log-hash-chain",
+		"",
+		false,
+		"Chain the audit log records with SHA-256 hashes so that altered or removed records can be detected.",
+	)
+
+	flags.DurationP(
+		"config-reload-interval",
+		"",
+		time.Second*10,
+		"How often the config file is checked for changes to staging-image, app-lifecycle-url, custom-image-command, stage-stop-grace and log-level. They apply to stagings started after the change. Never checked if 0.",
+	)
+}
+
+// addKubernetesFlags defines the settings for connecting to Kubernetes and
+// finding the stagings of a stager, which the operator commands share with
+// the run command.
+func addKubernetesFlags(flags *pflag.FlagSet) {
+	flags.StringP(
+		"id",
+		"i",
+		"stager-0",
+		"Identifier of the stager.",
+	)
+
+	flags.StringP(
+		"k8s-endpoint",
+		"k",
+		"",
+		"Kubernetes HTTP API endpoint.",
+	)
+
+	flags.StringP(
+		"k8s-namespace",
+		"n",
+		"furnace-staging",
+		"Kubernetes namespace to use for staging.",
+	)
+
+	flags.StringP(
 		"k8s-client-cert",
 		"",
 		"",
 		"Path to a PEM-encoded client certificate.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
 		"k8s-client-key",
 		"",
 		"",
 		"Path to a PEM-encoded client key.",
 	)
 
-	runCmd.PersistentFlags().StringP(
+	flags.StringP(
 		"k8s-cacert",
 		"",
 		"",
 		"Path to a PEM-encoded CA certificate for connecting to kubernetes.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"cc-baseurl",
-		"",
+	flags.StringP(
+		"k8s-connection",
 		"",
-		"Cloud controller API location.",
+		k8s.ConnectionExplicit,
+		"How to connect to kubernetes: explicit uses k8s-endpoint and the k8s certificate flags, in-cluster the service account of the stager pod, kubeconfig a context of the kubeconfig file.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"cc-username",
+	flags.StringP(
+		"kubeconfig",
 		"",
 		"",
-		"Cloud Controller internal API username.",
+		"Path to the kubeconfig file used by the kubeconfig connection.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"cc-password",
+	flags.StringP(
+		"kubeconfig-context",
 		"",
 		"",
-		"Cloud Controller internal API password.",
+		"Context of the kubeconfig file to use. The current context if empty.",
 	)
 
-	runCmd.PersistentFlags().StringP(
-		"consul-cluster",
+	flags.Float32P(
+		"k8s-qps",
 		"",
+		5,
+		"Maximum number of requests per second to the kubernetes API.",
+	)
+
+	flags.IntP(
+		"k8s-burst",
 		"",
-		"Consul used for service discovery.",
+		10,
+		"Maximum burst of requests to the kubernetes API above k8s-qps.",
 	)
 
-	viper.BindPFlags(runCmd.PersistentFlags())
+	flags.DurationP(
+		"k8s-request-timeout",
+		"",
+		30*time.Second,
+		"Timeout of requests to the kubernetes API. Watches and followed staging logs aren't bounded. No timeout if 0.",
+	)
 }
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/client"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger"
+	apiclient "github.com/cf-furnace/k8s-stager/lib/swagger/client"
+	clientops "github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
+
+	uuid "github.com/nu7hatch/gouuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	stageLogsRetryInterval = 2 * time.Second
+
+	// followLogsTimeout bounds following logs through the stager's API. CC
+	// stops stagings long before.
+	followLogsTimeout = 24 * time.Hour
+)
+
+// stageCmd represents the stage command
+var stageCmd = &cobra.Command{
+	Use:   "stage",
+	Short: "Submits a staging to a running stager and waits for its result, standing in for CC.",
+	Long: `Submits a staging to a running stager like CC does, follows its logs and
+waits for the stager to deliver the result, which is printed. The staging
+request is read from a StagingRequestFromCC JSON file, or built from flags.
+
+The stager delivers the result to a callback this command serves, so the
+stager has to be able to reach --callback-url. The command exits with 1 if
+the staging failed. With --dry-run the objects the stager would create are
+printed instead, and nothing is staged.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+
+		request, err := stagingRequest(cmd)
+		if err != nil {
+			fmt.Println("Invalid staging request:", err)
+			os.Exit(1)
+		}
+
+		stagingGuid, _ := flags.GetString("staging-guid")
+		if stagingGuid == "" {
+			stagingGuid = request.AppID + "-" + newGuid()
+		}
+
+		stager, err := stagerClient(cmd, nil)
+		if err != nil {
+			fmt.Println("Invalid stager settings:", err)
+			os.Exit(1)
+		}
+
+		if dryRun, _ := flags.GetBool("dry-run"); dryRun {
+			if callbackURL, _ := flags.GetString("callback-url"); callbackURL != "" {
+				request.CompletionCallback = strings.TrimSuffix(callbackURL, "/") + "/staging/" + stagingGuid + "/completed"
+			}
+
+			dryRun := true
+			rendered, _, err := stager.Operations.Stage(clientops.NewStageParams().
+				WithStagingGUID(stagingGuid).
+				WithDryRun(&dryRun).
+				WithStagingRequest(request))
+			if err == nil && rendered == nil {
+				err = errors.New("the stager started the staging instead of rendering it")
+			}
+			if err != nil {
+				fmt.Println("The stager didn't render the staging:", stageErrorMessage(err))
+				os.Exit(1)
+			}
+
+			printJSON(rendered.Payload)
+			return
+		}
+
+		// The stand-in for CC's completion callback
+		callbackListen, _ := flags.GetString("callback-listen")
+		listener, err := net.Listen("tcp", callbackListen)
+		if err != nil {
+			fmt.Println("Error listening for the staging result:", err)
+			os.Exit(1)
+		}
+		defer listener.Close()
+
+		results := make(chan []byte, 1)
+		go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload, _ := ioutil.ReadAll(r.Body)
+			select {
+			case results <- payload:
+			default:
+			}
+		}))
+
+		callbackURL, _ := flags.GetString("callback-url")
+		if callbackURL == "" {
+			callbackURL = "http://" + listener.Addr().String()
+		}
+		request.CompletionCallback = strings.TrimSuffix(callbackURL, "/") + "/staging/" + stagingGuid + "/completed"
+
+		_, _, err = stager.Operations.Stage(clientops.NewStageParams().
+			WithStagingGUID(stagingGuid).
+			WithStagingRequest(request))
+		if err != nil {
+			fmt.Println("The stager didn't accept the staging:", stageErrorMessage(err))
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Staging %s accepted, waiting for the result on %s\n", stagingGuid, request.CompletionCallback)
+
+		done := make(chan struct{})
+		defer close(done)
+		if request.Lifecycle == swagger.BuildpackLifecycleName {
+			go followStagingLogs(cmd, stagingGuid, done)
+		}
+
+		timeout, _ := flags.GetDuration("timeout")
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+
+		select {
+		case payload := <-results:
+			printStagingResult(payload)
+		case <-time.After(timeout):
+			fmt.Printf("No staging result after %s\n", timeout)
+			os.Exit(1)
+		case <-interrupts:
+			fmt.Fprintln(os.Stderr, "Stopping the staging")
+			stager.Operations.StopStaging(clientops.NewStopStagingParams().WithStagingGUID(stagingGuid))
+			os.Exit(1)
+		}
+	},
+}
+
+// stagingRequest reads the staging request file, or builds a request from
+// the flags.
+func stagingRequest(cmd *cobra.Command) (*model.StagingRequestFromCC, error) {
+	flags := cmd.Flags()
+	request := &model.StagingRequestFromCC{}
+
+	if requestFile, _ := flags.GetString("request"); requestFile != "" {
+		content, err := ioutil.ReadFile(requestFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(content, request); err != nil {
+			return nil, fmt.Errorf("%s: %s", requestFile, err)
+		}
+	} else {
+		request.Lifecycle, _ = flags.GetString("lifecycle")
+		request.MemoryMb = 1024
+		request.DiskMb = 4096
+		request.FileDescriptors = 16384
+		request.Timeout = 900
+
+		switch request.Lifecycle {
+		case swagger.BuildpackLifecycleName:
+			appBitsURL, _ := flags.GetString("app-bits-url")
+			dropletUploadURL, _ := flags.GetString("droplet-upload-url")
+			stack, _ := flags.GetString("stack")
+			buildpackURLs, _ := flags.GetStringSlice("buildpack")
+
+			if appBitsURL == "" || dropletUploadURL == "" {
+				return nil, fmt.Errorf("--app-bits-url and --droplet-upload-url are required by the %s lifecycle", swagger.BuildpackLifecycleName)
+			}
+
+			buildpacks := make([]*lib.Buildpack, len(buildpackURLs))
+			for idx, buildpackURL := range buildpackURLs {
+				key := fmt.Sprintf("buildpack-%d", idx)
+				buildpacks[idx] = &lib.Buildpack{Key: key, Name: key, URL: buildpackURL}
+			}
+
+			request.LifecycleData = &lib.BuildpackLifecycle{
+				AppBitsDownloadURI: appBitsURL,
+				DropletUploadURI:   dropletUploadURL,
+				Stack:              stack,
+				Buildpacks:         buildpacks,
+			}
+		case swagger.DockerLifecycleName:
+			dockerImage, _ := flags.GetString("docker-image")
+			if dockerImage == "" {
+				return nil, fmt.Errorf("--docker-image is required by the %s lifecycle", swagger.DockerLifecycleName)
+			}
+
+			request.LifecycleData = &lib.DockerLifecycle{DockerImageUrl: dockerImage}
+		default:
+			return nil, fmt.Errorf("unknown lifecycle %q, expected %s or %s", request.Lifecycle, swagger.BuildpackLifecycleName, swagger.DockerLifecycleName)
+		}
+	}
+
+	if appId, _ := flags.GetString("app-id"); appId != "" {
+		request.AppID = appId
+	}
+	if request.AppID == "" {
+		request.AppID = newGuid()
+	}
+	if request.LogGUID == "" {
+		request.LogGUID = request.AppID
+	}
+
+	return request, nil
+}
+
+// stagerClient talks to the stager like CC does, with a client certificate
+// if the stager requires one. Staging logs are copied to logs if not nil.
+func stagerClient(cmd *cobra.Command, logs io.Writer) (*apiclient.K8sSwagger, error) {
+	flags := cmd.Flags()
+	viper.BindPFlag("cc-username", flags.Lookup("cc-username"))
+	viper.BindPFlag("cc-password", flags.Lookup("cc-password"))
+
+	stagerURL, _ := flags.GetString("stager-url")
+	caFile, _ := flags.GetString("stager-ca-file")
+	certFile, _ := flags.GetString("client-cert-file")
+	keyFile, _ := flags.GetString("client-key-file")
+	skipCertVerify, _ := flags.GetBool("skip-cert-verify")
+
+	return client.New(client.Config{
+		URL: stagerURL,
+		TLS: &client.TLSConfig{
+			CAFile:             caFile,
+			CertFile:           certFile,
+			KeyFile:            keyFile,
+			InsecureSkipVerify: skipCertVerify,
+		},
+		AuthInfo: client.BasicAuth(func() (string, string, error) {
+			password, err := stagePassword(cmd)
+			return viper.GetString("cc-username"), password, err
+		}),
+		Logs: logs,
+	})
+}
+
+func stagePassword(cmd *cobra.Command) (string, error) {
+	passwordFile, _ := cmd.Flags().GetString("cc-password-file")
+	if passwordFile == "" {
+		return viper.GetString("cc-password"), nil
+	}
+
+	password, err := ioutil.ReadFile(passwordFile)
+	return strings.TrimSpace(string(password)), err
+}
+
+// followStagingLogs copies the staging logs to stderr until done, reopening
+// them if the stager can't serve them yet.
+func followStagingLogs(cmd *cobra.Command, stagingGuid string, done <-chan struct{}) {
+	stager, err := stagerClient(cmd, os.Stderr)
+	if err != nil {
+		return
+	}
+
+	follow := true
+	for {
+		_, err = stager.Operations.StagingLogs(clientops.NewStagingLogsParamsWithTimeout(followLogsTimeout).
+			WithStagingGUID(stagingGuid).
+			WithFollow(&follow))
+
+		// The pod is gone, there won't be more logs
+		if _, gone := err.(*clientops.StagingLogsGone); gone {
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(stageLogsRetryInterval):
+		}
+	}
+}
+
+// stageErrorMessage returns the message of the staging error the stager
+// responded with, if any.
+func stageErrorMessage(err error) string {
+	var response *model.StagingResponseFromCC
+	switch e := err.(type) {
+	case *clientops.StageConflict:
+		response = e.Payload
+	case *clientops.StageInternalServerError:
+		response = e.Payload
+	}
+
+	if response != nil && response.Error != nil {
+		return response.Error.Message
+	}
+	return err.Error()
+}
+
+// printStagingResult prints the result the stager delivered and exits with
+// 1 if the staging failed.
+func printStagingResult(payload []byte) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(payload, &result); err != nil {
+		fmt.Println(string(payload))
+		os.Exit(1)
+	}
+
+	pretty, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(pretty))
+
+	if result["error"] != nil {
+		os.Exit(1)
+	}
+}
+
+func newGuid() string {
+	guid, err := uuid.NewV4()
+	if err != nil {
+		panic(err)
+	}
+
+	return guid.String()
+}
+
+// addStagerAPIFlags adds the flags to reach a running stager's API like CC
+// does.
+func addStagerAPIFlags(flags *pflag.FlagSet, stagerURL string) {
+	flags.String("stager-url", stagerURL, "URL of the running stager.")
+	flags.String("stager-ca-file", "", "CA certificate the stager's certificate is verified with.")
+	flags.String("client-cert-file", "", "Client certificate presented to the stager, if it requires one.")
+	flags.String("client-key-file", "", "Key of the client certificate.")
+	flags.Bool("skip-cert-verify", false, "Don't verify the stager's certificate.")
+	flags.String("cc-username", "", "CC internal API username the stager accepts. Also read from STAGER_CC_USERNAME or the config file.")
+	flags.String("cc-password", "", "CC internal API password the stager accepts. Also read from STAGER_CC_PASSWORD or the config file.")
+	flags.String("cc-password-file", "", "File holding the CC internal API password.")
+}
+
+// addStagingRequestFlags adds the flags stagingRequest builds a staging
+// request from.
+func addStagingRequestFlags(flags *pflag.FlagSet) {
+	flags.String("request", "", "JSON file with the staging request, as CC sends it. The flags below build a request if empty.")
+	flags.String("staging-guid", "", "Guid of the staging. Generated from the app id if empty.")
+	flags.String("app-id", "", "Guid of the app. Generated if empty.")
+	flags.String("lifecycle", swagger.BuildpackLifecycleName, "Lifecycle of the staging, buildpack or docker.")
+	flags.StringSlice("buildpack", []string{}, "Download URL of a buildpack, in the order they are tried.")
+	flags.String("app-bits-url", "", "Download URL of the app bits.")
+	flags.String("droplet-upload-url", "", "URL the droplet is uploaded to.")
+	flags.String("stack", "cflinuxfs2", "Stack the app is staged for.")
+	flags.String("docker-image", "", "Image of a docker app.")
+}
+
+func init() {
+	RootCmd.AddCommand(stageCmd)
+
+	flags := stageCmd.Flags()
+	addStagerAPIFlags(flags, "http://127.0.0.1:8080")
+
+	addStagingRequestFlags(flags)
+
+	flags.String("callback-listen", "127.0.0.1:0", "Address the stand-in CC callback listens on.")
+	flags.String("callback-url", "", "URL the stager reaches the stand-in CC callback on. The listen address if empty.")
+	flags.Duration("timeout", 15*time.Minute, "How long to wait for the staging result.")
+	flags.Bool("dry-run", false, "Print the objects the stager would create for the staging instead of staging.")
+}
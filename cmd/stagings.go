@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/config"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	apiclient "github.com/cf-furnace/k8s-stager/lib/swagger/client"
+	clientops "github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	k8sapi "k8s.io/kubernetes/pkg/api"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the stagings of a stager, oldest first.",
+	Long: `Lists the staging jobs the stager with the given id runs in Kubernetes.
+The stager's API doesn't list stagings, so this always talks to Kubernetes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		stagings, c := stagingClient(cmd)
+
+		jobs, err := stagings.ListStagingTasks(c.K8SNamespace)
+		if err != nil {
+			fmt.Println("Error listing stagings:", err)
+			os.Exit(1)
+		}
+
+		summaries := []k8s.StagingSummary{}
+		for _, job := range jobs {
+			summaries = append(summaries, k8s.SummarizeStaging(job))
+		}
+
+		if output == outputJSON {
+			printJSON(summaries)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "STAGING GUID\tAPP GUID\tSTATE\tATTEMPTS\tCREATED")
+		for _, summary := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+				summary.StagingGuid, summary.AppGuid, summary.State, summary.Attempts, formatTime(&summary.Created))
+		}
+		w.Flush()
+	},
+}
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <staging-guid>",
+	Short: "Shows the state of a staging.",
+	Long: `Shows the state of a staging from its Kubernetes job. The stager's API
+doesn't describe stagings, so this always talks to Kubernetes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stagingGuid := stagingGuidArg(cmd, args)
+		output := outputFormat(cmd)
+		stagings, c := stagingClient(cmd)
+
+		job, exists, err := stagings.GetStagingTask(stagingGuid, c.K8SNamespace)
+		if err != nil {
+			fmt.Println("Error looking up staging:", err)
+			os.Exit(1)
+		}
+		if !exists {
+			fmt.Printf("Staging %s not found\n", stagingGuid)
+			os.Exit(1)
+		}
+
+		summary := k8s.SummarizeStaging(job)
+
+		if output == outputJSON {
+			printJSON(summary)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "Staging guid:\t%s\n", summary.StagingGuid)
+		fmt.Fprintf(w, "App guid:\t%s\n", summary.AppGuid)
+		fmt.Fprintf(w, "State:\t%s\n", summary.State)
+		fmt.Fprintf(w, "Attempts:\t%d\n", summary.Attempts)
+		fmt.Fprintf(w, "Stack:\t%s\n", summary.Stack)
+		fmt.Fprintf(w, "Image:\t%s\n", summary.Image)
+		fmt.Fprintf(w, "Namespace:\t%s\n", summary.Namespace)
+		fmt.Fprintf(w, "Job:\t%s\n", summary.Job)
+		fmt.Fprintf(w, "Stager id:\t%s\n", summary.StagerId)
+		fmt.Fprintf(w, "Log guid:\t%s\n", summary.LogGuid)
+		fmt.Fprintf(w, "Request id:\t%s\n", summary.RequestId)
+		fmt.Fprintf(w, "Created:\t%s\n", formatTime(&summary.Created))
+		fmt.Fprintf(w, "Started:\t%s\n", formatTime(summary.Started))
+		fmt.Fprintf(w, "Completed:\t%s\n", formatTime(summary.Completed))
+		w.Flush()
+	},
+}
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <staging-guid>",
+	Short: "Prints the logs of a staging.",
+	Long: `Prints the logs of the staging container, through the stager's API if
+--stager-url is set, or directly from Kubernetes otherwise.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stagingGuid := stagingGuidArg(cmd, args)
+		flags := cmd.Flags()
+		follow, _ := flags.GetBool("follow")
+		tail, _ := flags.GetInt64("tail")
+
+		if stagerURL, _ := flags.GetString("stager-url"); stagerURL != "" {
+			params := clientops.NewStagingLogsParams()
+			if follow {
+				params = clientops.NewStagingLogsParamsWithTimeout(followLogsTimeout)
+			}
+			params.WithStagingGUID(stagingGuid).WithFollow(&follow)
+			if tail >= 0 {
+				params.WithTail(&tail)
+			}
+
+			_, err := stagerAPIClient(cmd, os.Stdout).Operations.StagingLogs(params)
+			switch err.(type) {
+			case nil:
+			case *clientops.StagingLogsNotFound:
+				exitStagingNotFound(stagingGuid)
+			case *clientops.StagingLogsGone:
+				exitStagingPodGone(stagingGuid)
+			case *clientops.StagingLogsServiceUnavailable:
+				exitStagingPodPending(stagingGuid)
+			default:
+				fmt.Println("Error reading staging logs:", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		stagings, c := stagingClient(cmd)
+		options := &k8s.LogOptions{Follow: follow}
+		if tail >= 0 {
+			options.TailLines = &tail
+		}
+
+		for {
+			logs, err := stagings.StreamStagingLogs(stagingGuid, c.K8SNamespace, options)
+			switch err {
+			case nil:
+				defer logs.Close()
+				io.Copy(os.Stdout, logs)
+				return
+			case k8s.ErrStagingPodPending:
+				if !follow {
+					exitStagingPodPending(stagingGuid)
+				}
+
+				// Wait for the pod to start, like the stager does
+				time.Sleep(stageLogsRetryInterval)
+			case k8s.ErrStagingTaskNotFound:
+				exitStagingNotFound(stagingGuid)
+			case k8s.ErrStagingPodGone:
+				exitStagingPodGone(stagingGuid)
+			default:
+				fmt.Println("Error reading staging logs:", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// cancelCmd represents the cancel command
+var cancelCmd = &cobra.Command{
+	Use:   "cancel <staging-guid>",
+	Short: "Cancels a staging.",
+	Long: `Stops a running staging, through the stager's API if --stager-url is set,
+or directly in Kubernetes otherwise. CC isn't told about the cancellation.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stagingGuid := stagingGuidArg(cmd, args)
+		flags := cmd.Flags()
+
+		if stagerURL, _ := flags.GetString("stager-url"); stagerURL != "" {
+			_, err := stagerAPIClient(cmd, nil).Operations.StopStaging(clientops.NewStopStagingParams().WithStagingGUID(stagingGuid))
+			switch err.(type) {
+			case nil:
+				fmt.Printf("Staging %s cancelled\n", stagingGuid)
+			case *clientops.StopStagingNotFound:
+				exitStagingNotFound(stagingGuid)
+			default:
+				fmt.Println("Error cancelling staging:", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		stagings, c := stagingClient(cmd)
+
+		_, exists, err := stagings.GetStagingTask(stagingGuid, c.K8SNamespace)
+		if err != nil {
+			fmt.Println("Error looking up staging:", err)
+			os.Exit(1)
+		}
+		if !exists {
+			exitStagingNotFound(stagingGuid)
+		}
+
+		// Failing to record the event doesn't keep the staging from stopping
+		stagings.RecordStagingEvent(stagingGuid, c.K8SNamespace, k8sapi.EventTypeNormal, k8s.ReasonStagingCancelled,
+			"Staging cancelled from the command line")
+
+		gracePeriod := viper.GetDuration("stage-stop-grace")
+		if err := stagings.StopStaging(stagingGuid, c.K8SNamespace, int64(gracePeriod.Seconds())); err != nil {
+			fmt.Println("Error cancelling staging:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Staging %s cancelled\n", stagingGuid)
+	},
+}
+
+// stagingClient connects to Kubernetes with the stager's settings.
+func stagingClient(cmd *cobra.Command) (k8s.K8SStagingClient, *lib.ServerConfig) {
+	viper.BindPFlags(cmd.Flags())
+
+	c, err := config.LoadKubernetes(viper.GetViper())
+	if err != nil {
+		fmt.Println("Invalid configuration:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	logger := lager.NewLogger("stager")
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.ERROR))
+
+	stagings, err := k8s.NewStager(c.K8SConnectionConfig(), c.StagerId, logger)
+	if err != nil {
+		fmt.Println("Error connecting to Kubernetes:", err)
+		os.Exit(1)
+	}
+
+	return stagings, c
+}
+
+func stagerAPIClient(cmd *cobra.Command, logs io.Writer) *apiclient.K8sSwagger {
+	stager, err := stagerClient(cmd, logs)
+	if err != nil {
+		fmt.Println("Invalid stager settings:", err)
+		os.Exit(1)
+	}
+
+	return stager
+}
+
+func stagingGuidArg(cmd *cobra.Command, args []string) string {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %s\n", cmd.UseLine())
+		os.Exit(1)
+	}
+
+	return args[0]
+}
+
+func outputFormat(cmd *cobra.Command) string {
+	output, _ := cmd.Flags().GetString("output")
+	if output != outputTable && output != outputJSON {
+		fmt.Printf("Unknown output format %q, expected %s or %s\n", output, outputTable, outputJSON)
+		os.Exit(1)
+	}
+
+	return output
+}
+
+func printJSON(value interface{}) {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Println("Error encoding output:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(encoded))
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+func exitStagingNotFound(stagingGuid string) {
+	fmt.Printf("Staging %s not found\n", stagingGuid)
+	os.Exit(1)
+}
+
+func exitStagingPodPending(stagingGuid string) {
+	fmt.Printf("The pod of staging %s hasn't started yet\n", stagingGuid)
+	os.Exit(1)
+}
+
+func exitStagingPodGone(stagingGuid string) {
+	fmt.Printf("The pod of staging %s is gone, its logs are no longer available\n", stagingGuid)
+	os.Exit(1)
+}
+
+func addOutputFlag(flags *pflag.FlagSet) {
+	flags.StringP("output", "o", outputTable, "Output format, table or json.")
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+	RootCmd.AddCommand(showCmd)
+	RootCmd.AddCommand(logsCmd)
+	RootCmd.AddCommand(cancelCmd)
+
+	for _, command := range []*cobra.Command{listCmd, showCmd, logsCmd, cancelCmd} {
+		addKubernetesFlags(command.Flags())
+	}
+
+	addOutputFlag(listCmd.Flags())
+	addOutputFlag(showCmd.Flags())
+
+	logsFlags := logsCmd.Flags()
+	addStagerAPIFlags(logsFlags, "")
+	logsFlags.BoolP("follow", "f", false, "Follow the logs until the staging container exits.")
+	logsFlags.Int64("tail", -1, "Number of lines from the end of the logs to print. All lines if negative.")
+
+	cancelFlags := cancelCmd.Flags()
+	addStagerAPIFlags(cancelFlags, "")
+	cancelFlags.DurationP("stage-stop-grace", "g", time.Second*60, "Grace period for stopping the staging, when talking to Kubernetes.")
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operations recorded in the audit log
+const (
+	OperationStage      = "stage"
+	OperationStop       = "stop"
+	OperationComplete   = "complete"
+	OperationCCCallback = "cc_callback"
+)
+
+// Outcomes of CC callbacks. API operations are recorded with the response
+// they got.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+const (
+	maxRecordLineBytes   = 1024 * 1024
+	initialScanLineBytes = 64 * 1024
+)
+
+// Record describes one staging API operation or outbound CC callback.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Operation   string    `json:"operation"`
+	RequestId   string    `json:"request_id,omitempty"`
+	Caller      string    `json:"caller,omitempty"`
+	RemoteAddr  string    `json:"remote_addr,omitempty"`
+	AppGuid     string    `json:"app_guid,omitempty"`
+	StagingGuid string    `json:"staging_guid"`
+	Lifecycle   string    `json:"lifecycle,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Buildpacks  []string  `json:"buildpacks,omitempty"`
+	Target      string    `json:"target,omitempty"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  float64   `json:"duration_ms"`
+
+	// Set by hash chaining loggers
+	PreviousHash string `json:"previous_hash,omitempty"`
+	Hash         string `json:"hash,omitempty"`
+}
+
+// Logger writes audit records.
+type Logger interface {
+	Record(record Record) error
+}
+
+type nopLogger struct{}
+
+// NewNopLogger discards all records, for when no audit log is configured.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Record(Record) error {
+	return nil
+}
+
+type fileLogger struct {
+	lock      sync.Mutex
+	file      *os.File
+	hashChain bool
+	lastHash  string
+}
+
+// NewFileLogger appends one JSON record per line to the file at path. With
+// hash chaining, each record carries the hash of the previous one and its
+// own hash over its content, so that removed or altered records can be
+// detected with Verify. An existing chain is continued.
+func NewFileLogger(path string, hashChain bool) (Logger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &fileLogger{file: file, hashChain: hashChain}
+
+	if hashChain {
+		if l.lastHash, err = lastHash(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+func (l *fileLogger) Record(record Record) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	// UTC keeps the time identical when Verify reads the record back
+	record.Time = record.Time.UTC()
+	record.PreviousHash = ""
+	record.Hash = ""
+
+	if l.hashChain {
+		record.PreviousHash = l.lastHash
+
+		hash, err := recordHash(record)
+		if err != nil {
+			return err
+		}
+		record.Hash = hash
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+
+	l.lastHash = record.Hash
+	return nil
+}
+
+// Verify checks the hash chain of an audit log and returns an error naming
+// the first line that doesn't match.
+func Verify(log io.Reader) error {
+	scanner := newScanner(log)
+	previousHash := ""
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		if record.PreviousHash != previousHash {
+			return fmt.Errorf("line %d: chain broken, expected previous hash %q", lineNumber, previousHash)
+		}
+
+		hash, err := recordHash(record)
+		if err != nil {
+			return err
+		}
+
+		if record.Hash != hash {
+			return fmt.Errorf("line %d: record was modified", lineNumber)
+		}
+
+		previousHash = record.Hash
+	}
+
+	return scanner.Err()
+}
+
+// StripCredentials removes userinfo and query from a URL, which is where
+// signed URLs and basic auth credentials live.
+func StripCredentials(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" {
+		return rawURL
+	}
+
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+
+	return parsed.String()
+}
+
+// The hash covers the whole record except the hash itself
+func recordHash(record Record) (string, error) {
+	record.Hash = ""
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func lastHash(file *os.File) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	scanner := newScanner(file)
+	hash := ""
+
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return "", fmt.Errorf("can't continue the hash chain of the audit log: %s", err)
+		}
+		hash = record.Hash
+	}
+
+	return hash, scanner.Err()
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, initialScanLineBytes), maxRecordLineBytes)
+
+	return scanner
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Role decides which staging API operations a caller may use.
+type Role string
+
+const (
+	// RoleCC may use every operation.
+	RoleCC Role = "cc"
+	// RoleStaging is given to staging pods, which only report completion
+	// of their own staging.
+	RoleStaging Role = "staging"
+)
+
+// ErrInvalidCredentials is returned when a request carries credentials of an
+// authenticator's kind that don't check out.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name  string
+	Roles []Role
+	// StagingGuid is the staging a staging pod was given its credentials
+	// for, and the only one it may act on.
+	StagingGuid string
+}
+
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator identifies the caller of a request. It returns a nil
+// principal and no error when the request carries no credentials of its
+// kind, so that the next authenticator can be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticate tries each authenticator in turn and returns the first
+// principal found. It returns ErrInvalidCredentials if no authenticator
+// recognises the request.
+func Authenticate(authenticators []Authenticator, r *http.Request) (*Principal, error) {
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if principal != nil {
+			return principal, nil
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
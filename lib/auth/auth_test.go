@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateFallsThroughToBasicAuth(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	authenticators := []Authenticator{
+		NewCertificateAuthenticator(map[string]Role{"staging": RoleStaging}),
+		NewBasicAuthenticator("internal_user", "secret"),
+	}
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.SetBasicAuth("internal_user", "secret")
+
+	// Act
+	principal, err := Authenticate(authenticators, request)
+
+	// Assert
+	assert.NoError(err)
+	assert.True(principal.HasRole(RoleCC))
+	assert.False(principal.HasRole(RoleStaging))
+}
+
+func TestAuthenticateRejectsWrongPassword(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	authenticators := []Authenticator{NewBasicAuthenticator("internal_user", "secret")}
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.SetBasicAuth("internal_user", "guess")
+
+	// Act
+	_, err := Authenticate(authenticators, request)
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, err)
+}
+
+func TestAuthenticateRejectsRequestsWithoutCredentials(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	authenticators := []Authenticator{NewBasicAuthenticator("internal_user", "secret")}
+
+	// Act
+	_, err := Authenticate(authenticators, httptest.NewRequest("PUT", "/v1/staging/guid", nil))
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, err)
+}
+
+func TestRotatingBasicAuthenticatorAcceptsRotatedPassword(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	password := "secret"
+	authenticators := []Authenticator{NewRotatingBasicAuthenticator(func() (string, string, error) {
+		return "internal_user", password, nil
+	})}
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.SetBasicAuth("internal_user", "rotated")
+
+	// Act
+	_, errBefore := Authenticate(authenticators, request)
+	password = "rotated"
+	principal, errAfter := Authenticate(authenticators, request)
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, errBefore)
+	assert.NoError(errAfter)
+	assert.True(principal.HasRole(RoleCC))
+}
+
+func TestBasicAuthenticatorRejectsEmptyCredentials(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	authenticators := []Authenticator{NewRotatingBasicAuthenticator(func() (string, string, error) {
+		return "", "", errors.New("secret file is empty")
+	})}
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.SetBasicAuth("", "")
+
+	// Act
+	_, err := Authenticate(authenticators, request)
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, err)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// CredentialsFunc returns the credentials currently accepted.
+type CredentialsFunc func() (username, password string, err error)
+
+type basicAuthenticator struct {
+	credentials CredentialsFunc
+}
+
+// NewBasicAuthenticator accepts the CC internal API credentials, which are
+// the same ones the stager uses to call CC.
+func NewBasicAuthenticator(username, password string) Authenticator {
+	return NewRotatingBasicAuthenticator(func() (string, string, error) {
+		return username, password, nil
+	})
+}
+
+// NewRotatingBasicAuthenticator accepts the CC internal API credentials
+// credentials returns when a request is authenticated, so that rotated
+// credentials are accepted right away.
+func NewRotatingBasicAuthenticator(credentials CredentialsFunc) Authenticator {
+	return &basicAuthenticator{credentials: credentials}
+}
+
+func (a *basicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+
+	// Unreadable rotated credentials leave the last ones in place
+	expectedUsername, expectedPassword, _ := a.credentials()
+
+	// Empty credentials never match, even if the expected ones are empty too
+	if username == "" || password == "" || expectedUsername == "" || expectedPassword == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(expectedUsername)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(expectedPassword)) == 1
+	if !usernameMatches || !passwordMatches {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Name: username, Roles: []Role{RoleCC}}, nil
+}
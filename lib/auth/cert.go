@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+type certificateAuthenticator struct {
+	subjectRoles map[string]Role
+}
+
+// NewCertificateAuthenticator maps the common name of a verified client
+// certificate to a role. Other certificates are left to the remaining
+// authenticators, since mutual TLS may require callers to present one anyway.
+func NewCertificateAuthenticator(subjectRoles map[string]Role) Authenticator {
+	return &certificateAuthenticator{subjectRoles: subjectRoles}
+}
+
+func (a *certificateAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+
+	commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+
+	role, ok := a.subjectRoles[commonName]
+	if !ok {
+		return nil, nil
+	}
+
+	return &Principal{Name: commonName, Roles: []Role{role}}, nil
+}
+
+// CertPoolFunc returns the CAs currently trusted.
+type CertPoolFunc func() (*x509.CertPool, error)
+
+type stagingCertificateAuthenticator struct {
+	stagingCAs CertPoolFunc
+}
+
+// NewStagingCertificateAuthenticator accepts the client certificates the
+// stager issues to staging pods, signed by one of the staging CAs with the
+// staging guid as the common name. The principal may only act on that
+// staging.
+func NewStagingCertificateAuthenticator(stagingCAs CertPoolFunc) Authenticator {
+	return &stagingCertificateAuthenticator{stagingCAs: stagingCAs}
+}
+
+func (a *stagingCertificateAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+
+	roots, err := a.stagingCAs()
+	if err != nil {
+		return nil, err
+	}
+
+	// The chain is verified against all client CAs, only certificates
+	// issued by a staging CA identify a staging
+	leaf := r.TLS.VerifiedChains[0][0]
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil || leaf.Subject.CommonName == "" {
+		return nil, nil
+	}
+
+	return &Principal{
+		Name:        leaf.Subject.CommonName,
+		Roles:       []Role{RoleStaging},
+		StagingGuid: leaf.Subject.CommonName,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newCertificate creates a certificate signed by parent, or a self-signed CA
+// if parent is nil.
+func newCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate, key
+}
+
+func TestStagingCertificateAuthenticatorBindsStagingGuid(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stagingCA, stagingCAKey := newCertificate(t, "staging-ca", nil, nil)
+	otherCA, otherCAKey := newCertificate(t, "other-ca", nil, nil)
+	stagingPod, _ := newCertificate(t, "staging-guid", stagingCA, stagingCAKey)
+	otherClient, _ := newCertificate(t, "staging-guid", otherCA, otherCAKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(stagingCA)
+	authenticator := NewStagingCertificateAuthenticator(func() (*x509.CertPool, error) {
+		return roots, nil
+	})
+
+	fromPod := httptest.NewRequest("POST", "/v1/staging/staging-guid/completed", nil)
+	fromPod.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{stagingPod, stagingCA}}}
+	fromOther := httptest.NewRequest("POST", "/v1/staging/staging-guid/completed", nil)
+	fromOther.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{otherClient, otherCA}}}
+
+	// Act
+	podPrincipal, podErr := authenticator.Authenticate(fromPod)
+	otherPrincipal, otherErr := authenticator.Authenticate(fromOther)
+
+	// Assert
+	assert.NoError(podErr)
+	if assert.NotNil(podPrincipal) {
+		assert.True(podPrincipal.HasRole(RoleStaging))
+		assert.False(podPrincipal.HasRole(RoleCC))
+		assert.Equal("staging-guid", podPrincipal.StagingGuid)
+	}
+
+	assert.NoError(otherErr)
+	assert.Nil(otherPrincipal)
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits how often a remote key set is fetched again
+// when a token names a key it doesn't know, or after fetching it failed.
+const jwksRefreshInterval = time.Minute
+
+// KeySet provides the public keys tokens are signed with. An empty key id
+// matches the only key of a key set that has a single key.
+type KeySet interface {
+	Key(keyId string) (*rsa.PublicKey, error)
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyId   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+type staticKeySet map[string]*rsa.PublicKey
+
+// ParseKeySet reads the RSA keys of a JSON Web Key Set document, as served by
+// UAA at /token_keys.
+func ParseKeySet(document []byte) (KeySet, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(document, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := staticKeySet{}
+	for _, key := range jwks.Keys {
+		if key.KeyType != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.N, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %s", key.KeyId, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.E, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %s", key.KeyId, err)
+		}
+
+		keys[key.KeyId] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (k staticKeySet) Key(keyId string) (*rsa.PublicKey, error) {
+	if key, ok := k[keyId]; ok {
+		return key, nil
+	}
+
+	if keyId == "" && len(k) == 1 {
+		for _, key := range k {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", keyId)
+}
+
+type remoteKeySet struct {
+	url        string
+	httpClient *http.Client
+
+	lock      sync.Mutex
+	keys      KeySet
+	fetchedAt time.Time
+	fetchErr  error
+}
+
+// NewRemoteKeySet fetches a key set from a URL when it is first needed, and
+// again when a token names an unknown key, so that rotated keys are found.
+// It is fetched at most once per jwksRefreshInterval, failed fetches
+// included, so that tokens can't make the stager flood the key endpoint.
+func NewRemoteKeySet(url string, httpClient *http.Client) KeySet {
+	return &remoteKeySet{url: url, httpClient: httpClient}
+}
+
+func (k *remoteKeySet) Key(keyId string) (*rsa.PublicKey, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.keys != nil {
+		if key, err := k.keys.Key(keyId); err == nil {
+			return key, nil
+		}
+	}
+
+	if k.fetchedAt.IsZero() || time.Since(k.fetchedAt) >= jwksRefreshInterval {
+		k.fetchErr = k.fetch()
+		k.fetchedAt = time.Now()
+	}
+
+	// The keys fetched before are kept when fetching them again fails
+	if k.fetchErr != nil {
+		return nil, k.fetchErr
+	}
+
+	return k.keys.Key(keyId)
+}
+
+func (k *remoteKeySet) fetch() error {
+	response, err := k.httpClient.Get(k.url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching key set from %s failed with %d", k.url, response.StatusCode)
+	}
+
+	document, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseKeySet(document)
+	if err != nil {
+		return err
+	}
+
+	k.keys = keys
+
+	return nil
+}
+
+type jwtAuthenticator struct {
+	keySet     KeySet
+	issuer     string
+	audience   string
+	scopeRoles map[string]Role
+}
+
+// NewJWTAuthenticator accepts RS256 signed bearer tokens issued by UAA. The
+// caller gets the roles its token has scopes for. Issuer and audience are
+// only checked if they aren't empty.
+func NewJWTAuthenticator(keySet KeySet, issuer, audience string, scopeRoles map[string]Role) Authenticator {
+	return &jwtAuthenticator{
+		keySet:     keySet,
+		issuer:     issuer,
+		audience:   audience,
+		scopeRoles: scopeRoles,
+	}
+}
+
+type tokenClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	ClientId  string          `json:"client_id"`
+	Subject   string          `json:"sub"`
+	Scopes    []string        `json:"scope"`
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+		return nil, nil
+	}
+
+	claims, err := a.verify(strings.TrimSpace(authorization[7:]))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	principal := &Principal{Name: claims.ClientId}
+	if principal.Name == "" {
+		principal.Name = claims.Subject
+	}
+
+	for _, scope := range claims.Scopes {
+		if role, ok := a.scopeRoles[scope]; ok {
+			principal.Roles = append(principal.Roles, role)
+		}
+	}
+
+	return principal, nil
+}
+
+func (a *jwtAuthenticator) verify(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyId     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+
+	key, err := a.keySet.Key(header.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	claims := &tokenClaims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, errors.New("token not valid yet")
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+
+	if a.audience != "" && !hasAudience(claims.Audience, a.audience) {
+		return nil, fmt.Errorf("token not issued for %q", a.audience)
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// The audience claim is either a single string or a list of them
+func hasAudience(claim json.RawMessage, audience string) bool {
+	var audiences []string
+	if err := json.Unmarshal(claim, &audiences); err != nil {
+		var single string
+		if err := json.Unmarshal(claim, &single); err != nil {
+			return false
+		}
+		audiences = []string{single}
+	}
+
+	for _, a := range audiences {
+		if a == audience {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateKeySet(t *testing.T, keyId string) (*rsa.PrivateKey, KeySet) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyId,
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}},
+	})
+
+	keySet, err := ParseKeySet(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, keySet
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, keyId string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": keyId, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthenticatorMapsScopesToRoles(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, keySet := generateKeySet(t, "key-1")
+	authenticator := NewJWTAuthenticator(keySet, "https://uaa.example.com/oauth/token", "cloud_controller", map[string]Role{
+		"cloud_controller.admin": RoleCC,
+	})
+
+	token := signToken(t, key, "key-1", map[string]interface{}{
+		"iss":       "https://uaa.example.com/oauth/token",
+		"aud":       []string{"cloud_controller"},
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"client_id": "cloud_controller",
+		"scope":     []string{"cloud_controller.admin", "uaa.none"},
+	})
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.Header.Set("Authorization", "bearer "+token)
+
+	// Act
+	principal, err := authenticator.Authenticate(request)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("cloud_controller", principal.Name)
+	assert.Equal([]Role{RoleCC}, principal.Roles)
+}
+
+func TestJWTAuthenticatorRejectsExpiredTokens(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	key, keySet := generateKeySet(t, "key-1")
+	authenticator := NewJWTAuthenticator(keySet, "", "", map[string]Role{})
+
+	token := signToken(t, key, "key-1", map[string]interface{}{
+		"exp": time.Now().Add(-time.Minute).Unix(),
+	})
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	// Act
+	_, err := authenticator.Authenticate(request)
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, err)
+}
+
+func TestJWTAuthenticatorRejectsTokensSignedWithOtherKeys(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	_, keySet := generateKeySet(t, "key-1")
+	otherKey, _ := generateKeySet(t, "key-1")
+	authenticator := NewJWTAuthenticator(keySet, "", "", map[string]Role{})
+
+	token := signToken(t, otherKey, "key-1", map[string]interface{}{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	// Act
+	_, err := authenticator.Authenticate(request)
+
+	// Assert
+	assert.Equal(ErrInvalidCredentials, err)
+}
+
+func TestRemoteKeySetBacksOffAfterFailedFetch(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	keySet := NewRemoteKeySet(server.URL, http.DefaultClient)
+
+	// Act
+	_, firstErr := keySet.Key("key-1")
+	_, secondErr := keySet.Key("key-1")
+	_, otherErr := keySet.Key("key-2")
+
+	// Assert
+	assert.Error(firstErr)
+	assert.Equal(firstErr, secondErr)
+	assert.Equal(firstErr, otherErr)
+	assert.Equal(1, fetches)
+}
@@ -0,0 +1,195 @@
+// Package client configures the stager API client generated into
+// lib/swagger/client with the transport, TLS settings and credentials to
+// reach a running stager.
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cf-furnace/k8s-stager/lib/auth"
+	apiclient "github.com/cf-furnace/k8s-stager/lib/swagger/client"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+)
+
+// Config describes how to reach a stager.
+type Config struct {
+	// URL of the stager, without the API base path
+	URL string
+
+	// Transport sends the requests. A transport using TLS is built if nil.
+	Transport http.RoundTripper
+
+	// TLS is used if Transport is nil.
+	TLS *TLSConfig
+
+	// AuthInfo adds credentials to every request. Requests aren't
+	// authorized if nil.
+	AuthInfo runtime.ClientAuthInfoWriter
+
+	// Logs receives the staging logs as they are read, so that followed
+	// logs show up before the staging ends. The payload of stagingLogs
+	// responses is left empty if set.
+	Logs io.Writer
+}
+
+// eventStreamMime is the media type of logs sent as server-sent events,
+// which the stager may choose over plain text.
+const eventStreamMime = "text/event-stream"
+
+// New creates a client for the stager the config describes. Operations time
+// out after the timeout of their params, so params of followed logs should
+// be created with a timeout longer than the staging.
+func New(config Config) (*apiclient.K8sSwagger, error) {
+	stagerURL, err := url.Parse(config.URL)
+	if err != nil || stagerURL.Host == "" {
+		return nil, fmt.Errorf("invalid stager URL %q", config.URL)
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		tlsConfig := &TLSConfig{}
+		if config.TLS != nil {
+			tlsConfig = config.TLS
+		}
+
+		if transport, err = NewTransport(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	basePath := strings.TrimSuffix(stagerURL.Path, "/") + apiclient.DefaultBasePath
+	stager := httptransport.New(stagerURL.Host, basePath, []string{stagerURL.Scheme})
+	stager.Transport = transport
+	stager.DefaultAuthentication = config.AuthInfo
+
+	// The stager picks either media type for staging logs, so both are read
+	// the same way
+	logs := runtime.TextConsumer()
+	if config.Logs != nil {
+		logs = writerConsumer(config.Logs)
+	}
+	stager.Consumers[runtime.TextMime] = logs
+	stager.Consumers[eventStreamMime] = eventStreamConsumer(logs)
+
+	return apiclient.New(pathEscapingTransport{stager}, strfmt.Default), nil
+}
+
+// BasicAuth authorizes requests with the credentials CC uses, read for every
+// request so rotated credentials are picked up.
+func BasicAuth(credentials auth.CredentialsFunc) runtime.ClientAuthInfoWriter {
+	return runtime.ClientAuthInfoWriterFunc(func(request runtime.ClientRequest, formats strfmt.Registry) error {
+		username, password, err := credentials()
+		if err != nil {
+			return fmt.Errorf("reading credentials: %s", err)
+		}
+
+		if username == "" && password == "" {
+			return nil
+		}
+		return httptransport.BasicAuth(username, password).AuthenticateRequest(request, formats)
+	})
+}
+
+// BearerToken authorizes requests with a token, read for every request.
+func BearerToken(token func() (string, error)) runtime.ClientAuthInfoWriter {
+	return runtime.ClientAuthInfoWriterFunc(func(request runtime.ClientRequest, formats strfmt.Registry) error {
+		value, err := token()
+		if err != nil {
+			return fmt.Errorf("reading token: %s", err)
+		}
+
+		return httptransport.BearerToken(value).AuthenticateRequest(request, formats)
+	})
+}
+
+// writerConsumer copies text responses to w as they are read.
+func writerConsumer(w io.Writer) runtime.Consumer {
+	return runtime.ConsumerFunc(func(reader io.Reader, data interface{}) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+}
+
+// eventStreamConsumer passes the data of server-sent events to text as lines
+// of plain text. Other events end the stream, like the end of a plain text
+// response does.
+func eventStreamConsumer(text runtime.Consumer) runtime.Consumer {
+	return runtime.ConsumerFunc(func(reader io.Reader, data interface{}) error {
+		lines, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(copyEventData(writer, reader))
+		}()
+
+		err := text.Consume(lines, data)
+		// Unblocks the copy if text stopped reading early
+		lines.Close()
+		return err
+	})
+}
+
+func copyEventData(w io.Writer, events io.Reader) error {
+	reader := bufio.NewReader(events)
+	event := ""
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:") && (event == "" || event == "message"):
+			data := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+			if _, writeErr := io.WriteString(w, data+"\n"); writeErr != nil {
+				return writeErr
+			}
+		case strings.HasPrefix(line, "data:"):
+			return nil
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// pathEscapingTransport escapes path params, which the runtime substitutes
+// into the operation's path pattern as they are.
+type pathEscapingTransport struct {
+	runtime.ClientTransport
+}
+
+func (t pathEscapingTransport) Submit(operation *runtime.ClientOperation) (interface{}, error) {
+	params := operation.Params
+	escaped := *operation
+	escaped.Params = runtime.ClientRequestWriterFunc(func(request runtime.ClientRequest, formats strfmt.Registry) error {
+		return params.WriteToRequest(pathEscapingRequest{request}, formats)
+	})
+
+	return t.ClientTransport.Submit(&escaped)
+}
+
+type pathEscapingRequest struct {
+	runtime.ClientRequest
+}
+
+func (r pathEscapingRequest) SetPathParam(name, value string) error {
+	// The runtime cleans the path, so these would address another resource
+	// even when escaped
+	if value == "" || value == "." || value == ".." || strings.Contains(value, "/") {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return r.ClientRequest.SetPathParam(name, url.PathEscape(value))
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	apiclient "github.com/cf-furnace/k8s-stager/lib/swagger/client"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T, config Config, handler http.HandlerFunc) (*apiclient.K8sSwagger, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	config.URL = server.URL
+	stager, err := New(config)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return stager, server
+}
+
+func TestNewReadsCredentialsForEveryRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	passwords := []string{"old_password", "new_password"}
+	var presented []string
+	stager, server := newTestClient(t, Config{
+		AuthInfo: BasicAuth(func() (string, string, error) {
+			password := passwords[0]
+			passwords = passwords[1:]
+			return "internal_user", password, nil
+		}),
+	}, func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		presented = append(presented, username+":"+password)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+
+	// Act
+	_, firstErr := stager.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID("staging-guid"))
+	_, secondErr := stager.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID("staging-guid"))
+
+	// Assert
+	assert.NoError(firstErr)
+	assert.NoError(secondErr)
+	assert.Equal([]string{"internal_user:old_password", "internal_user:new_password"}, presented)
+}
+
+func TestNewEscapesStagingGuid(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var paths []string
+	stager, server := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+
+	// Act
+	_, escapedErr := stager.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID("staging guid?x=1"))
+	_, slashErr := stager.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID("../staging-guid"))
+	_, dotsErr := stager.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID(".."))
+
+	// Assert
+	assert.NoError(escapedErr)
+	assert.Error(slashErr)
+	assert.Error(dotsErr)
+	assert.Equal([]string{"/v1/staging/staging%20guid%3Fx=1"}, paths)
+}
+
+func TestNewCopiesLogsToWriter(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	var follow, tail string
+	stager, server := newTestClient(t, Config{Logs: logs}, func(w http.ResponseWriter, r *http.Request) {
+		follow, tail = r.URL.Query().Get("follow"), r.URL.Query().Get("tail")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("line one\n"))
+	})
+	defer server.Close()
+	following, lines := true, int64(5)
+
+	// Act
+	_, err := stager.Operations.StagingLogs(operations.NewStagingLogsParams().
+		WithStagingGUID("staging-guid").
+		WithFollow(&following).
+		WithTail(&lines))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("line one\n", logs.String())
+	assert.Equal("true", follow)
+	assert.Equal("5", tail)
+}
+
+func TestNewReadsLogsSentAsEvents(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: line one\n\ndata: line two\n\nevent: error\ndata: client disconnected\n\n"))
+	}
+	stager, server := newTestClient(t, Config{}, handler)
+	defer server.Close()
+	writingStager, writingServer := newTestClient(t, Config{Logs: logs}, handler)
+	defer writingServer.Close()
+	params := operations.NewStagingLogsParams().WithStagingGUID("staging-guid")
+
+	// Act
+	ok, err := stager.Operations.StagingLogs(params)
+	_, writingErr := writingStager.Operations.StagingLogs(params)
+
+	// Assert
+	if assert.NoError(err) {
+		assert.Equal("line one\nline two\n", ok.Payload)
+	}
+	assert.NoError(writingErr)
+	assert.Equal("line one\nline two\n", logs.String())
+}
+
+func TestNewReadsLogErrorsOfEitherMediaType(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	contentTypes := map[string]string{
+		"plain-staging":  "text/plain",
+		"events-staging": "text/event-stream",
+	}
+	stager, server := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypes[path.Base(path.Dir(r.URL.Path))])
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	for stagingGuid := range contentTypes {
+		// Act
+		_, err := stager.Operations.StagingLogs(operations.NewStagingLogsParams().WithStagingGUID(stagingGuid))
+
+		// Assert
+		assert.IsType(&operations.StagingLogsNotFound{}, err, stagingGuid)
+	}
+}
+
+func TestNewRejectsURLWithoutHost(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := New(Config{URL: "stager.service.cf.internal"})
+
+	// Assert
+	assert.Error(err)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig describes how to verify the stager and authenticate to it with a
+// client certificate.
+type TLSConfig struct {
+	// CAFile holds the CA certificates the stager's certificate is verified
+	// with. The system roots are used if empty.
+	CAFile string
+
+	// CertFile and KeyFile hold the client certificate, if the stager
+	// requires one.
+	CertFile string
+	KeyFile  string
+
+	InsecureSkipVerify bool
+}
+
+// NewTransport creates a transport with the TLS settings, honoring the
+// proxy environment variables.
+func NewTransport(config *TLSConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+
+	if config.CAFile != "" {
+		caCert, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates in %s", config.CAFile)
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}, nil
+}
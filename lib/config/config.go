@@ -0,0 +1,483 @@
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/discovery"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/logger"
+	"github.com/cf-furnace/k8s-stager/lib/secret"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Errors lists everything that is wrong with a configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Load reads the stager configuration from v and validates it. All problems
+// are reported at once as Errors.
+func Load(v *viper.Viper) (*lib.ServerConfig, error) {
+	return load(&reader{viper: v})
+}
+
+// Reload reads the stager configuration from v like Load, but keeps reading
+// the secrets of current from its files whose paths didn't change. They
+// keep returning the last secret read while a rotated file is rewritten.
+func Reload(v *viper.Viper, current *lib.ServerConfig) (*lib.ServerConfig, error) {
+	r := &reader{viper: v, secretFiles: map[string]*secret.File{}}
+	for _, file := range []*secret.File{current.CCUsernameFile, current.CCPasswordFile} {
+		if file != nil {
+			r.secretFiles[file.Path()] = file
+		}
+	}
+
+	return load(r)
+}
+
+func load(r *reader) (*lib.ServerConfig, error) {
+	c := &lib.ServerConfig{}
+
+	readKubernetes(r, c)
+	c.StagingBackend = r.String("staging-backend")
+	if c.StagingBackend == "" {
+		c.StagingBackend = lib.StagingBackendKubernetes
+	}
+	c.LocalStagingDir = r.String("local-staging-dir")
+	if c.LocalStagingDir == "" {
+		c.LocalStagingDir = filepath.Join(os.TempDir(), "stager-stagings")
+	}
+	c.LocalStagingCommand = r.String("local-staging-command")
+	c.LogLevel = r.String("log-level")
+	c.Listen = r.String("listen")
+	c.Port = r.Int("port")
+	c.AdvertiseAddress = r.String("advertise-address")
+	c.StagingImage = r.String("staging-image")
+	c.StagingStopGracePeriodSeconds = int64(r.Duration("stage-stop-grace").Seconds())
+	c.SkipCertVerification = r.Bool("skip-cert-verify")
+	c.AppLifecycleURL = r.String("app-lifecycle-url")
+	c.CustomImageCommand = r.String("custom-image-command")
+	c.CCBaseURL = r.String("cc-baseurl")
+	c.CCUsername, c.CCUsernameFile = r.Secret("cc-username")
+	c.CCPassword, c.CCPasswordFile = r.Secret("cc-password")
+	c.ConsulCluster = r.String("consul-cluster")
+	c.Discovery = r.String("discovery")
+	if c.Discovery == "" {
+		c.Discovery = discovery.BackendConsul
+	}
+	c.DiscoveryServiceName = r.String("discovery-service-name")
+	c.DiscoveryServiceTags = r.StringSlice("discovery-service-tags")
+	c.DiscoveryNamespace = r.String("discovery-namespace")
+	c.LeaderElection = r.Bool("leader-election")
+	c.LeaderLockTTL = r.Duration("leader-lock-ttl")
+	c.LeaderLockRetryInterval = r.Duration("leader-lock-retry-interval")
+	c.MetronAddress = r.String("metron-address")
+	c.ReadinessCheckCC = r.Bool("readiness-check-cc")
+	c.TLSCertFile = r.String("tls-cert-file")
+	c.TLSKeyFile = r.String("tls-key-file")
+	c.TLSClientCAFile = r.String("tls-client-ca-file")
+	c.StagingClientCACertFile = r.String("staging-client-ca-cert-file")
+	c.StagingClientCAKeyFile = r.String("staging-client-ca-key-file")
+	c.StagingServerCAFile = r.String("staging-server-ca-file")
+	c.LogSinks = r.StringSlice("log-sinks")
+	c.LogFile = r.String("log-file")
+	c.LogFileMaxSizeMB = r.Int("log-file-max-size")
+	c.LogFileMaxBackups = r.Int("log-file-max-backups")
+	c.SyslogAddress = r.String("syslog-address")
+	c.SyslogTag = r.String("syslog-tag")
+	c.LogRedactEnvPatterns = r.StringSlice("log-redact-env-patterns")
+	c.DebugAddress = r.String("debug-address")
+	c.AuditLogFile = r.String("audit-log-file")
+	c.AuditLogHashChain = r.Bool("audit-log-hash-chain")
+	c.AuthBasic = r.Bool("auth-basic")
+	c.AuthJWKSURL = r.String("auth-jwks-url")
+	c.AuthJWKSCAFile = r.String("auth-jwks-ca-file")
+	c.AuthJWTIssuer = r.String("auth-jwt-issuer")
+	c.AuthJWTAudience = r.String("auth-jwt-audience")
+	c.AuthJWTCCScope = r.String("auth-jwt-cc-scope")
+	c.AuthCCCertSubject = r.String("auth-cc-cert-subject")
+	c.ConfigReloadInterval = r.Duration("config-reload-interval")
+
+	errs := append(r.errs, Validate(c)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return c, nil
+}
+
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// EnvPrefix starts the names of the environment variables settings are read
+// from.
+const EnvPrefix = "STAGER"
+
+// BindEnv makes v read every setting from an environment variable named
+// after it, STAGER_CC_PASSWORD for cc-password. They take precedence over
+// the config file but not over flags.
+func BindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+}
+
+// LoadKubernetes reads and validates only the settings for connecting to
+// Kubernetes and finding the stagings of a stager, for the commands that
+// work on stagings without running a stager.
+func LoadKubernetes(v *viper.Viper) (*lib.ServerConfig, error) {
+	r := &reader{viper: v}
+	c := &lib.ServerConfig{}
+
+	readKubernetes(r, c)
+
+	validator := &validator{}
+	validateKubernetes(validator, c)
+
+	errs := append(r.errs, validator.errs...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return c, nil
+}
+
+func readKubernetes(r *reader, c *lib.ServerConfig) {
+	c.StagerId = r.String("id")
+	c.K8SNamespace = r.String("k8s-namespace")
+	c.K8SConnection = r.String("k8s-connection")
+	c.K8SAPIEndpoint = r.String("k8s-endpoint")
+	c.KBSClientCertFile = r.String("k8s-client-cert")
+	c.K8SClientKeyFile = r.String("k8s-client-key")
+	c.K8SCACertFile = r.String("k8s-cacert")
+	c.Kubeconfig = r.String("kubeconfig")
+	c.KubeconfigContext = r.String("kubeconfig-context")
+	c.K8SQPS = float32(r.Float("k8s-qps"))
+	c.K8SBurst = r.Int("k8s-burst")
+	c.K8SRequestTimeout = r.Duration("k8s-request-timeout")
+}
+
+func validateKubernetes(v *validator, c *lib.ServerConfig) {
+	v.require(c.StagerId != "", "id is required")
+	v.require(c.K8SNamespace != "", "k8s-namespace is required")
+
+	switch c.K8SConnection {
+	case "", k8s.ConnectionExplicit:
+		v.require(c.K8SAPIEndpoint != "", "k8s-endpoint is required by the %s connection", k8s.ConnectionExplicit)
+		v.pair("k8s-client-cert", c.KBSClientCertFile, "k8s-client-key", c.K8SClientKeyFile)
+	case k8s.ConnectionInCluster:
+	case k8s.ConnectionKubeconfig:
+		v.require(c.Kubeconfig != "", "kubeconfig is required by the %s connection", k8s.ConnectionKubeconfig)
+	default:
+		v.add("k8s-connection: unknown connection %q, expected %s, %s or %s",
+			c.K8SConnection, k8s.ConnectionExplicit, k8s.ConnectionInCluster, k8s.ConnectionKubeconfig)
+	}
+	v.require(c.K8SQPS >= 0, "k8s-qps must not be negative")
+	v.require(c.K8SBurst >= 0, "k8s-burst must not be negative")
+	v.require(c.K8SRequestTimeout >= 0, "k8s-request-timeout must not be negative")
+}
+
+// Validate checks the values of a configuration and how they fit together.
+func Validate(c *lib.ServerConfig) Errors {
+	v := &validator{}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		v.add("log-level: %s", err)
+	}
+	for _, sink := range c.LogSinks {
+		switch sink {
+		case logger.StdoutSink, logger.SyslogSink:
+		case logger.FileSink:
+			v.require(c.LogFile != "", "log-file is required by the %s log sink", logger.FileSink)
+		default:
+			v.add("log-sinks: unknown log sink %q, expected %s, %s or %s", sink, logger.StdoutSink, logger.FileSink, logger.SyslogSink)
+		}
+	}
+	v.require(c.LogFileMaxSizeMB >= 0, "log-file-max-size must not be negative")
+	v.require(c.LogFileMaxBackups >= 0, "log-file-max-backups must not be negative")
+	if _, err := logger.NewRedactor(c.LogRedactEnvPatterns); err != nil {
+		v.add("log-redact-env-patterns: %s", err)
+	}
+
+	v.require(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535")
+	v.require(c.AdvertiseAddress != "", "advertise-address is required, staging pods and Consul reach the stager on it")
+	v.require(c.StagingStopGracePeriodSeconds >= 0, "stage-stop-grace must not be negative")
+	v.url("app-lifecycle-url", c.AppLifecycleURL, true)
+
+	switch c.StagingBackend {
+	case lib.StagingBackendKubernetes:
+		v.require(c.StagingImage != "", "staging-image is required")
+		validateKubernetes(v, c)
+	case lib.StagingBackendLocal:
+		// Stagings are still named after the stager and its space
+		v.require(c.StagerId != "", "id is required")
+		v.require(c.K8SNamespace != "", "k8s-namespace is required")
+		v.require(c.LocalStagingCommand != "" || c.CustomImageCommand != "",
+			"local-staging-command or custom-image-command is required by the %s staging backend", lib.StagingBackendLocal)
+		v.require(c.Discovery != discovery.BackendKubernetes,
+			"the %s discovery requires the %s staging backend", discovery.BackendKubernetes, lib.StagingBackendKubernetes)
+	default:
+		v.add("staging-backend: unknown staging backend %q, expected %s or %s",
+			c.StagingBackend, lib.StagingBackendKubernetes, lib.StagingBackendLocal)
+	}
+
+	v.url("cc-baseurl", c.CCBaseURL, true)
+	v.require(c.CCUsername != "", "cc-username or cc-username-file is required")
+	v.require(c.CCPassword != "", "cc-password or cc-password-file is required")
+
+	switch c.Discovery {
+	case discovery.BackendConsul:
+		v.require(c.DiscoveryServiceName != "", "discovery-service-name is required by the %s discovery", discovery.BackendConsul)
+	case discovery.BackendKubernetes:
+		v.require(dnsLabel.MatchString(c.DiscoveryServiceName),
+			"discovery-service-name must be a lower case DNS label for the %s discovery", discovery.BackendKubernetes)
+		v.require(net.ParseIP(c.AdvertiseAddress) != nil,
+			"advertise-address must be an IP address for the %s discovery", discovery.BackendKubernetes)
+	case discovery.BackendNone:
+	default:
+		v.add("discovery: unknown discovery %q, expected %s, %s or %s",
+			c.Discovery, discovery.BackendConsul, discovery.BackendKubernetes, discovery.BackendNone)
+	}
+
+	// Only discovery through Consul and leader election use Consul
+	usesConsul := c.Discovery == discovery.BackendConsul || c.LeaderElection
+	v.url("consul-cluster", c.ConsulCluster, usesConsul)
+	if c.LeaderElection {
+		v.require(c.LeaderLockTTL > 0, "leader-lock-ttl must be positive")
+		v.require(c.LeaderLockRetryInterval > 0, "leader-lock-retry-interval must be positive")
+	}
+
+	v.hostPort("metron-address", c.MetronAddress)
+	v.hostPort("debug-address", c.DebugAddress)
+
+	v.pair("tls-cert-file", c.TLSCertFile, "tls-key-file", c.TLSKeyFile)
+	v.pair("staging-client-ca-cert-file", c.StagingClientCACertFile, "staging-client-ca-key-file", c.StagingClientCAKeyFile)
+	if c.TLSCertFile == "" {
+		v.require(c.TLSClientCAFile == "", "tls-client-ca-file requires tls-cert-file")
+		v.require(c.StagingServerCAFile == "", "staging-server-ca-file requires tls-cert-file")
+	}
+	// The certificates staging pods are issued are verified as client
+	// certificates first
+	v.require(c.StagingClientCACertFile == "" || c.TLSClientCAFile != "", "staging-client-ca-cert-file requires tls-client-ca-file")
+
+	v.url("auth-jwks-url", c.AuthJWKSURL, false)
+	if c.AuthJWKSURL != "" {
+		v.require(c.AuthJWTCCScope != "", "auth-jwks-url needs auth-jwt-cc-scope")
+	}
+	v.require(c.AuthJWKSCAFile == "" || c.AuthJWKSURL != "", "auth-jwks-ca-file requires auth-jwks-url")
+	if c.AuthCCCertSubject != "" {
+		v.require(c.TLSClientCAFile != "", "auth-cc-cert-subject requires tls-client-ca-file")
+	}
+
+	v.require(c.AuditLogFile != "" || !c.AuditLogHashChain, "audit-log-hash-chain requires audit-log-file")
+
+	v.require(c.ConfigReloadInterval >= 0, "config-reload-interval must not be negative")
+
+	return v.errs
+}
+
+// reader reads typed values from viper, collecting the values that can't
+// be converted instead of silently turning them into zero values.
+type reader struct {
+	viper *viper.Viper
+	errs  Errors
+
+	// secretFiles are read from instead of opening their paths again
+	secretFiles map[string]*secret.File
+}
+
+func (r *reader) fail(key string, value interface{}, kind string) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %q is not a valid %s", key, fmt.Sprint(value), kind))
+}
+
+func (r *reader) String(key string) string {
+	return strings.TrimSpace(cast.ToString(r.viper.Get(key)))
+}
+
+func (r *reader) Int(key string) int {
+	value := r.viper.Get(key)
+	if value == nil {
+		return 0
+	}
+
+	result, err := cast.ToIntE(value)
+	if err != nil {
+		r.fail(key, value, "integer")
+	}
+
+	return result
+}
+
+func (r *reader) Float(key string) float64 {
+	value := r.viper.Get(key)
+	if value == nil {
+		return 0
+	}
+
+	result, err := cast.ToFloat64E(value)
+	if err != nil {
+		r.fail(key, value, "number")
+	}
+
+	return result
+}
+
+func (r *reader) Bool(key string) bool {
+	value := r.viper.Get(key)
+	if value == nil {
+		return false
+	}
+
+	result, err := cast.ToBoolE(value)
+	if err != nil {
+		r.fail(key, value, "boolean")
+	}
+
+	return result
+}
+
+func (r *reader) Duration(key string) time.Duration {
+	value := r.viper.Get(key)
+	if value == nil {
+		return 0
+	}
+
+	// cast turns anything it can't parse into 0
+	if s, ok := value.(string); ok {
+		result, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			r.fail(key, value, "duration")
+		}
+
+		return result
+	}
+
+	result, err := cast.ToDurationE(value)
+	if err != nil {
+		r.fail(key, value, "duration")
+	}
+
+	return result
+}
+
+// Secret reads a setting that can also be kept in the file named by its
+// -file variant. Only one of them may be set.
+func (r *reader) Secret(key string) (string, *secret.File) {
+	value := r.String(key)
+	path := r.String(key + "-file")
+	if path == "" {
+		return value, nil
+	}
+
+	if value != "" {
+		r.errs = append(r.errs, fmt.Errorf("%s and %s-file must not both be set", key, key))
+		return value, nil
+	}
+
+	if file, ok := r.secretFiles[path]; ok {
+		value, _ = file.Value()
+		return value, file
+	}
+
+	file, err := secret.NewFile(path)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s-file: %s", key, err))
+		return "", nil
+	}
+
+	value, _ = file.Value()
+	return value, file
+}
+
+// StringSlice reads a list from a config file, or from a flag or environment
+// variable holding comma separated values. Flags come as "[a,b]".
+func (r *reader) StringSlice(key string) []string {
+	value := r.viper.Get(key)
+
+	s, ok := value.(string)
+	if !ok {
+		return cast.ToStringSlice(value)
+	}
+
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+
+	if s == "" {
+		return []string{}
+	}
+
+	// Same format as the pflag string slices
+	values, err := csv.NewReader(strings.NewReader(s)).Read()
+	if err != nil {
+		r.fail(key, value, "comma separated list")
+		return nil
+	}
+
+	for idx := range values {
+		values[idx] = strings.TrimSpace(values[idx])
+	}
+
+	return values
+}
+
+type validator struct {
+	errs Errors
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) require(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) url(key, value string, required bool) {
+	if value == "" {
+		v.require(!required, "%s is required", key)
+		return
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.add("%s must be an absolute http or https URL", key)
+	}
+}
+
+func (v *validator) hostPort(key, value string) {
+	if value == "" {
+		return
+	}
+
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		v.add("%s must be a host:port address: %s", key, err)
+	}
+}
+
+// Both or neither of a certificate and its key have to be set
+func (v *validator) pair(key, value, otherKey, otherValue string) {
+	if (value == "") != (otherValue == "") {
+		v.add("%s and %s must be set together", key, otherKey)
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Masked replaces secrets in the effective configuration.
+const Masked = "********"
+
+// secretKeys are the settings whose values are never shown. Each of them
+// can also be read from a file with the -file variant of the setting, which
+// keeps it off the command line.
+var secretKeys = map[string]bool{
+	"cc-password": true,
+}
+
+// IsSecret tells whether the value of a setting must not be shown.
+func IsSecret(key string) bool {
+	return secretKeys[key]
+}
+
+// Setting is the effective value of a configuration setting.
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Effective returns the values v resolves for the flags, from the flags
+// themselves, the environment, the config file or the defaults. Secrets and
+// passwords in URLs are masked.
+func Effective(v *viper.Viper, flags *pflag.FlagSet) []Setting {
+	r := &reader{viper: v}
+	settings := []Setting{}
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		var value string
+		if flag.Value.Type() == "stringSlice" {
+			value = strings.Join(r.StringSlice(flag.Name), ",")
+		} else {
+			value = r.String(flag.Name)
+		}
+
+		if IsSecret(flag.Name) && value != "" {
+			value = Masked
+		} else {
+			value = maskURLPassword(value)
+		}
+
+		settings = append(settings, Setting{Key: flag.Name, Value: value})
+	})
+
+	return settings
+}
+
+func maskURLPassword(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+
+	if _, hasPassword := parsed.User.Password(); !hasPassword {
+		return value
+	}
+
+	// Replaced in place, the URL encoding would escape the mask
+	return strings.Replace(value, parsed.User.String()+"@", url.User(parsed.User.Username()).String()+":"+Masked+"@", 1)
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/logger"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// reloadableKeys are the settings a running stager picks up from a changed
+// config file.
+var reloadableKeys = map[string]bool{
+	"staging-image":        true,
+	"app-lifecycle-url":    true,
+	"custom-image-command": true,
+	"stage-stop-grace":     true,
+	"log-level":            true,
+}
+
+// Reloader polls the config file and applies changes to the staging settings
+// and the log level. The file can be a mounted ConfigMap: polling follows
+// the symlinks Kubernetes swaps when it updates one. Changes are validated
+// like at startup and ignored if they are invalid.
+type Reloader struct {
+	viper        *viper.Viper
+	flags        *pflag.FlagSet
+	serverConfig *lib.ServerConfig
+	logLevelSink *lager.ReconfigurableSink
+	pollInterval time.Duration
+	logger       lager.Logger
+
+	modTime  time.Time
+	settings map[string]string
+}
+
+// NewReloader watches the config file v was loaded from. flags are the
+// settings whose changes are reported.
+func NewReloader(
+	v *viper.Viper,
+	flags *pflag.FlagSet,
+	serverConfig *lib.ServerConfig,
+	logLevelSink *lager.ReconfigurableSink,
+	pollInterval time.Duration,
+	logger lager.Logger,
+) *Reloader {
+	r := &Reloader{
+		viper:        v,
+		flags:        flags,
+		serverConfig: serverConfig,
+		logLevelSink: logLevelSink,
+		pollInterval: pollInterval,
+		logger:       logger.Session("config-reloader", lager.Data{"ConfigFile": v.ConfigFileUsed()}),
+	}
+
+	if info, err := os.Stat(v.ConfigFileUsed()); err == nil {
+		r.modTime = info.ModTime()
+	}
+	r.settings = r.effectiveSettings()
+
+	return r
+}
+
+func (r *Reloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reloadIfChanged()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (r *Reloader) reloadIfChanged() {
+	info, err := os.Stat(r.viper.ConfigFileUsed())
+	if err != nil {
+		r.logger.Error("Error checking config file.", err)
+		return
+	}
+
+	if info.ModTime().Equal(r.modTime) {
+		return
+	}
+
+	// A file that is still being written fails to parse and is read again
+	// on the next poll
+	if err := r.viper.ReadInConfig(); err != nil {
+		r.logger.Error("Error reading changed config file.", err)
+		return
+	}
+	r.modTime = info.ModTime()
+
+	r.Reload()
+}
+
+// Reload applies the configuration v currently resolves.
+func (r *Reloader) Reload() {
+	config, err := Reload(r.viper, r.serverConfig)
+	if err != nil {
+		r.logger.Error("Ignoring invalid configuration change.", err)
+		return
+	}
+
+	settings := r.effectiveSettings()
+
+	changed := []string{}
+	restartRequired := []string{}
+	for key, value := range settings {
+		if r.settings[key] == value {
+			continue
+		}
+
+		if reloadableKeys[key] {
+			changed = append(changed, key)
+		} else {
+			restartRequired = append(restartRequired, key)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(restartRequired)
+
+	if len(changed) > 0 {
+		staging := config.Staging()
+		r.serverConfig.SetStaging(staging)
+
+		// Leaves a level set through the debug server alone unless the file
+		// changes it
+		if r.settings["log-level"] != settings["log-level"] {
+			if level, err := logger.ParseLevel(config.LogLevel); err == nil {
+				r.logLevelSink.SetMinLevel(level)
+			}
+		}
+
+		data := lager.Data{
+			"Changed":            changed,
+			"StagingImage":       staging.StagingImage,
+			"AppLifecycleURL":    staging.AppLifecycleURL,
+			"CustomImageCommand": staging.CustomImageCommand,
+			"StopGracePeriod":    staging.StagingStopGracePeriodSeconds,
+			"LogLevel":           config.LogLevel,
+		}
+		r.logger.Info("Applied configuration change to new stagings.", data)
+	}
+
+	if len(restartRequired) > 0 {
+		r.logger.Info("Configuration change takes effect after a restart.", lager.Data{"Changed": restartRequired})
+	}
+
+	r.settings = settings
+}
+
+func (r *Reloader) effectiveSettings() map[string]string {
+	settings := map[string]string{}
+	for _, setting := range Effective(r.viper, r.flags) {
+		settings[setting.Key] = setting.Value
+	}
+
+	return settings
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+const reloaderConfig = `
+port: 8080
+advertise-address: 10.0.0.1
+id: stager-0
+staging-image: cffurnace/stager
+k8s-endpoint: https://k8s.example.com
+k8s-namespace: furnace-staging
+stage-stop-grace: 60s
+app-lifecycle-url: https://blobstore.example.com/buildpack_app_lifecycle.tgz
+cc-baseurl: https://cc.example.com
+cc-username: internal_user
+cc-password: internal_password
+consul-cluster: http://127.0.0.1:8500
+discovery-service-name: k8s-stager
+log-level: info
+log-sinks: [stdout]
+`
+
+func newTestReloader(t *testing.T, configFile string) (*Reloader, *viper.Viper) {
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig, err := Load(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	for _, key := range []string{"staging-image", "app-lifecycle-url", "custom-image-command", "stage-stop-grace", "log-level", "port"} {
+		flags.String(key, "", "")
+	}
+
+	logLevelSink := lager.NewReconfigurableSink(lager.NewWriterSink(ioutil.Discard, lager.DEBUG), lager.INFO)
+
+	return NewReloader(v, flags, serverConfig, logLevelSink, time.Hour, lager.NewLogger("test")), v
+}
+
+func TestReloaderAppliesChangedStagingSettings(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	assert.NoError(ioutil.WriteFile(configFile, []byte(reloaderConfig), 0600))
+	reloader, _ := newTestReloader(t, configFile)
+
+	// Act
+	changedConfig := reloaderConfig + "staging-image: cffurnace/stager:v2\nstage-stop-grace: 30s\nlog-level: debug\n"
+	assert.NoError(ioutil.WriteFile(configFile, []byte(changedConfig), 0600))
+	reloader.modTime = time.Time{}
+	reloader.reloadIfChanged()
+
+	// Assert
+	staging := reloader.serverConfig.Staging()
+	assert.Equal("cffurnace/stager:v2", staging.StagingImage)
+	assert.Equal(int64(30), staging.StagingStopGracePeriodSeconds)
+	assert.Equal("cffurnace/stager", reloader.serverConfig.StagingImage)
+	assert.Equal(lager.DEBUG, reloader.logLevelSink.GetMinLevel())
+}
+
+func TestReloaderIgnoresInvalidChange(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	assert.NoError(ioutil.WriteFile(configFile, []byte(reloaderConfig), 0600))
+	reloader, v := newTestReloader(t, configFile)
+
+	// Act
+	v.Set("staging-image", "cffurnace/stager:v2")
+	v.Set("app-lifecycle-url", "/relative/lifecycle.tgz")
+	reloader.Reload()
+
+	// Assert
+	assert.Equal("cffurnace/stager", reloader.serverConfig.Staging().StagingImage)
+	assert.Equal(lager.INFO, reloader.logLevelSink.GetMinLevel())
+}
+
+func TestReloaderAppliesChangedStopGracePeriodLimit(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	assert.NoError(ioutil.WriteFile(configFile, []byte(reloaderConfig), 0600))
+	reloader, v := newTestReloader(t, configFile)
+
+	// Act
+	v.Set("stage-stop-grace", "2m")
+	reloader.Reload()
+	changed := reloader.serverConfig.Staging().StagingStopGracePeriodSeconds
+	v.Set("stage-stop-grace", "-1s")
+	reloader.Reload()
+
+	// Assert
+	assert.Equal(int64(120), changed)
+	assert.Equal(int64(120), reloader.serverConfig.Staging().StagingStopGracePeriodSeconds)
+	assert.Equal(int64(60), reloader.serverConfig.StagingStopGracePeriodSeconds)
+}
+
+func TestReloaderKeepsSecretFilesWhileRotated(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	passwordFile := filepath.Join(dir, "cc-password")
+	assert.NoError(ioutil.WriteFile(passwordFile, []byte("internal_password\n"), 0600))
+	configFile := filepath.Join(dir, "config.yaml")
+	config := strings.Replace(reloaderConfig, "cc-password: internal_password", "cc-password-file: "+passwordFile, 1)
+	assert.NoError(ioutil.WriteFile(configFile, []byte(config), 0600))
+	reloader, v := newTestReloader(t, configFile)
+	passwordReader := reloader.serverConfig.CCPasswordFile
+
+	// Act
+	// The secret is being rewritten while the config changes
+	assert.NoError(ioutil.WriteFile(passwordFile, []byte{}, 0600))
+	assert.NoError(os.Chtimes(passwordFile, time.Now(), time.Now().Add(time.Minute)))
+	v.Set("staging-image", "cffurnace/stager:v2")
+	reloader.Reload()
+	_, password, credentialsErr := reloader.serverConfig.CCCredentials()
+
+	// Assert
+	assert.Equal("cffurnace/stager:v2", reloader.serverConfig.Staging().StagingImage)
+	assert.True(passwordReader == reloader.serverConfig.CCPasswordFile)
+	assert.Error(credentialsErr)
+	assert.Equal("internal_password", password)
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// Ways other components find the stager
+const (
+	// BackendConsul registers the stager as a Consul service.
+	BackendConsul = "consul"
+	// BackendKubernetes lists the stager in the Endpoints of a Kubernetes
+	// Service.
+	BackendKubernetes = "kubernetes"
+	// BackendNone doesn't register the stager anywhere, it is found through
+	// its advertise address.
+	BackendNone = "none"
+)
+
+// ConsulDeregistration runs a Consul registration runner and removes the
+// service from Consul when it is signalled, instead of waiting for the
+// health check of the stopped stager to fail.
+type ConsulDeregistration struct {
+	registration ifrit.Runner
+	consulClient consuladapter.Client
+	serviceID    string
+	logger       lager.Logger
+}
+
+// NewConsulDeregistration deregisters serviceID once registration stops.
+func NewConsulDeregistration(registration ifrit.Runner, consulClient consuladapter.Client, serviceID string, logger lager.Logger) *ConsulDeregistration {
+	return &ConsulDeregistration{
+		registration: registration,
+		consulClient: consulClient,
+		serviceID:    serviceID,
+		logger:       logger.Session("consul-deregistration", lager.Data{"ServiceID": serviceID}),
+	}
+}
+
+func (d *ConsulDeregistration) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	process := ifrit.Background(d.registration)
+	registered := process.Ready()
+
+	for {
+		select {
+		case <-registered:
+			close(ready)
+			registered = nil
+		case err := <-process.Wait():
+			return err
+		case signal := <-signals:
+			process.Signal(signal)
+			err := <-process.Wait()
+
+			if deregisterErr := d.consulClient.Agent().ServiceDeregister(d.serviceID); deregisterErr != nil {
+				d.logger.Error("Error deregistering service.", deregisterErr)
+			} else {
+				d.logger.Info("Deregistered service.")
+			}
+
+			return err
+		}
+	}
+}
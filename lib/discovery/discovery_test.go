@@ -0,0 +1,52 @@
+package discovery
+
+import (
+	"os"
+	"testing"
+
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+	"github.com/tedsuo/ifrit"
+)
+
+type fakeConsulClient struct {
+	consuladapter.Client
+	agent *fakeConsulAgent
+}
+
+func (c *fakeConsulClient) Agent() consuladapter.Agent {
+	return c.agent
+}
+
+type fakeConsulAgent struct {
+	consuladapter.Agent
+	deregistered []string
+}
+
+func (a *fakeConsulAgent) ServiceDeregister(serviceID string) error {
+	a.deregistered = append(a.deregistered, serviceID)
+	return nil
+}
+
+func TestConsulDeregistrationDeregistersOnSignal(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	agent := &fakeConsulAgent{}
+	registration := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		close(ready)
+		<-signals
+		return nil
+	})
+
+	deregistration := NewConsulDeregistration(registration, &fakeConsulClient{agent: agent}, "k8s-stager-0", lager.NewLogger("test"))
+	process := ifrit.Invoke(deregistration)
+
+	// Act
+	process.Signal(os.Interrupt)
+	err := <-process.Wait()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"k8s-stager-0"}, agent.deregistered)
+}
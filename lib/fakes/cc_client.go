@@ -0,0 +1,73 @@
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/stager/cc_client"
+)
+
+// CcClient is a cc_client.CcClient recording the staging results delivered
+// to it. Errors are returned one per call, in order, before deliveries
+// succeed.
+type CcClient struct {
+	lock       sync.Mutex
+	errors     []error
+	deliveries []Delivery
+	delivered  chan struct{}
+}
+
+// Delivery is a staging result delivered to CC.
+type Delivery struct {
+	StagingGuid        string
+	CompletionCallback string
+	Payload            []byte
+}
+
+var _ cc_client.CcClient = &CcClient{}
+
+// NewCcClient creates a client failing its first calls with errors.
+func NewCcClient(errors ...error) *CcClient {
+	return &CcClient{
+		errors:    errors,
+		delivered: make(chan struct{}, 100),
+	}
+}
+
+func (c *CcClient) StagingComplete(stagingGuid string, completionCallback string, payload []byte, logger lager.Logger) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.errors) > 0 {
+		err := c.errors[0]
+		c.errors = c.errors[1:]
+		return err
+	}
+
+	c.deliveries = append(c.deliveries, Delivery{
+		StagingGuid:        stagingGuid,
+		CompletionCallback: completionCallback,
+		Payload:            payload,
+	})
+
+	select {
+	case c.delivered <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Deliveries returns the staging results that were delivered, in order.
+func (c *CcClient) Deliveries() []Delivery {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]Delivery{}, c.deliveries...)
+}
+
+// Delivered is sent on for every staging result that is delivered, for
+// waiting on deliveries made in the background.
+func (c *CcClient) Delivered() <-chan struct{} {
+	return c.delivered
+}
@@ -0,0 +1,265 @@
+// Package fakes has in-memory stand-ins for the clients the stager calls, for
+// tests that exercise the API handlers without Kubernetes or CC.
+package fakes
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+// StagingClient is a K8SStagingClient keeping the namespaces, secrets and
+// jobs it is asked to create in memory. A call fails with the error set for it, if any.
+type StagingClient struct {
+	StagerId string
+
+	CreateStagingNamespaceErr error
+	GetStagingNamespaceErr    error
+	RemoveStagingNamespaceErr error
+	StartStagingErr           error
+	GetStagingTaskErr         error
+	ListStagingTasksErr       error
+	StopStagingErr            error
+	StreamStagingLogsErr      error
+	RecordStagingEventErr     error
+
+	lock       sync.Mutex
+	namespaces map[string]*api.Namespace
+	jobs       map[string]*batch.Job
+	secrets    map[string]*api.Secret
+	logs       map[string]string
+	stopped    []string
+	events     []Event
+}
+
+// Event is an event recorded on a staging.
+type Event struct {
+	StagingId string
+	Type      string
+	Reason    string
+	Message   string
+}
+
+// NewStagingClient creates a client without namespaces, secrets or jobs.
+func NewStagingClient(stagerId string) *StagingClient {
+	return &StagingClient{
+		StagerId:   stagerId,
+		namespaces: map[string]*api.Namespace{},
+		jobs:       map[string]*batch.Job{},
+		secrets:    map[string]*api.Secret{},
+		logs:       map[string]string{},
+	}
+}
+
+func (c *StagingClient) CreateStagingNamespace(organization, space string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.CreateStagingNamespaceErr != nil {
+		return c.CreateStagingNamespaceErr
+	}
+
+	c.namespaces[space] = k8s.NewStagingNamespace(organization, space, c.StagerId)
+	return nil
+}
+
+func (c *StagingClient) GetStagingNamespace(space string) (*api.Namespace, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.GetStagingNamespaceErr != nil {
+		return nil, false, c.GetStagingNamespaceErr
+	}
+
+	namespace, exists := c.namespaces[space]
+	return namespace, exists, nil
+}
+
+func (c *StagingClient) RemoveStagingNamespace(space string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.RemoveStagingNamespaceErr != nil {
+		return c.RemoveStagingNamespaceErr
+	}
+
+	delete(c.namespaces, space)
+	for key := range c.jobs {
+		if strings.HasPrefix(key, space+"/") {
+			delete(c.jobs, key)
+			delete(c.secrets, key)
+		}
+	}
+	return nil
+}
+
+// StartStaging creates the secret and job the Kubernetes client would
+// create, without running the job.
+func (c *StagingClient) StartStaging(stagingData *k8s.StagingInfo, space string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.StartStagingErr != nil {
+		return c.StartStagingErr
+	}
+
+	if _, exists := c.jobs[jobKey(stagingData.Id, space)]; exists {
+		return k8s.ErrStagingTaskExists
+	}
+
+	secret, err := k8s.NewStagingSecret(stagingData, space, c.StagerId)
+	if err != nil {
+		return err
+	}
+
+	job, err := k8s.NewStagingJob(stagingData, space, c.StagerId)
+	if err != nil {
+		return err
+	}
+	job.CreationTimestamp = unversioned.Now()
+
+	if secret != nil {
+		c.secrets[jobKey(stagingData.Id, space)] = secret
+	}
+	c.jobs[jobKey(stagingData.Id, space)] = job
+	return nil
+}
+
+func (c *StagingClient) GetStagingTask(id, space string) (*batch.Job, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.GetStagingTaskErr != nil {
+		return nil, false, c.GetStagingTaskErr
+	}
+
+	job, exists := c.jobs[jobKey(id, space)]
+	return job, exists, nil
+}
+
+func (c *StagingClient) ListStagingTasks(space string) ([]*batch.Job, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.ListStagingTasksErr != nil {
+		return nil, c.ListStagingTasksErr
+	}
+
+	jobs := []*batch.Job{}
+	for key, job := range c.jobs {
+		if strings.HasPrefix(key, space+"/") {
+			jobs = append(jobs, job)
+		}
+	}
+
+	k8s.SortStagingJobs(jobs)
+	return jobs, nil
+}
+
+// StopStaging removes the job and secret of a staging. Stopping a staging without a job
+// succeeds, like deleting a job that is already gone.
+func (c *StagingClient) StopStaging(id, space string, gracePeriod int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.StopStagingErr != nil {
+		return c.StopStagingErr
+	}
+
+	delete(c.jobs, jobKey(id, space))
+	delete(c.secrets, jobKey(id, space))
+	c.stopped = append(c.stopped, id)
+	return nil
+}
+
+// StreamStagingLogs returns the logs set with SetLogs for a staging that has
+// a job.
+func (c *StagingClient) StreamStagingLogs(id, space string, options *k8s.LogOptions) (io.ReadCloser, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.StreamStagingLogsErr != nil {
+		return nil, c.StreamStagingLogsErr
+	}
+
+	if _, exists := c.jobs[jobKey(id, space)]; !exists {
+		return nil, k8s.ErrStagingTaskNotFound
+	}
+
+	return ioutil.NopCloser(strings.NewReader(c.logs[id])), nil
+}
+
+func (c *StagingClient) RecordStagingEvent(id, space, eventType, reason, message string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.RecordStagingEventErr != nil {
+		return c.RecordStagingEventErr
+	}
+
+	c.events = append(c.events, Event{StagingId: id, Type: eventType, Reason: reason, Message: message})
+	return nil
+}
+
+// SetLogs sets the logs of a staging.
+func (c *StagingClient) SetLogs(id, logs string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.logs[id] = logs
+}
+
+// Namespaces returns the spaces staging namespaces were created for.
+func (c *StagingClient) Namespaces() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	spaces := []string{}
+	for space := range c.namespaces {
+		spaces = append(spaces, space)
+	}
+	return spaces
+}
+
+// Job returns the job of a staging, nil if there is none.
+func (c *StagingClient) Job(id, space string) *batch.Job {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.jobs[jobKey(id, space)]
+}
+
+// Secret returns the secret of a staging, nil if there is none.
+func (c *StagingClient) Secret(id, space string) *api.Secret {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.secrets[jobKey(id, space)]
+}
+
+// Stopped returns the ids of the stagings that were stopped, in order.
+func (c *StagingClient) Stopped() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]string{}, c.stopped...)
+}
+
+// Events returns the events that were recorded, in order.
+func (c *StagingClient) Events() []Event {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]Event{}, c.events...)
+}
+
+func jobKey(id, space string) string {
+	return space + "/" + id
+}
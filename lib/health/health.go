@@ -0,0 +1,128 @@
+package health
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Check is a named readiness condition. Func returns nil when it holds.
+type Check struct {
+	Name string
+	Func func() error
+}
+
+// LivenessHandler answers as long as the process can serve requests.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler runs all checks concurrently and answers 200 if they all
+// pass within timeout, 503 otherwise. The body reports each check's result.
+func ReadinessHandler(checks []Check, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := runChecks(checks, timeout)
+
+		status := http.StatusOK
+		for _, result := range results {
+			if result != "ok" {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(results)
+	})
+}
+
+// Ready runs all checks like ReadinessHandler and returns an error naming
+// the checks that failed.
+func Ready(checks []Check, timeout time.Duration) error {
+	results := runChecks(checks, timeout)
+
+	failed := []string{}
+	for _, check := range checks {
+		if result := results[check.Name]; result != "ok" {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, result))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("not ready: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func runChecks(checks []Check, timeout time.Duration) map[string]string {
+	type checkResult struct {
+		name string
+		err  error
+	}
+
+	resultChan := make(chan checkResult, len(checks))
+	for _, check := range checks {
+		go func(check Check) {
+			resultChan <- checkResult{name: check.Name, err: check.Func()}
+		}(check)
+	}
+
+	results := map[string]string{}
+	for _, check := range checks {
+		results[check.Name] = "timed out"
+	}
+
+	deadline := time.After(timeout)
+	for range checks {
+		select {
+		case result := <-resultChan:
+			if result.err != nil {
+				results[result.name] = result.err.Error()
+			} else {
+				results[result.name] = "ok"
+			}
+		case <-deadline:
+			return results
+		}
+	}
+
+	return results
+}
+
+// CCReachable checks that the Cloud Controller answers HTTP requests. Any
+// response that isn't a server error counts, since the stager doesn't
+// authenticate against the info endpoint.
+func CCReachable(baseURL string, skipCertVerify bool, timeout time.Duration) Check {
+	httpClient := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipCertVerify},
+		},
+	}
+
+	return Check{
+		Name: "cc",
+		Func: func() error {
+			response, err := httpClient.Get(strings.TrimRight(baseURL, "/") + "/v2/info")
+			if err != nil {
+				return err
+			}
+			response.Body.Close()
+
+			if response.StatusCode >= 500 {
+				return fmt.Errorf("CC responded with %d", response.StatusCode)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessHandlerPassesWhenAllChecksPass(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	handler := ReadinessHandler([]Check{
+		{Name: "k8s", Func: func() error { return nil }},
+	}, time.Second)
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	// Assert
+	assert.Equal(http.StatusOK, recorder.Code)
+}
+
+func TestReadinessHandlerReportsFailedAndSlowChecks(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	handler := ReadinessHandler([]Check{
+		{Name: "k8s", Func: func() error { return errors.New("connection refused") }},
+		{Name: "cc", Func: func() error { time.Sleep(time.Second); return nil }},
+	}, 50*time.Millisecond)
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	results := map[string]string{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &results)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal("connection refused", results["k8s"])
+	assert.Equal("timed out", results["cc"])
+}
+
+func TestCCReachableFailsOnServerErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	cc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v2/info", r.URL.Path)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer cc.Close()
+
+	// Act
+	err := CCReachable(cc.URL, false, time.Second).Func()
+
+	// Assert
+	assert.EqualError(err, "CC responded with 502")
+}
@@ -0,0 +1,469 @@
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/meta"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+const (
+	// StagerIdLabel marks the namespaces, jobs and pods owned by a stager.
+	StagerIdLabel = "stager-id"
+
+	cacheRelistInterval = 5 * time.Second
+)
+
+// ResourceEventHandler is notified when objects in the staging cache change.
+type ResourceEventHandler interface {
+	OnAdd(obj runtime.Object)
+	OnUpdate(oldObj, newObj runtime.Object)
+	OnDelete(obj runtime.Object)
+}
+
+// ResourceEventHandlerFuncs adapts plain functions to a ResourceEventHandler.
+// Any of the functions may be nil.
+type ResourceEventHandlerFuncs struct {
+	AddFunc    func(obj runtime.Object)
+	UpdateFunc func(oldObj, newObj runtime.Object)
+	DeleteFunc func(obj runtime.Object)
+}
+
+func (f ResourceEventHandlerFuncs) OnAdd(obj runtime.Object) {
+	if f.AddFunc != nil {
+		f.AddFunc(obj)
+	}
+}
+
+func (f ResourceEventHandlerFuncs) OnUpdate(oldObj, newObj runtime.Object) {
+	if f.UpdateFunc != nil {
+		f.UpdateFunc(oldObj, newObj)
+	}
+}
+
+func (f ResourceEventHandlerFuncs) OnDelete(obj runtime.Object) {
+	if f.DeleteFunc != nil {
+		f.DeleteFunc(obj)
+	}
+}
+
+type listFunc func(options api.ListOptions) ([]runtime.Object, string, error)
+type watchFunc func(options api.ListOptions) (watch.Interface, error)
+
+// informer keeps a local copy of one kind of object up to date by listing
+// and then watching it, and notifies handlers about changes.
+type informer struct {
+	kind     string
+	list     listFunc
+	watch    watchFunc
+	selector labels.Selector
+	logger   lager.Logger
+
+	lock     sync.RWMutex
+	items    map[string]runtime.Object
+	handlers []ResourceEventHandler
+	synced   bool
+}
+
+func newInformer(kind string, selector labels.Selector, list listFunc, watch watchFunc, logger lager.Logger) *informer {
+	return &informer{
+		kind:     kind,
+		list:     list,
+		watch:    watch,
+		selector: selector,
+		logger:   logger.Session("informer", lager.Data{"kind": kind}),
+		items:    map[string]runtime.Object{},
+	}
+}
+
+func (i *informer) addEventHandler(handler ResourceEventHandler) {
+	i.lock.Lock()
+	i.handlers = append(i.handlers, handler)
+	existing := make([]runtime.Object, 0, len(i.items))
+	for _, obj := range i.items {
+		existing = append(existing, obj)
+	}
+	i.lock.Unlock()
+
+	for _, obj := range existing {
+		handler.OnAdd(obj)
+	}
+}
+
+func (i *informer) get(namespace, name string) (runtime.Object, bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	obj, ok := i.items[cacheKey(namespace, name)]
+	return obj, ok
+}
+
+func (i *informer) listAll() []runtime.Object {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	result := make([]runtime.Object, 0, len(i.items))
+	for _, obj := range i.items {
+		result = append(result, obj)
+	}
+
+	return result
+}
+
+func (i *informer) hasSynced() bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return i.synced
+}
+
+// change describes one modification of the store: oldObj is nil for
+// additions and newObj is nil for deletions.
+type change struct {
+	oldObj runtime.Object
+	newObj runtime.Object
+}
+
+// replace swaps the whole content of the informer for a fresh list,
+// notifying handlers about the differences.
+func (i *informer) replace(objs []runtime.Object) {
+	changes := []change{}
+
+	i.lock.Lock()
+	old := i.items
+	i.items = map[string]runtime.Object{}
+
+	for _, obj := range objs {
+		key, err := objectKey(obj)
+		if err != nil {
+			i.logger.Error("Error getting object key.", err)
+			continue
+		}
+
+		i.items[key] = obj
+		changes = append(changes, change{oldObj: old[key], newObj: obj})
+		delete(old, key)
+	}
+
+	for _, obj := range old {
+		changes = append(changes, change{oldObj: obj})
+	}
+
+	i.synced = true
+	i.lock.Unlock()
+
+	i.notify(changes...)
+}
+
+func (i *informer) store(obj runtime.Object) {
+	key, err := objectKey(obj)
+	if err != nil {
+		i.logger.Error("Error getting object key.", err)
+		return
+	}
+
+	i.lock.Lock()
+	oldObj := i.items[key]
+	i.items[key] = obj
+	i.lock.Unlock()
+
+	i.notify(change{oldObj: oldObj, newObj: obj})
+}
+
+func (i *informer) remove(obj runtime.Object) {
+	key, err := objectKey(obj)
+	if err != nil {
+		i.logger.Error("Error getting object key.", err)
+		return
+	}
+
+	i.lock.Lock()
+	_, exists := i.items[key]
+	delete(i.items, key)
+	i.lock.Unlock()
+
+	if exists {
+		i.notify(change{oldObj: obj})
+	}
+}
+
+// notify dispatches changes to the handlers. It must be called without
+// holding the lock, so that handlers are free to read from the cache.
+func (i *informer) notify(changes ...change) {
+	i.lock.RLock()
+	handlers := i.handlers
+	i.lock.RUnlock()
+
+	for _, c := range changes {
+		for _, handler := range handlers {
+			switch {
+			case c.oldObj == nil:
+				handler.OnAdd(c.newObj)
+			case c.newObj == nil:
+				handler.OnDelete(c.oldObj)
+			default:
+				handler.OnUpdate(c.oldObj, c.newObj)
+			}
+		}
+	}
+}
+
+// listAndWatch lists all objects and then follows changes until the watch
+// ends, an error event arrives or stop is closed.
+func (i *informer) listAndWatch(stop <-chan struct{}) error {
+	objs, resourceVersion, err := i.list(api.ListOptions{LabelSelector: i.selector})
+	if err != nil {
+		return err
+	}
+
+	i.replace(objs)
+
+	watcher, err := i.watch(api.ListOptions{
+		LabelSelector:   i.selector,
+		ResourceVersion: resourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				i.store(event.Object)
+			case watch.Deleted:
+				i.remove(event.Object)
+			case watch.Error:
+				return fmt.Errorf("watch of %s failed: %v", i.kind, event.Object)
+			}
+		}
+	}
+}
+
+func (i *informer) run(stop <-chan struct{}) {
+	for {
+		err := i.listAndWatch(stop)
+		if err != nil {
+			i.logger.Error("Error watching objects. Relisting.", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(cacheRelistInterval):
+		}
+	}
+}
+
+// StagingCache watches the namespaces, jobs and pods belonging to a stager,
+// so that lookups don't need to hit the Kubernetes API.
+type StagingCache struct {
+	namespaces *informer
+	jobs       *informer
+	pods       *informer
+
+	logger lager.Logger
+}
+
+func NewStagingCache(k8sClient *client.Client, stagerId string, logger lager.Logger) *StagingCache {
+	selector := labels.SelectorFromSet(labels.Set{StagerIdLabel: stagerId})
+	logger = logger.Session("staging-cache")
+
+	return &StagingCache{
+		namespaces: newInformer(
+			"namespaces",
+			selector,
+			func(options api.ListOptions) ([]runtime.Object, string, error) {
+				list, err := k8sClient.Namespaces().List(options)
+				if err != nil {
+					return nil, "", err
+				}
+
+				objs := make([]runtime.Object, len(list.Items))
+				for idx := range list.Items {
+					objs[idx] = &list.Items[idx]
+				}
+
+				return objs, list.ResourceVersion, nil
+			},
+			func(options api.ListOptions) (watch.Interface, error) {
+				return k8sClient.Namespaces().Watch(options)
+			},
+			logger,
+		),
+		jobs: newInformer(
+			"jobs",
+			selector,
+			func(options api.ListOptions) ([]runtime.Object, string, error) {
+				list, err := k8sClient.BatchClient.Jobs(api.NamespaceAll).List(options)
+				if err != nil {
+					return nil, "", err
+				}
+
+				objs := make([]runtime.Object, len(list.Items))
+				for idx := range list.Items {
+					objs[idx] = &list.Items[idx]
+				}
+
+				return objs, list.ResourceVersion, nil
+			},
+			func(options api.ListOptions) (watch.Interface, error) {
+				return k8sClient.BatchClient.Jobs(api.NamespaceAll).Watch(options)
+			},
+			logger,
+		),
+		pods: newInformer(
+			"pods",
+			selector,
+			func(options api.ListOptions) ([]runtime.Object, string, error) {
+				list, err := k8sClient.Pods(api.NamespaceAll).List(options)
+				if err != nil {
+					return nil, "", err
+				}
+
+				objs := make([]runtime.Object, len(list.Items))
+				for idx := range list.Items {
+					objs[idx] = &list.Items[idx]
+				}
+
+				return objs, list.ResourceVersion, nil
+			},
+			func(options api.ListOptions) (watch.Interface, error) {
+				return k8sClient.Pods(api.NamespaceAll).Watch(options)
+			},
+			logger,
+		),
+
+		logger: logger,
+	}
+}
+
+// Run starts the informers and reports ready once all of them have
+// completed their initial list.
+func (c *StagingCache) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	c.logger.Info("starting")
+	defer c.logger.Info("finished")
+
+	stop := make(chan struct{})
+	informers := []*informer{c.namespaces, c.jobs, c.pods}
+
+	for _, i := range informers {
+		go i.run(stop)
+	}
+
+	syncTicker := time.NewTicker(100 * time.Millisecond)
+	defer syncTicker.Stop()
+
+	for ready != nil {
+		select {
+		case <-signals:
+			close(stop)
+			return nil
+		case <-syncTicker.C:
+			if c.HasSynced() {
+				c.logger.Info("synced")
+				close(ready)
+				ready = nil
+			}
+		}
+	}
+
+	<-signals
+	close(stop)
+	return nil
+}
+
+// HasSynced returns true once namespaces, jobs and pods have been listed.
+func (c *StagingCache) HasSynced() bool {
+	return c.namespaces.hasSynced() && c.jobs.hasSynced() && c.pods.hasSynced()
+}
+
+func (c *StagingCache) GetNamespace(name string) (*api.Namespace, bool) {
+	obj, ok := c.namespaces.get("", name)
+	if !ok {
+		return nil, false
+	}
+
+	return obj.(*api.Namespace), true
+}
+
+func (c *StagingCache) GetJob(namespace, name string) (*batch.Job, bool) {
+	obj, ok := c.jobs.get(namespace, name)
+	if !ok {
+		return nil, false
+	}
+
+	return obj.(*batch.Job), true
+}
+
+// ListJobs returns all cached staging jobs.
+func (c *StagingCache) ListJobs() []*batch.Job {
+	result := []*batch.Job{}
+
+	for _, obj := range c.jobs.listAll() {
+		result = append(result, obj.(*batch.Job))
+	}
+
+	return result
+}
+
+// ListPods returns the cached pods in a namespace matching a label selector.
+// api.NamespaceAll matches pods in every namespace.
+func (c *StagingCache) ListPods(namespace string, selector labels.Selector) []*api.Pod {
+	result := []*api.Pod{}
+
+	for _, obj := range c.pods.listAll() {
+		pod := obj.(*api.Pod)
+		if (namespace == api.NamespaceAll || pod.Namespace == namespace) && selector.Matches(labels.Set(pod.Labels)) {
+			result = append(result, pod)
+		}
+	}
+
+	return result
+}
+
+func (c *StagingCache) AddNamespaceEventHandler(handler ResourceEventHandler) {
+	c.namespaces.addEventHandler(handler)
+}
+
+func (c *StagingCache) AddJobEventHandler(handler ResourceEventHandler) {
+	c.jobs.addEventHandler(handler)
+}
+
+func (c *StagingCache) AddPodEventHandler(handler ResourceEventHandler) {
+	c.pods.addEventHandler(handler)
+}
+
+func objectKey(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+
+	return cacheKey(accessor.GetNamespace(), accessor.GetName()), nil
+}
+
+func cacheKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return namespace + "/" + name
+}
@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+type recordedEvent struct {
+	kind string
+	name string
+}
+
+func newTestInformer(initial []runtime.Object, watcher *watch.FakeWatcher) *informer {
+	return newInformer(
+		"pods",
+		labels.SelectorFromSet(labels.Set{StagerIdLabel: "stager-0"}),
+		func(options api.ListOptions) ([]runtime.Object, string, error) {
+			return initial, "1", nil
+		},
+		func(options api.ListOptions) (watch.Interface, error) {
+			return watcher, nil
+		},
+		lager.NewLogger("cache-test"),
+	)
+}
+
+func newTestPod(name string) *api.Pod {
+	return &api.Pod{ObjectMeta: api.ObjectMeta{Namespace: "cf-staging-space", Name: name}}
+}
+
+func recordEvents(i *informer) chan recordedEvent {
+	events := make(chan recordedEvent, 10)
+	name := func(obj runtime.Object) string { return obj.(*api.Pod).Name }
+
+	i.addEventHandler(ResourceEventHandlerFuncs{
+		AddFunc:    func(obj runtime.Object) { events <- recordedEvent{"add", name(obj)} },
+		UpdateFunc: func(oldObj, newObj runtime.Object) { events <- recordedEvent{"update", name(newObj)} },
+		DeleteFunc: func(obj runtime.Object) { events <- recordedEvent{"delete", name(obj)} },
+	})
+
+	return events
+}
+
+func nextEvent(t *testing.T, events chan recordedEvent) recordedEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for cache event")
+		return recordedEvent{}
+	}
+}
+
+func TestInformerListAndWatch(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	watcher := watch.NewFake()
+	informer := newTestInformer([]runtime.Object{newTestPod("listed")}, watcher)
+	events := recordEvents(informer)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// Act
+	go informer.listAndWatch(stop)
+	listed := nextEvent(t, events)
+
+	watcher.Add(newTestPod("added"))
+	added := nextEvent(t, events)
+
+	watcher.Modify(newTestPod("listed"))
+	updated := nextEvent(t, events)
+
+	watcher.Delete(newTestPod("added"))
+	deleted := nextEvent(t, events)
+
+	// Assert
+	assert.Equal(recordedEvent{"add", "listed"}, listed)
+	assert.Equal(recordedEvent{"add", "added"}, added)
+	assert.Equal(recordedEvent{"update", "listed"}, updated)
+	assert.Equal(recordedEvent{"delete", "added"}, deleted)
+	assert.True(informer.hasSynced())
+
+	_, exists := informer.get("cf-staging-space", "listed")
+	assert.True(exists)
+	_, exists = informer.get("cf-staging-space", "added")
+	assert.False(exists)
+}
+
+func TestInformerReplaceRemovesVanishedObjects(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	informer := newTestInformer(nil, watch.NewFake())
+	informer.replace([]runtime.Object{newTestPod("kept"), newTestPod("vanished")})
+	events := recordEvents(informer)
+	nextEvent(t, events)
+	nextEvent(t, events)
+
+	// Act
+	informer.replace([]runtime.Object{newTestPod("kept")})
+	kept := nextEvent(t, events)
+	vanished := nextEvent(t, events)
+
+	// Assert
+	assert.Equal(recordedEvent{"update", "kept"}, kept)
+	assert.Equal(recordedEvent{"delete", "vanished"}, vanished)
+	assert.Len(informer.listAll(), 1)
+}
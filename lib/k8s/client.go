@@ -2,7 +2,10 @@ package k8s
 
 import (
 	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 
 	"github.com/cf-furnace/pkg/cloudfoundry"
@@ -11,10 +14,52 @@ import (
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/apis/batch"
-	"k8s.io/kubernetes/pkg/client/restclient"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
 )
 
+const (
+	// StagingFingerprintAnnotation holds the fingerprint of the staging request
+	// that created a job, so that retried requests can be told apart from
+	// conflicting ones.
+	StagingFingerprintAnnotation = "cloudfoundry.org/staging-fingerprint"
+
+	// LogGuidAnnotation holds the guid staging output is logged under in
+	// Loggregator.
+	LogGuidAnnotation = "cloudfoundry.org/log-guid"
+
+	// StackAnnotation holds the stack an app is staged for.
+	StackAnnotation = "cloudfoundry.org/stack"
+
+	// RequestIdAnnotation holds the id of the stage request that created a
+	// job, to correlate it with the stager and CC logs.
+	RequestIdAnnotation = "cloudfoundry.org/request-id"
+
+	// AppGuidAnnotation holds the guid of the app being staged.
+	AppGuidAnnotation = "cloudfoundry.org/app-guid"
+
+	// CompletionCallbackAnnotation holds the URL CC asked the staging result
+	// to be delivered to, if it didn't leave it to the stager.
+	CompletionCallbackAnnotation = "cloudfoundry.org/completion-callback"
+)
+
+const (
+	// CompletionCredentialsDir is where the secret with the credentials for
+	// calling back the stager is mounted in the staging container.
+	CompletionCredentialsDir = "/etc/cf-staging/completion"
+
+	// Keys of the completion credentials in the staging secret
+	CompletionClientCertKey = "client.crt"
+	CompletionClientKeyKey  = "client.key"
+	CompletionServerCAKey   = "ca.crt"
+
+	completionCredentialsVolume = "completion-credentials"
+)
+
+// ErrStagingTaskExists is returned by StartStaging when a job for the
+// staging id has already been created.
+var ErrStagingTaskExists = goerrors.New("staging task already exists")
+
 type Buildpack struct {
 	Id          string `json:"id"`
 	DownloadURL string `json:"url"`
@@ -33,6 +78,21 @@ type StagingInfo struct {
 	SkipCertVerify        bool
 	SkipDetection         bool
 	CompletionCallbackURL string
+	Fingerprint           string
+	LogGuid               string
+	RequestId             string
+	AppGuid               string
+	// CCCompletionCallback is where the staging result is delivered to. The
+	// CC internal API of the stager's configuration if empty.
+	CCCompletionCallback string
+
+	// PEM encoded client certificate and key the staging pod presents when
+	// calling back, if the stager requires client certificates
+	CompletionClientCert string
+	CompletionClientKey  string
+	// PEM encoded CA bundle the staging pod verifies the stager's
+	// certificate with, instead of the system's
+	CompletionServerCA string
 }
 
 type K8SStagingClient interface {
@@ -42,7 +102,10 @@ type K8SStagingClient interface {
 
 	StartStaging(stagingData *StagingInfo, space string) error
 	GetStagingTask(id, space string) (*batch.Job, bool, error)
+	ListStagingTasks(space string) ([]*batch.Job, error)
 	StopStaging(id, space string, gracePeriod int64) error
+	StreamStagingLogs(id, space string, options *LogOptions) (io.ReadCloser, error)
+	RecordStagingEvent(id, space, eventType, reason, message string) error
 }
 
 type Stager struct {
@@ -51,22 +114,22 @@ type Stager struct {
 
 	logger    lager.Logger
 	k8sClient *client.Client
+	cache     *StagingCache
 }
 
-func NewStager(address string, stagerId, kubeClientCertFile, kubeClientKeyFile, kubeCACertFile string, logger lager.Logger) (*Stager, error) {
-
-	config := restclient.Config{
-		Host: address,
-		TLSClientConfig: restclient.TLSClientConfig{
-			CertFile: kubeClientCertFile,
-			KeyFile:  kubeClientKeyFile,
-			CAFile:   kubeCACertFile,
-		},
+// NewStager connects to the Kubernetes API as described by connection.
+func NewStager(connection ConnectionConfig, stagerId string, logger lager.Logger) (*Stager, error) {
+	config, err := connection.RESTConfig()
+	if err != nil {
+		logger.Error("Can't configure Kubernetes client", err, lager.Data{"mode": connection.Mode})
+		return nil, err
 	}
 
+	address := config.Host
+
 	logger.Info("Trying to connect to Kubernetes API", lager.Data{"k8s_api_url": address})
 
-	k8sClient, err := client.New(&config)
+	k8sClient, err := client.New(config)
 	if err != nil {
 		logger.Error("Can't create Kubernetes Client", err, lager.Data{"address": address})
 		return nil, fmt.Errorf("Can't create Kubernetes client; k8s api address: %s", address)
@@ -86,29 +149,60 @@ func NewStager(address string, stagerId, kubeClientCertFile, kubeClientKeyFile,
 
 		logger:    logger,
 		k8sClient: k8sClient,
+		cache:     NewStagingCache(k8sClient, stagerId, logger),
 	}, nil
 }
 
+// Cache returns the informer cache backing the stager's lookups. It has to
+// be run for lookups to be served from it; until it has synced, and for
+// objects it doesn't hold, they go to the Kubernetes API directly.
+func (s *Stager) Cache() *StagingCache {
+	return s.cache
+}
+
+// AddJobEventHandler adds a handler notified of the staging jobs in the cache.
+func (s *Stager) AddJobEventHandler(handler ResourceEventHandler) {
+	s.cache.AddJobEventHandler(handler)
+}
+
+// AddPodEventHandler adds a handler notified of the staging pods in the cache.
+func (s *Stager) AddPodEventHandler(handler ResourceEventHandler) {
+	s.cache.AddPodEventHandler(handler)
+}
+
+// Ping checks that the Kubernetes API can be reached.
+func (s *Stager) Ping() error {
+	_, err := s.k8sClient.ServerVersion()
+	return err
+}
+
 func (s *Stager) CreateStagingNamespace(organization, space string) error {
-	newNamespace := &api.Namespace{
-		ObjectMeta: api.ObjectMeta{
-			Name: formatStagingNamespace(space),
-			Labels: map[string]string{
-				"cf-organization": organization,
-				"cf-space":        space,
-				"stager-id":       s.StagerId,
-			},
-		},
+	namespace, err := s.k8sClient.Namespaces().Create(NewStagingNamespace(organization, space, s.StagerId))
+	if errors.IsAlreadyExists(err) {
+		// Created by a concurrent stage request, possibly of another instance
+		return nil
+	}
+
+	if err != nil {
+		return err
 	}
 
-	_, err := s.k8sClient.Namespaces().Create(newNamespace)
+	s.cache.namespaces.store(namespace)
 
-	return err
+	return nil
 }
 
 func (s *Stager) GetStagingNamespace(space string) (*api.Namespace, bool, error) {
 	name := formatStagingNamespace(space)
 
+	// Misses go to the API, the cache lags behind objects created by other
+	// instances
+	if s.cache.HasSynced() {
+		if namespace, exists := s.cache.GetNamespace(name); exists {
+			return namespace, true, nil
+		}
+	}
+
 	namespace, err := s.k8sClient.Namespaces().Get(name)
 
 	if err != nil {
@@ -127,19 +221,176 @@ func (s *Stager) RemoveStagingNamespace(space string) error {
 	return s.k8sClient.Namespaces().Delete(name)
 }
 
+// StartStaging creates the job of a staging, and the secret holding its
+// completion credentials first if it has any.
 func (s *Stager) StartStaging(stagingData *StagingInfo, space string) error {
+	secret, err := NewStagingSecret(stagingData, space, s.StagerId)
+	if err != nil {
+		return err
+	}
+
+	job, err := NewStagingJob(stagingData, space, s.StagerId)
+	if err != nil {
+		return err
+	}
+
+	if secret != nil {
+		_, err = s.k8sClient.Secrets(secret.Namespace).Create(secret)
+		if errors.IsAlreadyExists(err) {
+			// Left behind by an earlier attempt, or the job exists already
+			_, err = s.k8sClient.Secrets(secret.Namespace).Update(secret)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	job, err = s.k8sClient.BatchClient.Jobs(job.Namespace).Create(job)
+	if errors.IsAlreadyExists(err) {
+		return ErrStagingTaskExists
+	}
+
+	if err != nil {
+		if secret != nil {
+			s.deleteStagingSecret(secret.Namespace, secret.Name)
+		}
+		return err
+	}
+
+	s.cache.jobs.store(job)
+
+	return nil
+}
+
+func (s *Stager) GetStagingTask(id, space string) (*batch.Job, bool, error) {
+	namespace := formatStagingNamespace(space)
+	taskGuid, err := cloudfoundry.NewTaskGuid(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Misses go to the API, the cache lags behind jobs created by other
+	// instances and doesn't hold jobs without a stager id
+	if s.cache.HasSynced() {
+		if job, exists := s.cache.GetJob(namespace, taskGuid.ShortenedGuid()); exists {
+			return job, true, nil
+		}
+	}
+
+	result, err := s.k8sClient.BatchClient.Jobs(namespace).Get(taskGuid.ShortenedGuid())
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, false, nil
+		} else {
+			return nil, false, err
+		}
+	}
+
+	return result, true, nil
+}
+
+// ListStagingTasks returns the staging jobs of this stager in a space,
+// oldest first.
+func (s *Stager) ListStagingTasks(space string) ([]*batch.Job, error) {
 	namespace := formatStagingNamespace(space)
+	jobs := []*batch.Job{}
 
-	buildpacksJSON, err := json.Marshal(stagingData.Buildpacks)
+	if s.cache.HasSynced() {
+		for _, job := range s.cache.ListJobs() {
+			if job.Namespace == namespace {
+				jobs = append(jobs, job)
+			}
+		}
+	} else {
+		selector := labels.SelectorFromSet(labels.Set{StagerIdLabel: s.StagerId})
+		list, err := s.k8sClient.BatchClient.Jobs(namespace).List(api.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range list.Items {
+			jobs = append(jobs, &list.Items[idx])
+		}
+	}
+
+	SortStagingJobs(jobs)
+
+	return jobs, nil
+}
+
+// SortStagingJobs sorts jobs oldest first, by name if created at the same
+// time.
+func SortStagingJobs(jobs []*batch.Job) {
+	sort.Sort(jobsByCreation(jobs))
+}
+
+type jobsByCreation []*batch.Job
+
+func (j jobsByCreation) Len() int      { return len(j) }
+func (j jobsByCreation) Swap(a, b int) { j[a], j[b] = j[b], j[a] }
+func (j jobsByCreation) Less(a, b int) bool {
+	if j[a].CreationTimestamp.Time.Equal(j[b].CreationTimestamp.Time) {
+		return j[a].Name < j[b].Name
+	}
+
+	return j[a].CreationTimestamp.Time.Before(j[b].CreationTimestamp.Time)
+}
+
+func (s *Stager) StopStaging(id, space string, gracePeriod int64) error {
+	namespace := formatStagingNamespace(space)
+	taskGuid, err := cloudfoundry.NewTaskGuid(id)
 	if err != nil {
-		s.logger.Error(
-			"Error marshalling buildpacks JSON.",
-			err,
-			lager.Data{
-				"StagingId":  stagingData.Id,
-				"Buildpacks": stagingData.Buildpacks,
+		return err
+	}
+
+	err = s.k8sClient.BatchClient.Jobs(namespace).Delete(taskGuid.ShortenedGuid(), nil)
+	if err != nil {
+		return err
+	}
+
+	return s.deleteStagingSecret(namespace, taskGuid.ShortenedGuid())
+}
+
+// deleteStagingSecret deletes the secret of a staging, which only exists if
+// the staging has completion credentials.
+func (s *Stager) deleteStagingSecret(namespace, name string) error {
+	err := s.k8sClient.Secrets(namespace).Delete(name)
+	if err != nil && !errors.IsNotFound(err) {
+		s.logger.Error("Error deleting staging secret.", err, lager.Data{
+			"Namespace": namespace,
+			"Secret":    name,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// NewStagingNamespace returns the namespace stagings of a space run in,
+// without creating it.
+func NewStagingNamespace(organization, space, stagerId string) *api.Namespace {
+	return &api.Namespace{
+		ObjectMeta: api.ObjectMeta{
+			Name: formatStagingNamespace(space),
+			Labels: map[string]string{
+				"cf-organization": organization,
+				"cf-space":        space,
+				StagerIdLabel:     stagerId,
 			},
-		)
+		},
+	}
+}
+
+// NewStagingJob returns the job that runs a staging in a space, without
+// creating it.
+func NewStagingJob(stagingData *StagingInfo, space, stagerId string) (*batch.Job, error) {
+	namespace := formatStagingNamespace(space)
+
+	buildpacksJSON, err := json.Marshal(stagingData.Buildpacks)
+	if err != nil {
+		return nil, err
 	}
 
 	buildpackOrderList := make([]string, len(stagingData.Buildpacks))
@@ -162,13 +413,41 @@ func (s *Stager) StartStaging(stagingData *StagingInfo, space string) error {
 	stagingData.Environment["CF_COMPLETION_CALLBACK_URL"] = stagingData.CompletionCallbackURL
 	stagingData.Environment["CF_SPACE"] = space
 
+	// The credentials themselves are in the staging secret
+	if stagingData.CompletionClientCert != "" {
+		stagingData.Environment["CF_COMPLETION_CLIENT_CERT_FILE"] = completionCredentialsPath(CompletionClientCertKey)
+		stagingData.Environment["CF_COMPLETION_CLIENT_KEY_FILE"] = completionCredentialsPath(CompletionClientKeyKey)
+	}
+	if stagingData.CompletionServerCA != "" {
+		stagingData.Environment["CF_COMPLETION_CA_FILE"] = completionCredentialsPath(CompletionServerCAKey)
+	}
+
 	taskGuid, err := cloudfoundry.NewTaskGuid(stagingData.Id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	vcapUid := int64(2000)
 
+	var volumes []api.Volume
+	var volumeMounts []api.VolumeMount
+	var podSecurityContext *api.PodSecurityContext
+	if hasCompletionCredentials(stagingData) {
+		volumes = append(volumes, api.Volume{
+			Name: completionCredentialsVolume,
+			VolumeSource: api.VolumeSource{
+				Secret: &api.SecretVolumeSource{SecretName: taskGuid.ShortenedGuid()},
+			},
+		})
+		volumeMounts = append(volumeMounts, api.VolumeMount{
+			Name:      completionCredentialsVolume,
+			MountPath: CompletionCredentialsDir,
+			ReadOnly:  true,
+		})
+		// Makes the secret volume readable by vcap
+		podSecurityContext = &api.PodSecurityContext{FSGroup: &vcapUid}
+	}
+
 	job := &batch.Job{
 		ObjectMeta: api.ObjectMeta{
 			Namespace: namespace,
@@ -177,6 +456,15 @@ func (s *Stager) StartStaging(stagingData *StagingInfo, space string) error {
 				"cloudfoundry.org/app-guid":   taskGuid.AppGuid.String(),
 				"cloudfoundry.org/space-guid": space,
 				"cloudfoundry.org/task-guid":  taskGuid.ShortenedGuid(),
+				StagerIdLabel:                 stagerId,
+			},
+			Annotations: map[string]string{
+				StagingFingerprintAnnotation: stagingData.Fingerprint,
+				LogGuidAnnotation:            stagingData.LogGuid,
+				StackAnnotation:              stagingData.Stack,
+				RequestIdAnnotation:          stagingData.RequestId,
+				AppGuidAnnotation:            stagingData.AppGuid,
+				CompletionCallbackAnnotation: stagingData.CCCompletionCallback,
 			},
 		},
 		Spec: batch.JobSpec{
@@ -186,72 +474,102 @@ func (s *Stager) StartStaging(stagingData *StagingInfo, space string) error {
 						"cloudfoundry.org/app-guid":   taskGuid.AppGuid.String(),
 						"cloudfoundry.org/space-guid": space,
 						"cloudfoundry.org/task-guid":  taskGuid.ShortenedGuid(),
+						StagerIdLabel:                 stagerId,
+					},
+					Annotations: map[string]string{
+						LogGuidAnnotation: stagingData.LogGuid,
+						StackAnnotation:   stagingData.Stack,
 					},
 				},
 				Spec: api.PodSpec{
 					Containers: []api.Container{
 						api.Container{
-							Name:    "staging",
+							Name:    stagingContainerName,
 							Image:   stagingData.Image,
 							Env:     convertEnvironmentVariables(stagingData.Environment),
 							Command: stagingData.Command,
 							SecurityContext: &api.SecurityContext{
 								RunAsUser: &vcapUid,
 							},
-							WorkingDir: "/home/vcap/",
+							WorkingDir:   "/home/vcap/",
+							VolumeMounts: volumeMounts,
 						},
 					},
-					RestartPolicy: api.RestartPolicyNever,
+					Volumes:         volumes,
+					SecurityContext: podSecurityContext,
+					RestartPolicy:   api.RestartPolicyNever,
 				},
 			},
 		},
 	}
 
-	_, err = s.k8sClient.BatchClient.Jobs(namespace).Create(job)
-	return err
+	return job, nil
 }
 
-func (s *Stager) GetStagingTask(id, space string) (*batch.Job, bool, error) {
-	namespace := formatStagingNamespace(space)
-	taskGuid, err := cloudfoundry.NewTaskGuid(id)
-	if err != nil {
-		return nil, false, err
+// NewStagingSecret returns the secret holding the completion credentials of
+// a staging, without creating it. It is nil if the staging has none.
+func NewStagingSecret(stagingData *StagingInfo, space, stagerId string) (*api.Secret, error) {
+	if !hasCompletionCredentials(stagingData) {
+		return nil, nil
 	}
 
-	result, err := s.k8sClient.BatchClient.Jobs(namespace).Get(taskGuid.ShortenedGuid())
-
+	taskGuid, err := cloudfoundry.NewTaskGuid(stagingData.Id)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+		return nil, err
 	}
 
-	return result, true, nil
+	data := map[string][]byte{}
+	if stagingData.CompletionClientCert != "" {
+		data[CompletionClientCertKey] = []byte(stagingData.CompletionClientCert)
+		data[CompletionClientKeyKey] = []byte(stagingData.CompletionClientKey)
+	}
+	if stagingData.CompletionServerCA != "" {
+		data[CompletionServerCAKey] = []byte(stagingData.CompletionServerCA)
+	}
+
+	return &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: formatStagingNamespace(space),
+			Name:      taskGuid.ShortenedGuid(),
+			Labels: map[string]string{
+				"cloudfoundry.org/app-guid":   taskGuid.AppGuid.String(),
+				"cloudfoundry.org/space-guid": space,
+				"cloudfoundry.org/task-guid":  taskGuid.ShortenedGuid(),
+				StagerIdLabel:                 stagerId,
+			},
+		},
+		Type: api.SecretTypeOpaque,
+		Data: data,
+	}, nil
 }
 
-func (s *Stager) StopStaging(id, space string, gracePeriod int64) error {
-	namespace := formatStagingNamespace(space)
-	taskGuid, err := cloudfoundry.NewTaskGuid(id)
-	if err != nil {
-		return err
-	}
+func hasCompletionCredentials(stagingData *StagingInfo) bool {
+	return stagingData.CompletionClientCert != "" || stagingData.CompletionServerCA != ""
+}
 
-	return s.k8sClient.BatchClient.Jobs(namespace).Delete(taskGuid.ShortenedGuid(), nil)
+func completionCredentialsPath(key string) string {
+	return CompletionCredentialsDir + "/" + key
 }
 
 func formatStagingNamespace(space string) string {
 	return fmt.Sprintf("cf-staging-%s", space)
 }
 
+// convertEnvironmentVariables sorts the variables by name, so that jobs
+// for the same staging are identical.
 func convertEnvironmentVariables(envVars map[string]string) []api.EnvVar {
+	names := []string{}
+	for name := range envVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	result := []api.EnvVar{}
 
-	for k, v := range envVars {
+	for _, name := range names {
 		envVar := api.EnvVar{
-			Name:  k,
-			Value: v,
+			Name:  name,
+			Value: envVars[name],
 		}
 
 		result = append(result, envVar)
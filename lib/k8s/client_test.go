@@ -0,0 +1,184 @@
+package k8s
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const testStagingId = "9a8b7c6d-1234-5678-9abc-def012345678-0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+
+func TestNewStagingJobMountsCompletionCredentialsFromSecret(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stagingInfo := &StagingInfo{
+		Id:                   testStagingId,
+		Environment:          map[string]string{},
+		CompletionClientCert: "certificate",
+		CompletionClientKey:  "private key",
+		CompletionServerCA:   "server ca",
+	}
+
+	// Act
+	secret, secretErr := NewStagingSecret(stagingInfo, "furnace-staging", "stager-0")
+	job, jobErr := NewStagingJob(stagingInfo, "furnace-staging", "stager-0")
+
+	// Assert
+	assert.NoError(secretErr)
+	assert.NoError(jobErr)
+	assert.Equal(job.Namespace, secret.Namespace)
+	assert.Equal(job.Name, secret.Name)
+	assert.Equal("stager-0", secret.Labels[StagerIdLabel])
+	assert.Equal(map[string][]byte{
+		CompletionClientCertKey: []byte("certificate"),
+		CompletionClientKeyKey:  []byte("private key"),
+		CompletionServerCAKey:   []byte("server ca"),
+	}, secret.Data)
+
+	podSpec := job.Spec.Template.Spec
+	if assert.Len(podSpec.Volumes, 1) {
+		assert.Equal(secret.Name, podSpec.Volumes[0].Secret.SecretName)
+	}
+	if assert.Len(podSpec.Containers[0].VolumeMounts, 1) {
+		assert.Equal(CompletionCredentialsDir, podSpec.Containers[0].VolumeMounts[0].MountPath)
+		assert.True(podSpec.Containers[0].VolumeMounts[0].ReadOnly)
+	}
+
+	env := map[string]string{}
+	for _, envVar := range podSpec.Containers[0].Env {
+		env[envVar.Name] = envVar.Value
+		assert.NotContains(envVar.Value, "private key")
+	}
+	assert.Equal(CompletionCredentialsDir+"/client.crt", env["CF_COMPLETION_CLIENT_CERT_FILE"])
+	assert.Equal(CompletionCredentialsDir+"/client.key", env["CF_COMPLETION_CLIENT_KEY_FILE"])
+	assert.Equal(CompletionCredentialsDir+"/ca.crt", env["CF_COMPLETION_CA_FILE"])
+}
+
+func TestNewStagingJobWithoutCompletionCredentialsHasNoSecret(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stagingInfo := &StagingInfo{Id: testStagingId, Environment: map[string]string{}}
+
+	// Act
+	secret, secretErr := NewStagingSecret(stagingInfo, "furnace-staging", "stager-0")
+	job, jobErr := NewStagingJob(stagingInfo, "furnace-staging", "stager-0")
+
+	// Assert
+	assert.NoError(secretErr)
+	assert.NoError(jobErr)
+	assert.Nil(secret)
+	assert.Empty(job.Spec.Template.Spec.Volumes)
+	assert.Nil(job.Spec.Template.Spec.SecurityContext)
+}
+
+// newTestStager creates a stager talking to handler, with a synced cache that
+// holds nothing, as if the objects were created by another instance.
+func newTestStager(t *testing.T, handler http.HandlerFunc) (*Stager, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	k8sClient, err := client.New(&restclient.Config{Host: server.URL})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	logger := lager.NewLogger("client-test")
+	stager := &Stager{
+		StagerId:  "stager-0",
+		logger:    logger,
+		k8sClient: k8sClient,
+		cache:     NewStagingCache(k8sClient, "stager-0", logger),
+	}
+	stager.cache.namespaces.replace(nil)
+	stager.cache.jobs.replace(nil)
+	stager.cache.pods.replace(nil)
+
+	return stager, server
+}
+
+func writeAlreadyExists(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`)
+}
+
+func TestStartStagingRetriedOnAnotherInstanceFindsExistingJob(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stagingInfo := &StagingInfo{Id: testStagingId, Environment: map[string]string{}}
+	job, err := NewStagingJob(stagingInfo, "space", "stager-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobsPath := "/apis/batch/v1/namespaces/cf-staging-space/jobs"
+	stager, server := newTestStager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == jobsPath:
+			writeAlreadyExists(w)
+		case r.Method == "GET" && r.URL.Path == jobsPath+"/"+job.Name:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":%q,"namespace":"cf-staging-space","annotations":{%q:"fingerprint"}}}`,
+				job.Name, StagingFingerprintAnnotation)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	// Act
+	startErr := stager.StartStaging(stagingInfo, "space")
+	existing, exists, getErr := stager.GetStagingTask(testStagingId, "space")
+
+	// Assert
+	assert.Equal(ErrStagingTaskExists, startErr)
+	assert.NoError(getErr)
+	if assert.True(exists) {
+		assert.Equal("fingerprint", existing.Annotations[StagingFingerprintAnnotation])
+	}
+}
+
+func TestGetStagingTaskMissingFromCacheAndAPI(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, server := newTestStager(t, http.NotFound)
+	defer server.Close()
+
+	// Act
+	_, exists, err := stager.GetStagingTask(testStagingId, "space")
+
+	// Assert
+	assert.NoError(err)
+	assert.False(exists)
+}
+
+func TestCreateStagingNamespaceCreatedConcurrently(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, server := newTestStager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v1/namespaces":
+			writeAlreadyExists(w)
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/cf-staging-space":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"cf-staging-space"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	// Act
+	createErr := stager.CreateStagingNamespace("org", "space")
+	_, exists, getErr := stager.GetStagingNamespace("space")
+
+	// Assert
+	assert.NoError(createErr)
+	assert.NoError(getErr)
+	assert.True(exists)
+}
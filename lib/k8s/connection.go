@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/client/restclient"
+)
+
+// Ways of connecting to the Kubernetes API
+const (
+	// ConnectionExplicit connects to an endpoint with explicitly configured
+	// client certificate, key and CA files.
+	ConnectionExplicit = "explicit"
+	// ConnectionInCluster uses the service account of the pod the stager
+	// runs in.
+	ConnectionInCluster = "in-cluster"
+	// ConnectionKubeconfig uses a cluster and user from a kubeconfig file.
+	ConnectionKubeconfig = "kubeconfig"
+)
+
+// ConnectionConfig describes how to connect to the Kubernetes API.
+type ConnectionConfig struct {
+	// Mode is one of the connection modes, ConnectionExplicit if empty.
+	Mode string
+
+	// Used by ConnectionExplicit
+	Endpoint       string
+	ClientCertFile string
+	ClientKeyFile  string
+	CACertFile     string
+
+	// Used by ConnectionKubeconfig. The current context of the kubeconfig is
+	// used if KubeconfigContext is empty.
+	Kubeconfig        string
+	KubeconfigContext string
+
+	// QPS and Burst limit the rate of requests to the API, the client
+	// defaults are used if they are zero.
+	QPS   float32
+	Burst int
+	// RequestTimeout bounds requests to the API, except for watches and
+	// followed logs. No timeout if zero.
+	RequestTimeout time.Duration
+}
+
+// RESTConfig returns the client configuration for a connection.
+func (c ConnectionConfig) RESTConfig() (*restclient.Config, error) {
+	var config *restclient.Config
+	var err error
+
+	switch c.Mode {
+	case "", ConnectionExplicit:
+		config = &restclient.Config{
+			Host: c.Endpoint,
+			TLSClientConfig: restclient.TLSClientConfig{
+				CertFile: c.ClientCertFile,
+				KeyFile:  c.ClientKeyFile,
+				CAFile:   c.CACertFile,
+			},
+		}
+	case ConnectionInCluster:
+		config, err = restclient.InClusterConfig()
+	case ConnectionKubeconfig:
+		config, err = kubeconfigRESTConfig(c.Kubeconfig, c.KubeconfigContext)
+	default:
+		return nil, fmt.Errorf("unknown Kubernetes connection mode %q, must be one of %s, %s or %s",
+			c.Mode, ConnectionExplicit, ConnectionInCluster, ConnectionKubeconfig)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	config.QPS = c.QPS
+	config.Burst = c.Burst
+
+	if c.RequestTimeout > 0 {
+		config.WrapTransport = chainTransportWrappers(config.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+			return &timeoutRoundTripper{next: rt, timeout: c.RequestTimeout}
+		})
+	}
+
+	return config, nil
+}
+
+func chainTransportWrappers(first, second func(http.RoundTripper) http.RoundTripper) func(http.RoundTripper) http.RoundTripper {
+	if first == nil {
+		return second
+	}
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return second(first(rt))
+	}
+}
+
+// timeoutRoundTripper cancels requests that take longer than a timeout,
+// including reading their response. Watches and followed logs stay open for
+// as long as they are needed and aren't bounded.
+type timeoutRoundTripper struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isStreamingRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func isStreamingRequest(req *http.Request) bool {
+	query := req.URL.Query()
+
+	return query.Get("watch") == "true" ||
+		query.Get("follow") == "true" ||
+		strings.Contains(req.URL.Path, "/watch/")
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,181 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: default
+clusters:
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority: certs/ca.pem
+- name: remote
+  cluster:
+    server: https://k8s.example.com
+    insecure-skip-tls-verify: true
+users:
+- name: admin
+  user:
+    client-certificate: /etc/k8s/admin.pem
+    client-key: certs/admin-key.pem
+- name: stager
+  user:
+    token: stager-token
+contexts:
+- name: default
+  context:
+    cluster: local
+    user: admin
+- name: staging
+  context:
+    cluster: remote
+    user: stager
+`
+
+func writeTempFile(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestKubeconfigUsesCurrentContext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "config", testKubeconfig, 0600)
+
+	// Act
+	config, err := ConnectionConfig{Mode: ConnectionKubeconfig, Kubeconfig: path}.RESTConfig()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("https://127.0.0.1:6443", config.Host)
+	assert.Equal(filepath.Join(dir, "certs/ca.pem"), config.CAFile)
+	assert.Equal("/etc/k8s/admin.pem", config.CertFile)
+	assert.Equal(filepath.Join(dir, "certs/admin-key.pem"), config.KeyFile)
+}
+
+func TestKubeconfigUsesGivenContext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "config", testKubeconfig, 0600)
+
+	// Act
+	config, err := ConnectionConfig{
+		Mode:              ConnectionKubeconfig,
+		Kubeconfig:        path,
+		KubeconfigContext: "staging",
+		QPS:               20,
+		Burst:             40,
+	}.RESTConfig()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("https://k8s.example.com", config.Host)
+	assert.True(config.Insecure)
+	assert.Equal("stager-token", config.BearerToken)
+	assert.Equal(float32(20), config.QPS)
+	assert.Equal(40, config.Burst)
+}
+
+func TestKubeconfigRejectsUnknownContext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "config", testKubeconfig, 0600)
+
+	// Act
+	_, err := ConnectionConfig{Mode: ConnectionKubeconfig, Kubeconfig: path, KubeconfigContext: "missing"}.RESTConfig()
+
+	// Assert
+	assert.EqualError(err, `context "missing" not found in kubeconfig `+path)
+}
+
+func TestKubeconfigExecCredentialsAuthorizeRequests(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	writeTempFile(t, dir, "credentials.sh", `#!/bin/sh
+echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"'$TOKEN_PREFIX'-token"}}'
+`, 0700)
+	path := writeTempFile(t, dir, "config", `
+current-context: exec
+clusters:
+- name: cluster
+  cluster:
+    server: `+server.URL+`
+users:
+- name: exec
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: ./credentials.sh
+      env:
+      - name: TOKEN_PREFIX
+        value: exec
+contexts:
+- name: exec
+  context:
+    cluster: cluster
+    user: exec
+`, 0600)
+
+	config, err := ConnectionConfig{Mode: ConnectionKubeconfig, Kubeconfig: path}.RESTConfig()
+	assert.NoError(err)
+	client := &http.Client{Transport: config.WrapTransport(http.DefaultTransport)}
+
+	// Act
+	_, err = client.Get(server.URL + "/version")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("Bearer exec-token", authorization)
+}
+
+func TestRequestTimeoutDoesNotApplyToWatches(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	config, err := ConnectionConfig{Endpoint: server.URL, RequestTimeout: 20 * time.Millisecond}.RESTConfig()
+	assert.NoError(err)
+	client := &http.Client{Transport: config.WrapTransport(http.DefaultTransport)}
+
+	// Act
+	_, getErr := client.Get(server.URL + "/api/v1/pods")
+	_, watchErr := client.Get(server.URL + "/api/v1/pods?watch=true")
+
+	// Assert
+	assert.Error(getErr)
+	assert.NoError(watchErr)
+}
@@ -0,0 +1,286 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"k8s.io/kubernetes/pkg/api"
+	k8serrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+const (
+	endpointsPortName        = "api"
+	endpointsUpdateAttempts  = 5
+	serviceAccountNamespace  = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	endpointsManagedByLabel  = "app.kubernetes.io/managed-by"
+	endpointsManagedByStager = "k8s-stager"
+)
+
+// PodNamespace returns the namespace of the pod the stager runs in.
+func PodNamespace() (string, error) {
+	namespace, err := ioutil.ReadFile(serviceAccountNamespace)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// EndpointsRegistration makes a stager discoverable through a Kubernetes
+// Service without a selector. Every stager keeps its own address in the
+// Endpoints of the Service, among the ready or the not ready addresses
+// depending on its readiness, and removes it when it stops.
+type EndpointsRegistration struct {
+	stager    *Stager
+	namespace string
+	service   string
+	address   string
+	port      int
+	ready     func() error
+	interval  time.Duration
+	logger    lager.Logger
+}
+
+// NewEndpointsRegistration registers address and port under service in
+// namespace. ready tells whether the stager can take requests, the
+// registration is refreshed every interval.
+func NewEndpointsRegistration(
+	stager *Stager,
+	namespace, service, address string,
+	port int,
+	ready func() error,
+	interval time.Duration,
+	logger lager.Logger,
+) *EndpointsRegistration {
+	return &EndpointsRegistration{
+		stager:    stager,
+		namespace: namespace,
+		service:   service,
+		address:   address,
+		port:      port,
+		ready:     ready,
+		interval:  interval,
+		logger: logger.Session("endpoints-registration", lager.Data{
+			"Namespace": namespace,
+			"Service":   service,
+			"Address":   address,
+		}),
+	}
+}
+
+func (r *EndpointsRegistration) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if err := r.ensureService(); err != nil {
+		r.logger.Error("Error creating service.", err)
+	}
+	r.register()
+
+	// Failed registrations are retried with the next refresh instead of
+	// holding up the start of the stager
+	close(ready)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.register()
+		case <-signals:
+			r.deregister()
+			return nil
+		}
+	}
+}
+
+func (r *EndpointsRegistration) ensureService() error {
+	_, err := r.stager.k8sClient.Services(r.namespace).Create(&api.Service{
+		ObjectMeta: api.ObjectMeta{
+			Name:   r.service,
+			Labels: map[string]string{endpointsManagedByLabel: endpointsManagedByStager},
+		},
+		Spec: api.ServiceSpec{
+			Type:      api.ServiceTypeClusterIP,
+			ClusterIP: api.ClusterIPNone,
+			Ports: []api.ServicePort{{
+				Name:       endpointsPortName,
+				Port:       int32(r.port),
+				TargetPort: intstr.FromInt(r.port),
+				Protocol:   api.ProtocolTCP,
+			}},
+		},
+	})
+	if k8serrors.IsAlreadyExists(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (r *EndpointsRegistration) register() {
+	ready := r.ready() == nil
+
+	err := r.updateEndpoints(func(endpoints *api.Endpoints) bool {
+		return setEndpointAddress(endpoints, api.EndpointAddress{IP: r.address}, r.endpointPort(), ready)
+	})
+	if err != nil {
+		r.logger.Error("Error registering endpoint.", err)
+		return
+	}
+
+	r.logger.Debug("Registered endpoint.", lager.Data{"Ready": ready})
+}
+
+func (r *EndpointsRegistration) deregister() {
+	err := r.updateEndpoints(func(endpoints *api.Endpoints) bool {
+		return removeEndpointAddress(endpoints, r.address)
+	})
+	if err != nil {
+		r.logger.Error("Error deregistering endpoint.", err)
+		return
+	}
+
+	r.logger.Info("Deregistered endpoint.")
+}
+
+// updateEndpoints applies change to the Endpoints of the service, creating
+// them if needed. change tells whether it changed anything. Other stagers
+// change the Endpoints too, conflicting updates are retried.
+func (r *EndpointsRegistration) updateEndpoints(change func(*api.Endpoints) bool) error {
+	client := r.stager.k8sClient.Endpoints(r.namespace)
+
+	var err error
+	for attempt := 0; attempt < endpointsUpdateAttempts; attempt++ {
+		var endpoints *api.Endpoints
+		endpoints, err = client.Get(r.service)
+		if k8serrors.IsNotFound(err) {
+			endpoints = &api.Endpoints{
+				ObjectMeta: api.ObjectMeta{
+					Name:   r.service,
+					Labels: map[string]string{endpointsManagedByLabel: endpointsManagedByStager},
+				},
+			}
+			if !change(endpoints) {
+				return nil
+			}
+
+			_, err = client.Create(endpoints)
+		} else if err == nil {
+			if !change(endpoints) {
+				return nil
+			}
+
+			_, err = client.Update(endpoints)
+		}
+
+		if !k8serrors.IsConflict(err) && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (r *EndpointsRegistration) endpointPort() api.EndpointPort {
+	return api.EndpointPort{
+		Name:     endpointsPortName,
+		Port:     int32(r.port),
+		Protocol: api.ProtocolTCP,
+	}
+}
+
+// setEndpointAddress puts address among the ready or not ready addresses
+// of the subset for port, removing it from anywhere else. It tells whether
+// the endpoints changed.
+func setEndpointAddress(endpoints *api.Endpoints, address api.EndpointAddress, port api.EndpointPort, ready bool) bool {
+	if hasEndpointAddress(endpoints, address, port, ready) {
+		return false
+	}
+
+	removeEndpointAddress(endpoints, address.IP)
+
+	idx := -1
+	for i, subset := range endpoints.Subsets {
+		if reflect.DeepEqual(subset.Ports, []api.EndpointPort{port}) {
+			idx = i
+		}
+	}
+
+	if idx < 0 {
+		endpoints.Subsets = append(endpoints.Subsets, api.EndpointSubset{Ports: []api.EndpointPort{port}})
+		idx = len(endpoints.Subsets) - 1
+	}
+
+	subset := &endpoints.Subsets[idx]
+	if ready {
+		subset.Addresses = append(subset.Addresses, address)
+	} else {
+		subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+	}
+
+	return true
+}
+
+// hasEndpointAddress tells whether address is only listed where
+// setEndpointAddress would put it.
+func hasEndpointAddress(endpoints *api.Endpoints, address api.EndpointAddress, port api.EndpointPort, ready bool) bool {
+	found := false
+
+	for _, subset := range endpoints.Subsets {
+		for idx, addresses := range [][]api.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+			for _, listed := range addresses {
+				if listed.IP != address.IP {
+					continue
+				}
+
+				inPlace := reflect.DeepEqual(subset.Ports, []api.EndpointPort{port}) && (idx == 0) == ready
+				if !inPlace || found || !reflect.DeepEqual(listed, address) {
+					return false
+				}
+				found = true
+			}
+		}
+	}
+
+	return found
+}
+
+// removeEndpointAddress removes ip from all subsets, and the subsets left
+// without addresses. It tells whether the endpoints changed.
+func removeEndpointAddress(endpoints *api.Endpoints, ip string) bool {
+	var subsets []api.EndpointSubset
+	removed := false
+
+	for _, subset := range endpoints.Subsets {
+		addresses, notReadyAddresses := len(subset.Addresses), len(subset.NotReadyAddresses)
+		subset.Addresses = withoutIP(subset.Addresses, ip)
+		subset.NotReadyAddresses = withoutIP(subset.NotReadyAddresses, ip)
+		removed = removed || len(subset.Addresses) != addresses || len(subset.NotReadyAddresses) != notReadyAddresses
+
+		if len(subset.Addresses) > 0 || len(subset.NotReadyAddresses) > 0 {
+			subsets = append(subsets, subset)
+		}
+	}
+
+	if removed {
+		endpoints.Subsets = subsets
+	}
+
+	return removed
+}
+
+func withoutIP(addresses []api.EndpointAddress, ip string) []api.EndpointAddress {
+	var result []api.EndpointAddress
+
+	for _, address := range addresses {
+		if address.IP != ip {
+			result = append(result, address)
+		}
+	}
+
+	return result
+}
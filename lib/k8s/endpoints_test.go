@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+var testEndpointPort = api.EndpointPort{Name: "api", Port: 8080, Protocol: api.ProtocolTCP}
+
+func TestSetEndpointAddressMovesAddressToReady(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	endpoints := &api.Endpoints{
+		Subsets: []api.EndpointSubset{{
+			Addresses:         []api.EndpointAddress{{IP: "10.0.0.2"}},
+			NotReadyAddresses: []api.EndpointAddress{{IP: "10.0.0.1"}},
+			Ports:             []api.EndpointPort{testEndpointPort},
+		}},
+	}
+
+	// Act
+	changed := setEndpointAddress(endpoints, api.EndpointAddress{IP: "10.0.0.1"}, testEndpointPort, true)
+	changedAgain := setEndpointAddress(endpoints, api.EndpointAddress{IP: "10.0.0.1"}, testEndpointPort, true)
+
+	// Assert
+	assert.True(changed)
+	assert.False(changedAgain)
+	assert.Equal([]api.EndpointSubset{{
+		Addresses: []api.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.1"}},
+		Ports:     []api.EndpointPort{testEndpointPort},
+	}}, endpoints.Subsets)
+}
+
+func TestRemoveEndpointAddressDropsEmptySubsets(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	otherPort := api.EndpointPort{Name: "api", Port: 9090, Protocol: api.ProtocolTCP}
+	endpoints := &api.Endpoints{
+		Subsets: []api.EndpointSubset{
+			{Addresses: []api.EndpointAddress{{IP: "10.0.0.1"}}, Ports: []api.EndpointPort{testEndpointPort}},
+			{Addresses: []api.EndpointAddress{{IP: "10.0.0.2"}}, Ports: []api.EndpointPort{otherPort}},
+		},
+	}
+
+	// Act
+	removed := removeEndpointAddress(endpoints, "10.0.0.1")
+	removedAgain := removeEndpointAddress(endpoints, "10.0.0.1")
+
+	// Assert
+	assert.True(removed)
+	assert.False(removedAgain)
+	assert.Equal([]api.EndpointSubset{
+		{Addresses: []api.EndpointAddress{{IP: "10.0.0.2"}}, Ports: []api.EndpointPort{otherPort}},
+	}, endpoints.Subsets)
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// Reasons of the events recorded on staging jobs
+const (
+	ReasonStagingAccepted        = "StagingAccepted"
+	ReasonStagingStarted         = "StagingStarted"
+	ReasonStagingCompleted       = "StagingCompleted"
+	ReasonStagingFailed          = "StagingFailed"
+	ReasonStagingCancelled       = "StagingCancelled"
+	ReasonCallbackDeliveryFailed = "CallbackDeliveryFailed"
+)
+
+const eventSourceComponent = "k8s-stager"
+
+// RecordStagingEvent records a Kubernetes event on the job of a staging, so
+// that it shows up in `kubectl describe job`. eventType is api.EventTypeNormal
+// or api.EventTypeWarning.
+func (s *Stager) RecordStagingEvent(id, space, eventType, reason, message string) error {
+	job, exists, err := s.GetStagingTask(id, space)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrStagingTaskNotFound
+	}
+
+	return s.recordJobEvent(job, eventType, reason, message)
+}
+
+func (s *Stager) recordJobEvent(job *batch.Job, eventType, reason, message string) error {
+	now := unversioned.NewTime(time.Now())
+
+	_, err := s.k8sClient.Events(job.Namespace).Create(&api.Event{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: job.Namespace,
+			// Unique like the names of the events Kubernetes records itself
+			Name: fmt.Sprintf("%s.%x", job.Name, now.UnixNano()),
+		},
+		InvolvedObject: api.ObjectReference{
+			Kind:            "Job",
+			APIVersion:      "batch/v1",
+			Namespace:       job.Namespace,
+			Name:            job.Name,
+			UID:             job.UID,
+			ResourceVersion: job.ResourceVersion,
+		},
+		Reason:  reason,
+		Message: message,
+		Source: api.EventSource{
+			Component: eventSourceComponent,
+			Host:      s.StagerId,
+		},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	})
+
+	return err
+}
+
+// StagingEventRecorder records the transitions of staging pods that the
+// stager only observes through the staging cache: a pod starting and a pod
+// failing without reporting back. Only one stager of a deployment should
+// run it.
+type StagingEventRecorder struct {
+	stager *Stager
+	logger lager.Logger
+}
+
+func NewStagingEventRecorder(stager *Stager, logger lager.Logger) *StagingEventRecorder {
+	return &StagingEventRecorder{
+		stager: stager,
+		logger: logger.Session("staging-event-recorder"),
+	}
+}
+
+func (r *StagingEventRecorder) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	r.stager.Cache().AddPodEventHandler(ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj runtime.Object) {
+			r.podUpdated(oldObj.(*api.Pod), newObj.(*api.Pod))
+		},
+	})
+
+	close(ready)
+
+	<-signals
+	return nil
+}
+
+func (r *StagingEventRecorder) podUpdated(oldPod, pod *api.Pod) {
+	if oldPod.Status.Phase == pod.Status.Phase {
+		return
+	}
+
+	job, exists := r.stager.Cache().GetJob(pod.Namespace, pod.Labels["job-name"])
+	if !exists {
+		return
+	}
+
+	var err error
+	switch {
+	case oldPod.Status.Phase == api.PodPending && pod.Status.Phase == api.PodRunning:
+		err = r.stager.recordJobEvent(job, api.EventTypeNormal, ReasonStagingStarted,
+			fmt.Sprintf("Staging pod %s started on node %s", pod.Name, pod.Spec.NodeName))
+	case pod.Status.Phase == api.PodFailed:
+		err = r.stager.recordJobEvent(job, api.EventTypeWarning, ReasonStagingFailed,
+			fmt.Sprintf("Staging pod %s failed: %s", pod.Name, podFailureMessage(pod)))
+	default:
+		return
+	}
+
+	if err != nil {
+		r.logger.Error("Error recording staging event.", err, lager.Data{"Namespace": pod.Namespace, "Job": job.Name})
+	}
+}
+
+func podFailureMessage(pod *api.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			if terminated.Message != "" {
+				return terminated.Message
+			}
+
+			return fmt.Sprintf("%s (exit code %d)", terminated.Reason, terminated.ExitCode)
+		}
+	}
+
+	if pod.Status.Message != "" {
+		return pod.Status.Message
+	}
+
+	return pod.Status.Reason
+}
@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func TestPodFailureMessagePrefersTerminationMessage(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	pod := &api.Pod{
+		Status: api.PodStatus{
+			Phase: api.PodFailed,
+			ContainerStatuses: []api.ContainerStatus{{
+				State: api.ContainerState{
+					Terminated: &api.ContainerStateTerminated{ExitCode: 222, Reason: "Error", Message: "buildpack compile failed"},
+				},
+			}},
+		},
+	}
+
+	// Act
+	message := podFailureMessage(pod)
+
+	// Assert
+	assert.Equal("buildpack compile failed", message)
+}
+
+func TestPodFailureMessageFallsBackToExitCode(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	pod := &api.Pod{
+		Status: api.PodStatus{
+			Phase: api.PodFailed,
+			ContainerStatuses: []api.ContainerStatus{{
+				State: api.ContainerState{
+					Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"},
+				},
+			}},
+		},
+	}
+
+	// Act
+	message := podFailureMessage(pod)
+
+	// Assert
+	assert.Equal("OOMKilled (exit code 137)", message)
+}
@@ -1,27 +1,38 @@
 // +build integration
+
 package k8s
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/cf-furnace/k8s-stager/lib/logger"
+	"github.com/cf-furnace/k8s-stager/lib/client"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
 
-	"github.com/pivotal-golang/lager"
+	"code.cloudfoundry.org/lager"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 const (
 	k8sApiUrlEnvVar = "CF_STAGER_INTEGRATION_K8S_ADDRESS"
+
+	// A stager running against the same Kubernetes, for the API tests
+	stagerUrlEnvVar       = "CF_STAGER_INTEGRATION_STAGER_URL"
+	stagerNamespaceEnvVar = "CF_STAGER_INTEGRATION_STAGER_NAMESPACE"
+	stagerUsernameEnvVar  = "CF_STAGER_INTEGRATION_CC_USERNAME"
+	stagerPasswordEnvVar  = "CF_STAGER_INTEGRATION_CC_PASSWORD"
 )
 
 var k8sApiUrl string
 var logger lager.Logger
 
 func TestMain(m *testing.M) {
-	logger = lib.NewLogger("debug")
+	logger = lager.NewLogger("integration")
+	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.DEBUG))
 
 	k8sApiUrl = os.Getenv(k8sApiUrlEnvVar)
 	if k8sApiUrl == "" {
@@ -38,7 +49,7 @@ func TestConnectionOK(t *testing.T) {
 	assert := assert.New(t)
 
 	// Act
-	_, err := NewStager(k8sApiUrl, "foo", logger)
+	_, err := NewStager(ConnectionConfig{Endpoint: k8sApiUrl}, "foo", logger)
 
 	// Assert
 	assert.NoError(err)
@@ -50,7 +61,7 @@ func TestConnectionNotOK(t *testing.T) {
 	badAddress := "127.0.0.1:1"
 
 	// Act
-	_, err := NewStager(badAddress, "foo", logger)
+	_, err := NewStager(ConnectionConfig{Endpoint: badAddress}, "foo", logger)
 
 	// Assert
 	assert.Error(err)
@@ -60,7 +71,7 @@ func TestGetNamespaceNotOK(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
 	badAddress := k8sApiUrl
-	stager, err := NewStager(badAddress, "foo", logger)
+	stager, err := NewStager(ConnectionConfig{Endpoint: badAddress}, "foo", logger)
 	assert.NoError(err)
 
 	// Act
@@ -75,7 +86,7 @@ func TestCreateNamespaceOK(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
 	badAddress := k8sApiUrl
-	stager, err := NewStager(badAddress, "foo", logger)
+	stager, err := NewStager(ConnectionConfig{Endpoint: badAddress}, "foo", logger)
 	assert.NoError(err)
 
 	org := uuid.NewV4().String()
@@ -83,11 +94,12 @@ func TestCreateNamespaceOK(t *testing.T) {
 
 	// Act
 	err = stager.CreateStagingNamespace(org, space)
-	namespace, err2 := stager.GetStagingNamespace(space)
+	namespace, exists, err2 := stager.GetStagingNamespace(space)
 
 	// Assert
 	assert.NoError(err)
 	assert.NoError(err2)
+	assert.True(exists)
 	assert.Equal(formatStagingNamespace(space), namespace.Name)
 
 	// Cleanup
@@ -99,19 +111,20 @@ func TestStagingTaskOk(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
 	badAddress := k8sApiUrl
-	stager, err := NewStager(badAddress, "foo", logger)
+	stager, err := NewStager(ConnectionConfig{Endpoint: badAddress}, "foo", logger)
 	assert.NoError(err)
 	org := uuid.NewV4().String()
 	space := uuid.NewV4().String()
 	err = stager.CreateStagingNamespace(org, space)
 	assert.NoError(err)
 
-	dropletId := uuid.NewV4().String()
+	// Staging guids are the app guid followed by the droplet guid
+	stagingId := uuid.NewV4().String() + "-" + strings.Replace(uuid.NewV4().String(), "-", "", -1)
 	message := uuid.NewV4().String()
 
 	stagingData := &StagingInfo{
-		DropletId:       dropletId,
-		DockerImageName: "alpine:latest",
+		Id:    stagingId,
+		Image: "alpine:latest",
 		Environment: map[string]string{
 			"MESSAGE": message,
 		},
@@ -122,18 +135,69 @@ func TestStagingTaskOk(t *testing.T) {
 	err = stager.StartStaging(stagingData, space)
 	assert.NoError(err)
 
-	job, err := stager.GetStagingTask(dropletId, space)
+	job, exists, err := stager.GetStagingTask(stagingId, space)
 
-	for ; err == nil && job.Status.Failed == 0 && job.Status.Succeeded == 0; job, err = stager.GetStagingTask(dropletId, space) {
+	for ; err == nil && exists && job.Status.Failed == 0 && job.Status.Succeeded == 0; job, exists, err = stager.GetStagingTask(stagingId, space) {
 		time.Sleep(1 * time.Second)
 	}
 
 	// Assert
 	assert.NoError(err)
-	assert.Equal(1, job.Status.Succeeded)
-	assert.Equal(0, job.Status.Failed)
+	assert.True(exists)
+	assert.Equal(int32(1), job.Status.Succeeded)
+	assert.Equal(int32(0), job.Status.Failed)
 
 	// Cleanup
 	err = stager.RemoveStagingNamespace(space)
 	assert.NoError(err)
 }
+
+func TestStagerAPIStagesAndStops(t *testing.T) {
+	stagerUrl, space := os.Getenv(stagerUrlEnvVar), os.Getenv(stagerNamespaceEnvVar)
+	if stagerUrl == "" || space == "" {
+		t.Skipf("Set %s and %s to test a running stager's API.", stagerUrlEnvVar, stagerNamespaceEnvVar)
+	}
+
+	// Arrange
+	assert := assert.New(t)
+	stager, err := NewStager(ConnectionConfig{Endpoint: k8sApiUrl}, "foo", logger)
+	assert.NoError(err)
+	api, err := client.New(client.Config{
+		URL: stagerUrl,
+		AuthInfo: client.BasicAuth(func() (string, string, error) {
+			return os.Getenv(stagerUsernameEnvVar), os.Getenv(stagerPasswordEnvVar), nil
+		}),
+	})
+	assert.NoError(err)
+
+	appId := uuid.NewV4().String()
+	stagingId := appId + "-" + strings.Replace(uuid.NewV4().String(), "-", "", -1)
+	request := &model.StagingRequestFromCC{
+		AppID:     appId,
+		LogGUID:   appId,
+		Lifecycle: "buildpack",
+		LifecycleData: map[string]interface{}{
+			"app_bits_download_uri": "https://blobstore.example.com/app",
+			"droplet_upload_uri":    "https://blobstore.example.com/droplet",
+			"buildpacks":            []interface{}{},
+			"stack":                 "cflinuxfs2",
+		},
+		MemoryMb: 1024,
+		DiskMb:   4096,
+		Timeout:  900,
+	}
+
+	// Act
+	_, accepted, stageErr := api.Operations.Stage(operations.NewStageParams().
+		WithStagingGUID(stagingId).
+		WithStagingRequest(request))
+	_, jobExists, jobErr := stager.GetStagingTask(stagingId, space)
+	_, stopErr := api.Operations.StopStaging(operations.NewStopStagingParams().WithStagingGUID(stagingId))
+
+	// Assert
+	assert.NoError(stageErr)
+	assert.NotNil(accepted)
+	assert.NoError(jobErr)
+	assert.True(jobExists)
+	assert.NoError(stopErr)
+}
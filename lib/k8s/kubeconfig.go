@@ -0,0 +1,264 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	clientcmdapi "k8s.io/kubernetes/pkg/client/unversioned/clientcmd/api"
+)
+
+// The parts of the kubeconfig file format the stager uses. The client
+// library we vendor only knows the internal representation.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData []byte `json:"certificate-authority-data"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string         `json:"name"`
+		User kubeconfigUser `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+type kubeconfigUser struct {
+	ClientCertificate     string                           `json:"client-certificate"`
+	ClientCertificateData []byte                           `json:"client-certificate-data"`
+	ClientKey             string                           `json:"client-key"`
+	ClientKeyData         []byte                           `json:"client-key-data"`
+	Token                 string                           `json:"token"`
+	TokenFile             string                           `json:"tokenFile"`
+	Username              string                           `json:"username"`
+	Password              string                           `json:"password"`
+	AuthProvider          *clientcmdapi.AuthProviderConfig `json:"auth-provider"`
+	Exec                  *execConfig                      `json:"exec"`
+}
+
+// execConfig runs a command that prints an ExecCredential with a bearer
+// token, like the credential plugins of kubectl.
+type execConfig struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	APIVersion string   `json:"apiVersion"`
+	Env        []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"env"`
+}
+
+type execCredential struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Status     *struct {
+		Token               string     `json:"token"`
+		ExpirationTimestamp *time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// kubeconfigRESTConfig returns the client configuration for a context of a
+// kubeconfig file, the current one if contextName is empty. Relative paths
+// in the file are relative to the file.
+func kubeconfigRESTConfig(path, contextName string) (*restclient.Config, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no kubeconfig file given")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config kubeconfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %s", path, err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	var clusterName, userName string
+	contextFound := false
+	for _, context := range config.Contexts {
+		if context.Name == contextName {
+			clusterName, userName, contextFound = context.Context.Cluster, context.Context.User, true
+		}
+	}
+	if !contextFound {
+		return nil, fmt.Errorf("context %q not found in kubeconfig %s", contextName, path)
+	}
+
+	restConfig := &restclient.Config{}
+	dir := filepath.Dir(path)
+
+	clusterFound := false
+	for _, cluster := range config.Clusters {
+		if cluster.Name == clusterName {
+			clusterFound = true
+			restConfig.Host = cluster.Cluster.Server
+			restConfig.Insecure = cluster.Cluster.InsecureSkipTLSVerify
+			restConfig.CAFile = resolvePath(dir, cluster.Cluster.CertificateAuthority)
+			restConfig.CAData = cluster.Cluster.CertificateAuthorityData
+		}
+	}
+	if !clusterFound {
+		return nil, fmt.Errorf("cluster %q of context %q not found in kubeconfig %s", clusterName, contextName, path)
+	}
+
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+
+		restConfig.CertFile = resolvePath(dir, user.User.ClientCertificate)
+		restConfig.CertData = user.User.ClientCertificateData
+		restConfig.KeyFile = resolvePath(dir, user.User.ClientKey)
+		restConfig.KeyData = user.User.ClientKeyData
+		restConfig.BearerToken = user.User.Token
+		restConfig.Username = user.User.Username
+		restConfig.Password = user.User.Password
+		restConfig.AuthProvider = user.User.AuthProvider
+
+		if restConfig.BearerToken == "" && user.User.TokenFile != "" {
+			token, err := ioutil.ReadFile(resolvePath(dir, user.User.TokenFile))
+			if err != nil {
+				return nil, err
+			}
+			restConfig.BearerToken = strings.TrimSpace(string(token))
+		}
+
+		if user.User.Exec != nil {
+			credentials := &execCredentials{config: user.User.Exec, dir: dir}
+			restConfig.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+				return &execRoundTripper{next: rt, credentials: credentials}
+			}
+		}
+	}
+
+	return restConfig, nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// execCredentials runs the credential command of a kubeconfig user and
+// caches its token until it expires.
+type execCredentials struct {
+	config *execConfig
+	dir    string
+
+	lock       sync.Mutex
+	token      string
+	expiration time.Time
+}
+
+func (c *execCredentials) Token() (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.token != "" && (c.expiration.IsZero() || time.Now().Before(c.expiration)) {
+		return c.token, nil
+	}
+
+	command := c.config.Command
+	if strings.Contains(command, string(filepath.Separator)) {
+		command = resolvePath(c.dir, command)
+	}
+
+	cmd := exec.Command(command, c.config.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range c.config.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running credential command %s: %s: %s", c.config.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var credential execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &credential); err != nil {
+		return "", fmt.Errorf("invalid output of credential command %s: %s", c.config.Command, err)
+	}
+
+	if credential.Status == nil || credential.Status.Token == "" {
+		return "", fmt.Errorf("credential command %s didn't return a token", c.config.Command)
+	}
+
+	c.token = credential.Status.Token
+	c.expiration = time.Time{}
+	if credential.Status.ExpirationTimestamp != nil {
+		c.expiration = *credential.Status.ExpirationTimestamp
+	}
+
+	return c.token, nil
+}
+
+// Invalidate drops a token the API rejected.
+func (c *execCredentials) Invalidate(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+type execRoundTripper struct {
+	next        http.RoundTripper
+	credentials *execCredentials
+}
+
+func (t *execRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.credentials.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := new(http.Request)
+	*authorized = *req
+	authorized.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		authorized.Header[key] = values
+	}
+	authorized.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := t.next.RoundTrip(authorized)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The next request runs the command again
+		t.credentials.Invalidate(token)
+	}
+
+	return resp, err
+}
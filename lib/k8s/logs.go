@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	goerrors "errors"
+	"io"
+
+	"github.com/cf-furnace/pkg/cloudfoundry"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+const stagingContainerName = "staging"
+
+var (
+	// ErrStagingTaskNotFound is returned when neither a job nor a pod exists
+	// for a staging id.
+	ErrStagingTaskNotFound = goerrors.New("staging task not found")
+	// ErrStagingPodPending is returned when the staging job exists but its
+	// pod hasn't been scheduled or started yet.
+	ErrStagingPodPending = goerrors.New("staging pod not started yet")
+	// ErrStagingPodGone is returned when the staging job has finished and its
+	// pod has already been removed.
+	ErrStagingPodGone = goerrors.New("staging pod is gone")
+)
+
+type LogOptions struct {
+	Follow       bool
+	SinceSeconds *int64
+	TailLines    *int64
+}
+
+// StreamStagingLogs opens the log stream of the staging container. The
+// caller is responsible for closing it.
+func (s *Stager) StreamStagingLogs(id, space string, options *LogOptions) (io.ReadCloser, error) {
+	pod, err := s.getStagingPod(id, space)
+	if err != nil {
+		return nil, err
+	}
+
+	if pod.Status.Phase == api.PodPending {
+		return nil, ErrStagingPodPending
+	}
+
+	return s.StreamPodLogs(pod, options)
+}
+
+// StreamPodLogs opens the log stream of the staging container of a pod.
+func (s *Stager) StreamPodLogs(pod *api.Pod, options *LogOptions) (io.ReadCloser, error) {
+	return s.k8sClient.Pods(pod.Namespace).GetLogs(pod.Name, &api.PodLogOptions{
+		Container:    stagingContainerName,
+		Follow:       options.Follow,
+		SinceSeconds: options.SinceSeconds,
+		TailLines:    options.TailLines,
+	}).Stream()
+}
+
+// getStagingPod finds the most recently created pod of a staging job.
+func (s *Stager) getStagingPod(id, space string) (*api.Pod, error) {
+	namespace := formatStagingNamespace(space)
+	taskGuid, err := cloudfoundry.NewTaskGuid(id)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"cloudfoundry.org/task-guid": taskGuid.ShortenedGuid()})
+
+	var pods []*api.Pod
+	if s.cache.HasSynced() {
+		pods = s.cache.ListPods(namespace, selector)
+	} else {
+		podList, err := s.k8sClient.Pods(namespace).List(api.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range podList.Items {
+			pods = append(pods, &podList.Items[idx])
+		}
+	}
+
+	var latest *api.Pod
+	for _, pod := range pods {
+		if latest == nil || latest.CreationTimestamp.Before(pod.CreationTimestamp) {
+			latest = pod
+		}
+	}
+
+	if latest != nil {
+		return latest, nil
+	}
+
+	job, jobExists, err := s.GetStagingTask(id, space)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jobExists {
+		return nil, ErrStagingTaskNotFound
+	}
+
+	if job.Status.Succeeded > 0 || job.Status.Failed > 0 {
+		return nil, ErrStagingPodGone
+	}
+
+	return nil, ErrStagingPodPending
+}
@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/kubernetes/pkg/api"
+	_ "k8s.io/kubernetes/pkg/api/install"
+	"k8s.io/kubernetes/pkg/api/v1"
+	_ "k8s.io/kubernetes/pkg/apis/batch/install"
+	batchv1 "k8s.io/kubernetes/pkg/apis/batch/v1"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// Formats staging objects are rendered in
+const (
+	RenderJSON = "json"
+	RenderYAML = "yaml"
+)
+
+// redactedValue replaces secrets in rendered objects.
+const redactedValue = "REDACTED"
+
+// RenderStagingObjects encodes the objects a staging creates as a v1 List,
+// the way kubectl reads them. The private key in a staging secret is
+// redacted in place.
+func RenderStagingObjects(objects []runtime.Object, format string) ([]byte, error) {
+	codec := api.Codecs.LegacyCodec(v1.SchemeGroupVersion, batchv1.SchemeGroupVersion)
+
+	list := struct {
+		Kind       string            `json:"kind"`
+		APIVersion string            `json:"apiVersion"`
+		Items      []json.RawMessage `json:"items"`
+	}{
+		Kind:       "List",
+		APIVersion: "v1",
+		Items:      []json.RawMessage{},
+	}
+
+	for _, object := range objects {
+		if secret, ok := object.(*api.Secret); ok {
+			redactStagingSecret(secret)
+		}
+
+		encoded, err := runtime.Encode(codec, object)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = append(list.Items, json.RawMessage(encoded))
+	}
+
+	encoded, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case RenderJSON:
+		return encoded, nil
+	case RenderYAML:
+		return yaml.JSONToYAML(encoded)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, RenderJSON, RenderYAML)
+	}
+}
+
+func redactStagingSecret(secret *api.Secret) {
+	if _, ok := secret.Data[CompletionClientKeyKey]; ok {
+		secret.Data[CompletionClientKeyKey] = []byte(redactedValue)
+	}
+}
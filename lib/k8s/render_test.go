@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func TestRenderStagingObjectsRedactsClientKey(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stagingInfo := &StagingInfo{
+		Id:                   "9a8b7c6d-1234-5678-9abc-def012345678-0f1e2d3c4b5a69788796a5b4c3d2e1f0",
+		Image:                "cffurnace/stager",
+		Environment:          map[string]string{"VCAP_APPLICATION": "{}"},
+		Stack:                "cflinuxfs2",
+		CompletionClientCert: "certificate",
+		CompletionClientKey:  "private key",
+	}
+	secret, err := NewStagingSecret(stagingInfo, "furnace-staging", "stager-0")
+	assert.NoError(err)
+	job, err := NewStagingJob(stagingInfo, "furnace-staging", "stager-0")
+	assert.NoError(err)
+
+	// Act
+	rendered, err := RenderStagingObjects([]runtime.Object{NewStagingNamespace("cf-furnace", "furnace-staging", "stager-0"), secret, job}, RenderYAML)
+
+	// Assert
+	assert.NoError(err)
+	yaml := string(rendered)
+	assert.Contains(yaml, "kind: List")
+	assert.Contains(yaml, "kind: Namespace")
+	assert.Contains(yaml, "kind: Secret")
+	assert.Contains(yaml, "apiVersion: batch/v1")
+	assert.Contains(yaml, "kind: Job")
+	assert.Contains(yaml, "namespace: cf-staging-furnace-staging")
+	assert.Contains(yaml, "client.key: "+base64.StdEncoding.EncodeToString([]byte("REDACTED")))
+	assert.NotContains(yaml, base64.StdEncoding.EncodeToString([]byte("private key")))
+	assert.True(strings.Index(yaml, "CF_APP_PACKAGE") < strings.Index(yaml, "VCAP_APPLICATION"))
+}
+
+func TestRenderStagingObjectsRejectsUnknownFormat(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := RenderStagingObjects([]runtime.Object{}, "xml")
+
+	// Assert
+	assert.Error(err)
+}
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"time"
+
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+// States of a staging, as far as its job tells
+const (
+	StagingStatePending   = "pending"
+	StagingStateRunning   = "running"
+	StagingStateSucceeded = "succeeded"
+	StagingStateFailed    = "failed"
+)
+
+// StagingSummary describes a staging job for operators.
+type StagingSummary struct {
+	StagingGuid string     `json:"staging_guid"`
+	AppGuid     string     `json:"app_guid,omitempty"`
+	Namespace   string     `json:"namespace"`
+	Job         string     `json:"job"`
+	StagerId    string     `json:"stager_id"`
+	State       string     `json:"state"`
+	Stack       string     `json:"stack,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	LogGuid     string     `json:"log_guid,omitempty"`
+	RequestId   string     `json:"request_id,omitempty"`
+	Created     time.Time  `json:"created"`
+	Started     *time.Time `json:"started,omitempty"`
+	Completed   *time.Time `json:"completed,omitempty"`
+	Attempts    int32      `json:"attempts"`
+}
+
+// SummarizeStaging describes the staging a job runs.
+func SummarizeStaging(job *batch.Job) StagingSummary {
+	summary := StagingSummary{
+		AppGuid:   job.Annotations[AppGuidAnnotation],
+		Namespace: job.Namespace,
+		Job:       job.Name,
+		StagerId:  job.Labels[StagerIdLabel],
+		State:     StagingStatePending,
+		Stack:     job.Annotations[StackAnnotation],
+		LogGuid:   job.Annotations[LogGuidAnnotation],
+		RequestId: job.Annotations[RequestIdAnnotation],
+		Created:   job.CreationTimestamp.Time,
+		Attempts:  job.Status.Active + job.Status.Succeeded + job.Status.Failed,
+	}
+
+	// The job name is shortened, the staging guid is only kept in the
+	// environment of the staging container
+	for _, container := range job.Spec.Template.Spec.Containers {
+		summary.Image = container.Image
+
+		for _, env := range container.Env {
+			if env.Name == "CF_TASK_ID" {
+				summary.StagingGuid = env.Value
+			}
+		}
+	}
+
+	if job.Status.StartTime != nil {
+		summary.Started = &job.Status.StartTime.Time
+	}
+	if job.Status.CompletionTime != nil {
+		summary.Completed = &job.Status.CompletionTime.Time
+	}
+
+	// Failed pods are replaced as long as the job runs
+	switch {
+	case job.Status.Succeeded > 0:
+		summary.State = StagingStateSucceeded
+	case job.Status.Active > 0:
+		summary.State = StagingStateRunning
+	case job.Status.Failed > 0:
+		summary.State = StagingStateFailed
+	}
+
+	return summary
+}
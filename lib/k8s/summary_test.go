@@ -0,0 +1,44 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+func TestSummarizeStagingReadsGuidFromEnvironment(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	job := &batch.Job{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "cf-staging-space",
+			Name:        "app-staging",
+			Labels:      map[string]string{StagerIdLabel: "stager-0"},
+			Annotations: map[string]string{AppGuidAnnotation: "app-guid", StackAnnotation: "cflinuxfs2"},
+		},
+		Spec: batch.JobSpec{
+			Template: api.PodTemplateSpec{
+				Spec: api.PodSpec{
+					Containers: []api.Container{{
+						Image: "cffurnace/stager",
+						Env:   []api.EnvVar{{Name: "CF_TASK_ID", Value: "app-guid-staging-guid"}},
+					}},
+				},
+			},
+		},
+		Status: batch.JobStatus{Active: 1, Failed: 1},
+	}
+
+	// Act
+	summary := SummarizeStaging(job)
+
+	// Assert
+	assert.Equal("app-guid-staging-guid", summary.StagingGuid)
+	assert.Equal("app-guid", summary.AppGuid)
+	assert.Equal("stager-0", summary.StagerId)
+	assert.Equal("cffurnace/stager", summary.Image)
+	assert.Equal(StagingStateRunning, summary.State)
+	assert.Equal(int32(2), summary.Attempts)
+}
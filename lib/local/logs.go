@@ -0,0 +1,102 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+)
+
+// followInterval is how often a followed log is checked for new output.
+const followInterval = 250 * time.Millisecond
+
+// StreamStagingLogs opens the output of a staging process. The caller is
+// responsible for closing it. Lines aren't timestamped, so SinceSeconds is
+// ignored.
+func (s *Stager) StreamStagingLogs(id, space string, options *k8s.LogOptions) (io.ReadCloser, error) {
+	s.lock.Lock()
+	_, st, err := s.lookup(id, space)
+	s.lock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, k8s.ErrStagingTaskNotFound
+	}
+
+	file, err := os.Open(filepath.Join(st.dir, logFileName))
+	if err != nil {
+		// The sandbox is removed when the staging is stopped
+		if os.IsNotExist(err) {
+			return nil, k8s.ErrStagingPodGone
+		}
+		return nil, err
+	}
+
+	if options.TailLines != nil {
+		if err := seekTail(file, *options.TailLines); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if !options.Follow {
+		return file, nil
+	}
+
+	return &followReader{file: file, exited: st.exited}, nil
+}
+
+// seekTail moves to the start of the last lines of a file.
+func seekTail(file *os.File, lines int64) error {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	offset := len(content)
+	if lines > 0 {
+		// Each line kept starts after the newline found before it
+		trimmed := bytes.TrimSuffix(content, []byte("\n"))
+		start := len(trimmed)
+		for ; lines > 0 && start >= 0; lines-- {
+			start = bytes.LastIndexByte(trimmed[:start], '\n')
+		}
+		offset = start + 1
+	}
+
+	_, err = file.Seek(int64(offset), io.SeekStart)
+	return err
+}
+
+// followReader reads a log file as it is written, until the process writing
+// it has exited.
+type followReader struct {
+	file   *os.File
+	exited <-chan struct{}
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-r.exited:
+			// Whatever was written before the process exited
+			return r.file.Read(p)
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+func (r *followReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,419 @@
+// Package local runs stagings as child processes of the stager, for
+// developing and testing the stager without a Kubernetes cluster. A staging
+// process gets the environment the staging container would get, and runs in
+// a sandbox directory of its own.
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/pkg/cloudfoundry"
+
+	"code.cloudfoundry.org/lager"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+const (
+	// logFileName is the file in the sandbox the output of a staging process
+	// is written to.
+	logFileName = "staging.log"
+
+	// credentialsDirName is the directory in the sandbox the staging secret
+	// is written to, in lieu of mounting it.
+	credentialsDirName = "completion"
+)
+
+// finishedStagingRetention is how long a staging is kept after its process
+// exits, with its job and sandbox, unless stopped before. Stagings that
+// complete are stopped by the stager right away; this removes the ones whose
+// result never arrives.
+var finishedStagingRetention = 10 * time.Minute
+
+// Stager implements K8SStagingClient with local processes. Namespaces and
+// jobs only exist in memory, each job runs one process.
+type Stager struct {
+	StagerId string
+
+	dir     string
+	command []string
+	logger  lager.Logger
+
+	lock       sync.Mutex
+	namespaces map[string]*api.Namespace
+	stagings   map[string]*staging
+}
+
+type staging struct {
+	job     *batch.Job
+	dir     string
+	process *os.Process
+	exited  chan struct{}
+}
+
+// NewStager creates a stager running stagings in sandboxes below dir. The
+// command runs a staging unless the staging job has a command of its own.
+func NewStager(dir string, command []string, stagerId string, logger lager.Logger) (*Stager, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Stager{
+		StagerId: stagerId,
+
+		dir:        dir,
+		command:    command,
+		logger:     logger.Session("local-stager"),
+		namespaces: map[string]*api.Namespace{},
+		stagings:   map[string]*staging{},
+	}, nil
+}
+
+// Run stops the staging processes that are still running when the stager
+// stops, so that none are left behind.
+func (s *Stager) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	<-signals
+
+	s.lock.Lock()
+	stopped := []<-chan struct{}{}
+	for key, st := range s.stagings {
+		stopped = append(stopped, s.stop(key, st, 0))
+	}
+	s.lock.Unlock()
+
+	for _, done := range stopped {
+		<-done
+	}
+
+	return nil
+}
+
+func (s *Stager) CreateStagingNamespace(organization, space string) error {
+	namespace := k8s.NewStagingNamespace(organization, space, s.StagerId)
+	namespace.CreationTimestamp = unversioned.Now()
+
+	if err := os.MkdirAll(filepath.Join(s.dir, namespace.Name), 0700); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.namespaces[namespace.Name] = namespace
+
+	return nil
+}
+
+func (s *Stager) GetStagingNamespace(space string) (*api.Namespace, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	namespace, exists := s.namespaces[stagingNamespace(space)]
+	return namespace, exists, nil
+}
+
+// RemoveStagingNamespace stops the stagings of a space right away, like
+// deleting their namespace does.
+func (s *Stager) RemoveStagingNamespace(space string) error {
+	name := stagingNamespace(space)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, st := range s.stagings {
+		if st.job.Namespace == name {
+			s.stop(key, st, 0)
+		}
+	}
+	delete(s.namespaces, name)
+
+	return nil
+}
+
+func (s *Stager) StartStaging(stagingData *k8s.StagingInfo, space string) error {
+	secret, err := k8s.NewStagingSecret(stagingData, space, s.StagerId)
+	if err != nil {
+		return err
+	}
+
+	job, err := k8s.NewStagingJob(stagingData, space, s.StagerId)
+	if err != nil {
+		return err
+	}
+
+	key := job.Namespace + "/" + job.Name
+	container := job.Spec.Template.Spec.Containers[0]
+
+	command := container.Command
+	if len(command) == 0 {
+		command = s.command
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("no command to run staging %s", stagingData.Id)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, exists := s.stagings[key]; exists {
+		return k8s.ErrStagingTaskExists
+	}
+
+	dir := filepath.Join(s.dir, job.Namespace, job.Name)
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0700); err != nil {
+		return err
+	}
+
+	if secret != nil {
+		if err := writeSecret(filepath.Join(dir, credentialsDirName), secret); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, logFileName))
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = sandboxEnvironment(container.Env, dir)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// In a process group of its own, so that stopping it stops its children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		os.RemoveAll(dir)
+		return err
+	}
+
+	now := unversioned.Now()
+	job.CreationTimestamp = now
+	job.Status.StartTime = &now
+	job.Status.Active = 1
+
+	st := &staging{
+		job:     job,
+		dir:     dir,
+		process: cmd.Process,
+		exited:  make(chan struct{}),
+	}
+	s.stagings[key] = st
+
+	s.logger.Info("Started staging process.", lager.Data{
+		"StagingId": stagingData.Id,
+		"Pid":       cmd.Process.Pid,
+		"Dir":       dir,
+	})
+
+	go s.wait(key, cmd, logFile, st)
+
+	return nil
+}
+
+// wait records the outcome of a staging process in its job once it exits,
+// and removes the staging once it's been kept for finishedStagingRetention.
+func (s *Stager) wait(key string, cmd *exec.Cmd, logFile *os.File, st *staging) {
+	err := cmd.Wait()
+	logFile.Close()
+
+	s.lock.Lock()
+	now := unversioned.Now()
+	st.job.Status.Active = 0
+	st.job.Status.CompletionTime = &now
+	if err == nil {
+		st.job.Status.Succeeded = 1
+	} else {
+		st.job.Status.Failed = 1
+	}
+	s.lock.Unlock()
+
+	close(st.exited)
+
+	s.logger.Info("Staging process exited.", lager.Data{
+		"Job":   st.job.Name,
+		"Error": fmt.Sprint(err),
+	})
+
+	time.AfterFunc(finishedStagingRetention, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		// Unless it was stopped, or started again, in the meantime
+		if s.stagings[key] == st {
+			s.stop(key, st, 0)
+		}
+	})
+}
+
+func (s *Stager) GetStagingTask(id, space string) (*batch.Job, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, st, err := s.lookup(id, space)
+	if err != nil || st == nil {
+		return nil, false, err
+	}
+
+	// The status of the job changes when the process exits
+	job := *st.job
+	return &job, true, nil
+}
+
+func (s *Stager) ListStagingTasks(space string) ([]*batch.Job, error) {
+	namespace := stagingNamespace(space)
+	jobs := []*batch.Job{}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, st := range s.stagings {
+		if st.job.Namespace == namespace {
+			job := *st.job
+			jobs = append(jobs, &job)
+		}
+	}
+
+	k8s.SortStagingJobs(jobs)
+
+	return jobs, nil
+}
+
+// StopStaging forgets the staging right away, like deleting its job does.
+// Its process is sent SIGTERM, and SIGKILL once the grace period is over.
+func (s *Stager) StopStaging(id, space string, gracePeriod int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key, st, err := s.lookup(id, space)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return k8s.ErrStagingTaskNotFound
+	}
+
+	s.stop(key, st, time.Duration(gracePeriod)*time.Second)
+
+	return nil
+}
+
+// stop has to be called with the lock held. The returned channel is closed
+// once the process has exited and its sandbox is removed.
+func (s *Stager) stop(key string, st *staging, gracePeriod time.Duration) <-chan struct{} {
+	delete(s.stagings, key)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		select {
+		case <-st.exited:
+			// The process is reaped, its pid may be another process's by now
+		default:
+			if gracePeriod == 0 {
+				syscall.Kill(-st.process.Pid, syscall.SIGKILL)
+				<-st.exited
+				break
+			}
+
+			syscall.Kill(-st.process.Pid, syscall.SIGTERM)
+			select {
+			case <-st.exited:
+			case <-time.After(gracePeriod):
+				select {
+				case <-st.exited:
+				default:
+					syscall.Kill(-st.process.Pid, syscall.SIGKILL)
+					<-st.exited
+				}
+			}
+		}
+
+		os.RemoveAll(st.dir)
+	}()
+
+	return done
+}
+
+// RecordStagingEvent logs the event, there is nothing to record it on.
+func (s *Stager) RecordStagingEvent(id, space, eventType, reason, message string) error {
+	s.logger.Info("Staging event.", lager.Data{
+		"StagingId": id,
+		"Type":      eventType,
+		"Reason":    reason,
+		"Message":   message,
+	})
+
+	return nil
+}
+
+// lookup has to be called with the lock held. The staging is nil if it
+// doesn't exist.
+func (s *Stager) lookup(id, space string) (string, *staging, error) {
+	taskGuid, err := cloudfoundry.NewTaskGuid(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := stagingNamespace(space) + "/" + taskGuid.ShortenedGuid()
+	return key, s.stagings[key], nil
+}
+
+// sandboxEnvironment is the environment of the staging container, with the
+// sandbox as home and temporary directory. Only PATH is taken over from the
+// stager.
+func sandboxEnvironment(containerEnv []api.EnvVar, dir string) []string {
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + filepath.Join(dir, "tmp"),
+	}
+
+	for _, envVar := range containerEnv {
+		value := envVar.Value
+		// Paths into the secret volume point into the sandbox instead
+		if strings.HasPrefix(value, k8s.CompletionCredentialsDir+"/") {
+			value = filepath.Join(dir, credentialsDirName, strings.TrimPrefix(value, k8s.CompletionCredentialsDir+"/"))
+		}
+
+		env = append(env, envVar.Name+"="+value)
+	}
+
+	return env
+}
+
+// writeSecret writes each key of a secret to a file of its own, like a
+// secret volume has them.
+func writeSecret(dir string, secret *api.Secret) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for key, value := range secret.Data {
+		if err := ioutil.WriteFile(filepath.Join(dir, key), value, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stagingNamespace(space string) string {
+	return k8s.NewStagingNamespace("", space, "").Name
+}
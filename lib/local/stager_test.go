@@ -0,0 +1,199 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+const stagingId = "9a8b7c6d-1234-5678-9abc-def012345678-0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+
+func newTestStager(t *testing.T, script string) (*Stager, func()) {
+	dir, err := ioutil.TempDir("", "local-stager-test")
+	assert.NoError(t, err)
+
+	stager, err := NewStager(dir, []string{"/bin/sh", "-c", script}, "stager-0", lager.NewLogger("test"))
+	assert.NoError(t, err)
+
+	return stager, func() { os.RemoveAll(dir) }
+}
+
+func newStagingInfo() *k8s.StagingInfo {
+	return &k8s.StagingInfo{Id: stagingId, Environment: map[string]string{}}
+}
+
+func waitForCompletion(stager *Stager) *batch.Job {
+	for attempt := 0; attempt < 100; attempt++ {
+		job, _, _ := stager.GetStagingTask(stagingId, "furnace-staging")
+		if job != nil && job.Status.CompletionTime != nil {
+			return job
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return nil
+}
+
+func TestStartStagingRunsProcessWithStagingEnvironment(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, cleanup := newTestStager(t, `echo "$CF_TASK_ID $MESSAGE"; test "$HOME" = "$PWD"`)
+	defer cleanup()
+
+	// Act
+	err := stager.StartStaging(&k8s.StagingInfo{
+		Id:          stagingId,
+		Environment: map[string]string{"MESSAGE": "hello"},
+	}, "furnace-staging")
+	job := waitForCompletion(stager)
+
+	// Assert
+	assert.NoError(err)
+	if assert.NotNil(job) {
+		assert.Equal(int32(1), job.Status.Succeeded)
+		assert.Equal(k8s.StagingStateSucceeded, k8s.SummarizeStaging(job).State)
+	}
+
+	logs, err := stager.StreamStagingLogs(stagingId, "furnace-staging", &k8s.LogOptions{})
+	if assert.NoError(err) {
+		defer logs.Close()
+		content, _ := ioutil.ReadAll(logs)
+		assert.Equal(stagingId+" hello\n", string(content))
+	}
+}
+
+func TestStartStagingWritesCompletionCredentialsToSandbox(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, cleanup := newTestStager(t, `cat "$CF_COMPLETION_CLIENT_KEY_FILE"; test "${CF_COMPLETION_CA_FILE#$HOME/}" = completion/ca.crt`)
+	defer cleanup()
+	stagingInfo := newStagingInfo()
+	stagingInfo.CompletionClientCert = "certificate"
+	stagingInfo.CompletionClientKey = "private key"
+	stagingInfo.CompletionServerCA = "server ca"
+
+	// Act
+	err := stager.StartStaging(stagingInfo, "furnace-staging")
+	job := waitForCompletion(stager)
+
+	// Assert
+	assert.NoError(err)
+	if assert.NotNil(job) {
+		assert.Equal(int32(1), job.Status.Succeeded)
+	}
+
+	logs, err := stager.StreamStagingLogs(stagingId, "furnace-staging", &k8s.LogOptions{})
+	if assert.NoError(err) {
+		defer logs.Close()
+		content, _ := ioutil.ReadAll(logs)
+		assert.Equal("private key", string(content))
+	}
+}
+
+func TestStartStagingRecordsFailure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, cleanup := newTestStager(t, "exit 3")
+	defer cleanup()
+
+	// Act
+	err := stager.StartStaging(newStagingInfo(), "furnace-staging")
+	job := waitForCompletion(stager)
+	again := stager.StartStaging(newStagingInfo(), "furnace-staging")
+
+	// Assert
+	assert.NoError(err)
+	if assert.NotNil(job) {
+		assert.Equal(int32(1), job.Status.Failed)
+	}
+	assert.Equal(k8s.ErrStagingTaskExists, again)
+}
+
+func TestStreamStagingLogsFollowsUntilExit(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, cleanup := newTestStager(t, "echo one; echo two; sleep 0.3; echo three")
+	defer cleanup()
+	err := stager.StartStaging(newStagingInfo(), "furnace-staging")
+	assert.NoError(err)
+	tail := int64(1)
+
+	// Act
+	time.Sleep(100 * time.Millisecond)
+	logs, err := stager.StreamStagingLogs(stagingId, "furnace-staging", &k8s.LogOptions{Follow: true, TailLines: &tail})
+
+	// Assert
+	if assert.NoError(err) {
+		defer logs.Close()
+		content, _ := ioutil.ReadAll(logs)
+		assert.Equal("two\nthree\n", string(content))
+	}
+}
+
+func TestStopStagingKillsProcessAndRemovesSandbox(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	stager, cleanup := newTestStager(t, "trap '' TERM; sleep 30")
+	defer cleanup()
+	err := stager.StartStaging(newStagingInfo(), "furnace-staging")
+	assert.NoError(err)
+	jobs, _ := stager.ListStagingTasks("furnace-staging")
+	sandbox := filepath.Join(stager.dir, jobs[0].Namespace, jobs[0].Name)
+
+	// Act
+	err = stager.StopStaging(stagingId, "furnace-staging", 0)
+	_, exists, _ := stager.GetStagingTask(stagingId, "furnace-staging")
+	_, logsErr := stager.StreamStagingLogs(stagingId, "furnace-staging", &k8s.LogOptions{})
+	notFound := stager.StopStaging(stagingId, "furnace-staging", 0)
+
+	// Assert
+	assert.NoError(err)
+	assert.False(exists)
+	assert.Equal(k8s.ErrStagingTaskNotFound, logsErr)
+	assert.Equal(k8s.ErrStagingTaskNotFound, notFound)
+	for attempt := 0; attempt < 100; attempt++ {
+		if _, err := os.Stat(sandbox); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("sandbox %s was not removed", sandbox)
+}
+
+func TestFinishedStagingIsRemovedAfterRetention(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	finishedStagingRetention = 500 * time.Millisecond
+	defer func() { finishedStagingRetention = 10 * time.Minute }()
+	stager, cleanup := newTestStager(t, "exit 0")
+	defer cleanup()
+	err := stager.StartStaging(newStagingInfo(), "furnace-staging")
+	assert.NoError(err)
+	jobs, _ := stager.ListStagingTasks("furnace-staging")
+	sandbox := filepath.Join(stager.dir, jobs[0].Namespace, jobs[0].Name)
+
+	// Act
+	job := waitForCompletion(stager)
+	for attempt := 0; attempt < 100; attempt++ {
+		if _, err := os.Stat(sandbox); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_, exists, _ := stager.GetStagingTask(stagingId, "furnace-staging")
+
+	// Assert
+	assert.NotNil(job)
+	assert.False(exists)
+	_, statErr := os.Stat(sandbox)
+	assert.True(os.IsNotExist(statErr))
+	assert.NoError(stager.StartStaging(newStagingInfo(), "furnace-staging"))
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// NewLevelHandler reports the log level of sink on GET and changes it to the
+// level named in the body of a PUT or POST.
+func NewLevelHandler(sink *lager.ReconfigurableSink, logger lager.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT", "POST":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			level, err := ParseLevel(string(body))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			sink.SetMinLevel(level)
+			logger.Info("Log level changed.", lager.Data{"level": LevelName(level)})
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, LevelName(sink.GetMinLevel()))
+	})
+}
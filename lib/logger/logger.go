@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"code.cloudfoundry.org/lager"
 )
@@ -14,27 +16,119 @@ const (
 	FATAL = "fatal"
 )
 
-//NewLogger initializes a new logger
-func NewLogger(level string) lager.Logger {
-	var logger = lager.NewLogger("k8s-stager")
+//enum used to describe the possible log sinks
+const (
+	StdoutSink = "stdout"
+	FileSink   = "file"
+	SyslogSink = "syslog"
+)
 
-	var minLogLevel lager.LogLevel
-	switch level {
+// Config describes where logs go and from which level on.
+type Config struct {
+	Level string
+	Sinks []string
+
+	File           string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+
+	// SyslogAddress is empty for the local syslog daemon, or e.g.
+	// udp://syslog.example.com:514
+	SyslogAddress string
+	SyslogTag     string
+
+	Redactor *Redactor
+}
+
+//ParseLevel converts a level name into a lager level
+func ParseLevel(level string) (lager.LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
 	case DEBUG:
-		minLogLevel = lager.DEBUG
+		return lager.DEBUG, nil
 	case INFO:
-		minLogLevel = lager.INFO
+		return lager.INFO, nil
 	case ERROR:
-		minLogLevel = lager.ERROR
+		return lager.ERROR, nil
 	case FATAL:
-		minLogLevel = lager.FATAL
+		return lager.FATAL, nil
+	default:
+		return lager.DEBUG, fmt.Errorf("unknown log level %q, expected one of %s, %s, %s or %s", level, DEBUG, INFO, ERROR, FATAL)
+	}
+}
+
+//LevelName is the inverse of ParseLevel
+func LevelName(level lager.LogLevel) string {
+	switch level {
+	case lager.DEBUG:
+		return DEBUG
+	case lager.INFO:
+		return INFO
+	case lager.ERROR:
+		return ERROR
 	default:
-		minLogLevel = lager.DEBUG
+		return FATAL
+	}
+}
+
+//NewLogger initializes a new logger writing to the configured sinks. The
+//returned sink changes the log level at runtime.
+func NewLogger(config Config) (lager.Logger, *lager.ReconfigurableSink, error) {
+	minLogLevel, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(config.Sinks) == 0 {
+		config.Sinks = []string{StdoutSink}
+	}
+
+	sinks := multiSink{}
+	for _, name := range config.Sinks {
+		switch strings.TrimSpace(name) {
+		case StdoutSink:
+			sinks = append(sinks, lager.NewWriterSink(os.Stdout, lager.DEBUG))
+		case FileSink:
+			if config.File == "" {
+				return nil, nil, fmt.Errorf("the %s log sink needs a log file", FileSink)
+			}
+
+			file, err := NewRotatingFile(config.File, int64(config.FileMaxSizeMB)*1024*1024, config.FileMaxBackups)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			sinks = append(sinks, lager.NewWriterSink(file, lager.DEBUG))
+		case SyslogSink:
+			sink, err := NewSyslogSink(config.SyslogAddress, config.SyslogTag)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			sinks = append(sinks, sink)
+		default:
+			return nil, nil, fmt.Errorf("unknown log sink %q, expected %s, %s or %s", name, StdoutSink, FileSink, SyslogSink)
+		}
 	}
 
-	logger.RegisterSink(lager.NewWriterSink(os.Stdout, minLogLevel))
+	var sink lager.Sink = sinks
+	if config.Redactor != nil {
+		sink = NewRedactingSink(sink, config.Redactor)
+	}
+
+	reconfigurableSink := lager.NewReconfigurableSink(sink, minLogLevel)
+
+	var logger = lager.NewLogger("k8s-stager")
+	logger.RegisterSink(reconfigurableSink)
 
 	logger.Info("Log level set to:", lager.Data{"level": minLogLevel})
 
-	return logger
+	return logger, reconfigurableSink, nil
+}
+
+type multiSink []lager.Sink
+
+func (sinks multiSink) Log(log lager.LogFormat) {
+	for _, sink := range sinks {
+		sink.Log(log)
+	}
 }
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevelRejectsUnknownLevels(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := ParseLevel("verbose")
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestNewLoggerRejectsUnknownSinks(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, _, err := NewLogger(Config{Level: INFO, Sinks: []string{"kafka"}})
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestLevelHandlerChangesLevel(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	sink := lager.NewReconfigurableSink(multiSink{}, lager.INFO)
+	handler := NewLevelHandler(sink, lager.NewLogger("test"))
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("PUT", "/log-level", strings.NewReader("debug\n")))
+
+	// Assert
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("debug\n", recorder.Body.String())
+	assert.Equal(lager.DEBUG, sink.GetMinLevel())
+}
+
+func TestLevelHandlerRejectsUnknownLevel(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	sink := lager.NewReconfigurableSink(multiSink{}, lager.INFO)
+	handler := NewLevelHandler(sink, lager.NewLogger("test"))
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("PUT", "/log-level", strings.NewReader("loud")))
+
+	// Assert
+	assert.Equal(http.StatusBadRequest, recorder.Code)
+	assert.Equal(lager.INFO, sink.GetMinLevel())
+}
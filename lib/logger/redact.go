@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Redacted replaces secrets in log output
+const Redacted = "[REDACTED]"
+
+// DefaultSecretEnvPatterns match environment variables that usually hold
+// credentials. Patterns are matched against NAME=value.
+var DefaultSecretEnvPatterns = []string{
+	`(?i)^[^=]*(PASSWORD|PASSWD|SECRET|TOKEN|CREDENTIAL|PRIVATE|API_?KEY|ACCESS_?KEY)[^=]*=`,
+}
+
+var (
+	// Field names containing any of these hold secrets, whatever their value
+	secretFieldNames = []string{
+		"password",
+		"passphrase",
+		"secret",
+		"token",
+		"credential",
+		"authorization",
+		"privatekey",
+		"apikey",
+	}
+
+	urlUserinfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#@\s"]+@`)
+)
+
+// Redactor masks credentials in log data: values of secret fields, userinfo
+// in URLs and environment variables matching the secret patterns.
+type Redactor struct {
+	envPatterns []*regexp.Regexp
+}
+
+func NewRedactor(envPatterns []string) (*Redactor, error) {
+	r := &Redactor{}
+
+	for _, pattern := range envPatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		r.envPatterns = append(r.envPatterns, compiled)
+	}
+
+	return r, nil
+}
+
+// RedactData returns a redacted copy of data. Values are converted to their
+// JSON representation first, so structs are redacted by their field names.
+func (r *Redactor) RedactData(data lager.Data) lager.Data {
+	if data == nil {
+		return nil
+	}
+
+	redacted := lager.Data{}
+	for key, value := range data {
+		redacted[key] = r.redactField(key, toJSONValue(value))
+	}
+
+	return redacted
+}
+
+// RedactString masks userinfo in the URLs of a free text string.
+func (r *Redactor) RedactString(s string) string {
+	return urlUserinfo.ReplaceAllString(s, "${1}"+Redacted+"@")
+}
+
+func (r *Redactor) redactField(name string, value interface{}) interface{} {
+	if value != nil && isSecretFieldName(name) {
+		return Redacted
+	}
+
+	return r.redactValue(value)
+}
+
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.redactObject(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for idx, item := range v {
+			redacted[idx] = r.redactValue(item)
+		}
+		return redacted
+	case string:
+		return r.redactStringValue(v)
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) redactObject(object map[string]interface{}) map[string]interface{} {
+	redacted := map[string]interface{}{}
+
+	// Environment variables as CC sends them: {"name": ..., "value": ...}
+	if name, ok := object["name"].(string); ok && len(object) == 2 {
+		if value, ok := object["value"].(string); ok {
+			redacted["name"] = name
+			redacted["value"] = r.redactEnvValue(name, value)
+			return redacted
+		}
+	}
+
+	for key, value := range object {
+		if envValue, ok := value.(string); ok && isEnvName(key) {
+			redacted[key] = r.redactEnvValue(key, envValue)
+		} else {
+			redacted[key] = r.redactField(key, value)
+		}
+	}
+
+	return redacted
+}
+
+// Strings holding a JSON document, such as payloads, are redacted as such
+func (r *Redactor) redactStringValue(s string) interface{} {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var document interface{}
+		if err := json.Unmarshal([]byte(trimmed), &document); err == nil {
+			if redacted, err := json.Marshal(r.redactValue(document)); err == nil {
+				return string(redacted)
+			}
+		}
+	}
+
+	return r.RedactString(s)
+}
+
+func (r *Redactor) redactEnvValue(name, value string) interface{} {
+	for _, pattern := range r.envPatterns {
+		if pattern.MatchString(name + "=" + value) {
+			return Redacted
+		}
+	}
+
+	// Values like VCAP_SERVICES hold JSON with credentials
+	return r.redactStringValue(value)
+}
+
+func isSecretFieldName(name string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(name))
+
+	for _, secret := range secretFieldNames {
+		if strings.Contains(normalized, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys of environment maps look like CF_SPACE or VCAP_SERVICES
+func isEnvName(key string) bool {
+	if key == "" || strings.ToUpper(key) != key {
+		return false
+	}
+
+	for _, c := range key {
+		if !(c == '_' || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toJSONValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, string, bool, int, int64, float64:
+		return value
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return value
+	}
+
+	return decoded
+}
+
+type redactingSink struct {
+	sink     lager.Sink
+	redactor *Redactor
+}
+
+// NewRedactingSink redacts every log entry before passing it on to sink.
+func NewRedactingSink(sink lager.Sink, redactor *Redactor) lager.Sink {
+	return &redactingSink{sink: sink, redactor: redactor}
+}
+
+func (s *redactingSink) Log(log lager.LogFormat) {
+	log.Message = s.redactor.RedactString(log.Message)
+	log.Data = s.redactor.RedactData(log.Data)
+
+	s.sink.Log(log)
+}
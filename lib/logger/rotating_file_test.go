@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFileKeepsMaxBackups(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "logger")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stager.log")
+	file, err := NewRotatingFile(path, 10, 2)
+	assert.NoError(err)
+	defer file.Close()
+
+	// Act
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = file.Write([]byte(line))
+		assert.NoError(err)
+	}
+
+	// Assert
+	current, _ := ioutil.ReadFile(path)
+	newest, _ := ioutil.ReadFile(path + ".1")
+	oldest, _ := ioutil.ReadFile(path + ".2")
+
+	assert.Equal("fourth\n", string(current))
+	assert.Equal("third\n", string(newest))
+	assert.Equal("second\n", string(oldest))
+	_, err = os.Stat(path + ".3")
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+
+	"code.cloudfoundry.org/lager"
+)
+
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink sends log entries as JSON to syslog, with a severity that
+// matches their level. An empty address uses the local syslog daemon.
+func NewSyslogSink(address, tag string) (lager.Sink, error) {
+	network, raddr := "", ""
+
+	if address != "" {
+		syslogURL, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+
+		if syslogURL.Scheme != "udp" && syslogURL.Scheme != "tcp" {
+			return nil, fmt.Errorf("unsupported syslog address %q, expected udp://host:port or tcp://host:port", address)
+		}
+
+		network, raddr = syslogURL.Scheme, syslogURL.Host
+	}
+
+	writer, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) Log(log lager.LogFormat) {
+	message := string(log.ToJSON())
+
+	switch log.LogLevel {
+	case lager.DEBUG:
+		s.writer.Debug(message)
+	case lager.INFO:
+		s.writer.Info(message)
+	case lager.ERROR:
+		s.writer.Err(message)
+	default:
+		s.writer.Crit(message)
+	}
+}
@@ -0,0 +1,63 @@
+package loggregator
+
+import (
+	"net"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	// StagingSourceType is the source type CC and the cf CLI expect for
+	// staging output.
+	StagingSourceType = "STG"
+
+	origin = "k8s-stager"
+)
+
+// LogEmitter sends application log messages to Loggregator.
+type LogEmitter interface {
+	EmitLog(logGuid, sourceInstance, message string, messageType events.LogMessage_MessageType) error
+}
+
+type udpEmitter struct {
+	conn net.Conn
+}
+
+// NewUdpEmitter creates an emitter sending dropsonde envelopes to a metron
+// agent listening on UDP at the given address.
+func NewUdpEmitter(metronAddress string) (LogEmitter, error) {
+	conn, err := net.Dial("udp", metronAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &udpEmitter{conn: conn}, nil
+}
+
+func (e *udpEmitter) EmitLog(logGuid, sourceInstance, message string, messageType events.LogMessage_MessageType) error {
+	now := time.Now().UnixNano()
+
+	envelope := &events.Envelope{
+		Origin:    proto.String(origin),
+		EventType: events.Envelope_LogMessage.Enum(),
+		Timestamp: proto.Int64(now),
+		LogMessage: &events.LogMessage{
+			Message:        []byte(message),
+			MessageType:    messageType.Enum(),
+			Timestamp:      proto.Int64(now),
+			AppId:          proto.String(logGuid),
+			SourceType:     proto.String(StagingSourceType),
+			SourceInstance: proto.String(sourceInstance),
+		},
+	}
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = e.conn.Write(data)
+	return err
+}
@@ -0,0 +1,46 @@
+package loggregator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUdpEmitterSendsLogEnvelope(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	metron, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer metron.Close()
+
+	emitter, err := NewUdpEmitter(metron.LocalAddr().String())
+	assert.NoError(err)
+
+	// Act
+	err = emitter.EmitLog("log-guid", "0", "Creating container", events.LogMessage_OUT)
+	assert.NoError(err)
+
+	buffer := make([]byte, 65535)
+	metron.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := metron.ReadFrom(buffer)
+	assert.NoError(err)
+
+	envelope := &events.Envelope{}
+	err = envelope.Unmarshal(buffer[:n])
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(events.Envelope_LogMessage, envelope.GetEventType())
+	assert.Equal("k8s-stager", envelope.GetOrigin())
+
+	logMessage := envelope.GetLogMessage()
+	assert.Equal("Creating container", string(logMessage.GetMessage()))
+	assert.Equal(events.LogMessage_OUT, logMessage.GetMessageType())
+	assert.Equal("log-guid", logMessage.GetAppId())
+	assert.Equal("STG", logMessage.GetSourceType())
+	assert.Equal("0", logMessage.GetSourceInstance())
+}
@@ -0,0 +1,219 @@
+package loggregator
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/sonde-go/events"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// Staging is always reported as instance 0, as Diego does.
+const stagingSourceInstance = "0"
+
+// StagingPodSource tells about the staging jobs and pods of a stager and
+// streams the output of its pods. It is implemented by *k8s.Stager.
+type StagingPodSource interface {
+	AddJobEventHandler(handler k8s.ResourceEventHandler)
+	AddPodEventHandler(handler k8s.ResourceEventHandler)
+	StreamPodLogs(pod *api.Pod, options *k8s.LogOptions) (io.ReadCloser, error)
+}
+
+// StagingLogForwarder follows the staging pods of a stager and forwards
+// their output, together with container lifecycle messages, to Loggregator
+// under the log guid of the app being staged.
+type StagingLogForwarder struct {
+	source  StagingPodSource
+	emitter LogEmitter
+	logger  lager.Logger
+
+	lock      sync.Mutex
+	replaying bool
+	stopped   bool
+	tails     map[string]io.Closer
+}
+
+func NewStagingLogForwarder(source StagingPodSource, emitter LogEmitter, logger lager.Logger) *StagingLogForwarder {
+	return &StagingLogForwarder{
+		source:  source,
+		emitter: emitter,
+		logger:  logger.Session("staging-log-forwarder"),
+		tails:   map[string]io.Closer{},
+	}
+}
+
+func (f *StagingLogForwarder) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	f.logger.Info("starting")
+	defer f.logger.Info("finished")
+
+	// Objects that already exist are replayed when the handlers are added.
+	// Their lifecycle messages have been sent before, and only new output
+	// is forwarded for their pods.
+	f.setReplaying(true)
+
+	f.source.AddJobEventHandler(k8s.ResourceEventHandlerFuncs{
+		AddFunc:    f.jobAdded,
+		DeleteFunc: f.jobDeleted,
+	})
+
+	f.source.AddPodEventHandler(k8s.ResourceEventHandlerFuncs{
+		AddFunc: func(obj runtime.Object) {
+			f.podChanged(nil, obj.(*api.Pod))
+		},
+		UpdateFunc: func(oldObj, newObj runtime.Object) {
+			f.podChanged(oldObj.(*api.Pod), newObj.(*api.Pod))
+		},
+		DeleteFunc: f.podDeleted,
+	})
+
+	f.setReplaying(false)
+
+	close(ready)
+
+	<-signals
+
+	f.lock.Lock()
+	f.stopped = true
+	for _, tail := range f.tails {
+		if tail != nil {
+			tail.Close()
+		}
+	}
+	f.lock.Unlock()
+
+	return nil
+}
+
+func (f *StagingLogForwarder) setReplaying(replaying bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.replaying = replaying
+}
+
+func (f *StagingLogForwarder) isReplaying() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.replaying
+}
+
+func (f *StagingLogForwarder) jobAdded(obj runtime.Object) {
+	if f.isReplaying() {
+		return
+	}
+
+	job := obj.(*batch.Job)
+	f.emit(job.Annotations[k8s.LogGuidAnnotation], "Creating container", events.LogMessage_OUT)
+}
+
+func (f *StagingLogForwarder) jobDeleted(obj runtime.Object) {
+	job := obj.(*batch.Job)
+	f.emit(job.Annotations[k8s.LogGuidAnnotation], "Successfully destroyed container", events.LogMessage_OUT)
+}
+
+func (f *StagingLogForwarder) podChanged(oldPod, pod *api.Pod) {
+	logGuid := pod.Annotations[k8s.LogGuidAnnotation]
+	if logGuid == "" || pod.Status.Phase == api.PodPending {
+		return
+	}
+
+	if oldPod != nil && oldPod.Status.Phase != pod.Status.Phase {
+		switch pod.Status.Phase {
+		case api.PodSucceeded:
+			f.emit(logGuid, "Staging complete", events.LogMessage_OUT)
+		case api.PodFailed:
+			f.emit(logGuid, "Staging failed", events.LogMessage_ERR)
+		}
+	}
+
+	key := string(pod.UID)
+
+	f.lock.Lock()
+	_, tailing := f.tails[key]
+	if tailing || f.stopped {
+		f.lock.Unlock()
+		return
+	}
+	// Reserve the slot until the stream is open
+	f.tails[key] = nil
+	replaying := f.replaying
+	f.lock.Unlock()
+
+	options := &k8s.LogOptions{Follow: true}
+	if replaying {
+		noPastLines := int64(0)
+		options.TailLines = &noPastLines
+	} else {
+		f.emit(logGuid, "Successfully created container", events.LogMessage_OUT)
+	}
+
+	go f.tail(pod, logGuid, options)
+}
+
+func (f *StagingLogForwarder) podDeleted(obj runtime.Object) {
+	pod := obj.(*api.Pod)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if tail := f.tails[string(pod.UID)]; tail != nil {
+		tail.Close()
+	}
+	delete(f.tails, string(pod.UID))
+}
+
+func (f *StagingLogForwarder) tail(pod *api.Pod, logGuid string, options *k8s.LogOptions) {
+	logger := f.logger.Session("tail", lager.Data{"Namespace": pod.Namespace, "Pod": pod.Name})
+
+	logs, err := f.source.StreamPodLogs(pod, options)
+	if err != nil {
+		logger.Error("Error opening staging pod logs.", err)
+		f.podDeleted(pod)
+		return
+	}
+	defer logs.Close()
+
+	f.lock.Lock()
+	// The pod may have been deleted while its logs were being opened
+	if _, reserved := f.tails[string(pod.UID)]; !reserved || f.stopped {
+		f.lock.Unlock()
+		return
+	}
+	f.tails[string(pod.UID)] = logs
+	f.lock.Unlock()
+
+	reader := bufio.NewReader(logs)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); len(line) > 0 {
+			f.emit(logGuid, line, events.LogMessage_OUT)
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("Staging pod log stream ended.", lager.Data{"Reason": err.Error()})
+			}
+			return
+		}
+	}
+}
+
+func (f *StagingLogForwarder) emit(logGuid, message string, messageType events.LogMessage_MessageType) {
+	if logGuid == "" {
+		return
+	}
+
+	err := f.emitter.EmitLog(logGuid, stagingSourceInstance, message, messageType)
+	if err != nil {
+		f.logger.Error("Error emitting staging log message.", err, lager.Data{"LogGuid": logGuid})
+	}
+}
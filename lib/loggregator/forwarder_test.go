@@ -0,0 +1,222 @@
+package loggregator
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/types"
+)
+
+type logMessage struct {
+	logGuid     string
+	message     string
+	messageType events.LogMessage_MessageType
+}
+
+type fakeEmitter struct {
+	messages chan logMessage
+}
+
+func (e *fakeEmitter) EmitLog(logGuid, sourceInstance, message string, messageType events.LogMessage_MessageType) error {
+	e.messages <- logMessage{logGuid: logGuid, message: message, messageType: messageType}
+	return nil
+}
+
+// fakePodSource replays the jobs and pods it was given to the handlers added
+// to it, like the informer cache does.
+type fakePodSource struct {
+	lock        sync.Mutex
+	jobs        []*batch.Job
+	pods        []*api.Pod
+	jobHandlers []k8s.ResourceEventHandler
+	podHandlers []k8s.ResourceEventHandler
+	streams     map[string]*io.PipeWriter
+	options     map[string]*k8s.LogOptions
+}
+
+func newFakePodSource() *fakePodSource {
+	return &fakePodSource{
+		streams: map[string]*io.PipeWriter{},
+		options: map[string]*k8s.LogOptions{},
+	}
+}
+
+func (s *fakePodSource) AddJobEventHandler(handler k8s.ResourceEventHandler) {
+	s.lock.Lock()
+	s.jobHandlers = append(s.jobHandlers, handler)
+	s.lock.Unlock()
+
+	for _, job := range s.jobs {
+		handler.OnAdd(job)
+	}
+}
+
+func (s *fakePodSource) AddPodEventHandler(handler k8s.ResourceEventHandler) {
+	s.lock.Lock()
+	s.podHandlers = append(s.podHandlers, handler)
+	s.lock.Unlock()
+
+	for _, pod := range s.pods {
+		handler.OnAdd(pod)
+	}
+}
+
+func (s *fakePodSource) StreamPodLogs(pod *api.Pod, options *k8s.LogOptions) (io.ReadCloser, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reader, writer := io.Pipe()
+	s.streams[pod.Name] = writer
+	s.options[pod.Name] = options
+	return reader, nil
+}
+
+// stream waits for the logs of a pod to be opened.
+func (s *fakePodSource) stream(name string) (*io.PipeWriter, *k8s.LogOptions) {
+	for attempt := 0; attempt < 100; attempt++ {
+		s.lock.Lock()
+		writer, options := s.streams[name], s.options[name]
+		s.lock.Unlock()
+
+		if writer != nil {
+			return writer, options
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil, nil
+}
+
+func (s *fakePodSource) handlers() ([]k8s.ResourceEventHandler, []k8s.ResourceEventHandler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.jobHandlers, s.podHandlers
+}
+
+func newStagingJob(name string) *batch.Job {
+	job := &batch.Job{}
+	job.Name = name
+	job.Annotations = map[string]string{k8s.LogGuidAnnotation: "log-guid"}
+	return job
+}
+
+func newStagingPod(name string, phase api.PodPhase) *api.Pod {
+	pod := &api.Pod{}
+	pod.Name = name
+	pod.UID = types.UID(name + "-uid")
+	pod.Annotations = map[string]string{k8s.LogGuidAnnotation: "log-guid"}
+	pod.Status.Phase = phase
+	return pod
+}
+
+// runForwarder starts a forwarder and returns a function stopping it.
+func runForwarder(t *testing.T, source StagingPodSource, emitter LogEmitter) func() {
+	forwarder := NewStagingLogForwarder(source, emitter, lager.NewLogger("test"))
+	signals := make(chan os.Signal)
+	ready := make(chan struct{})
+	go forwarder.Run(signals, ready)
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+		t.Fatal("forwarder did not become ready")
+	}
+
+	return func() { signals <- os.Interrupt }
+}
+
+func nextMessage(t *testing.T, emitter *fakeEmitter) logMessage {
+	select {
+	case message := <-emitter.messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message was emitted")
+		return logMessage{}
+	}
+}
+
+func TestStagingLogForwarderSkipsLifecycleMessagesOfExistingStagings(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	source := newFakePodSource()
+	source.jobs = []*batch.Job{newStagingJob("staging")}
+	source.pods = []*api.Pod{newStagingPod("staging-pod", api.PodRunning)}
+	emitter := &fakeEmitter{messages: make(chan logMessage, 10)}
+
+	// Act
+	stop := runForwarder(t, source, emitter)
+	defer stop()
+	stream, options := source.stream("staging-pod")
+	stream.Write([]byte("new output\n"))
+
+	// Assert
+	if assert.NotNil(options) && assert.NotNil(options.TailLines) {
+		assert.True(options.Follow)
+		assert.Equal(int64(0), *options.TailLines)
+	}
+	assert.Equal(logMessage{"log-guid", "new output", events.LogMessage_OUT}, nextMessage(t, emitter))
+	assert.Empty(emitter.messages)
+}
+
+func TestStagingLogForwarderTailsNewStagingPods(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	source := newFakePodSource()
+	emitter := &fakeEmitter{messages: make(chan logMessage, 10)}
+	stop := runForwarder(t, source, emitter)
+	defer stop()
+	jobHandlers, podHandlers := source.handlers()
+
+	// Act
+	jobHandlers[0].OnAdd(newStagingJob("staging"))
+	podHandlers[0].OnAdd(newStagingPod("staging-pod", api.PodPending))
+	podHandlers[0].OnUpdate(newStagingPod("staging-pod", api.PodPending), newStagingPod("staging-pod", api.PodRunning))
+	stream, options := source.stream("staging-pod")
+	stream.Write([]byte("-----> Downloading buildpacks\r\n"))
+
+	// Assert
+	assert.Equal(logMessage{"log-guid", "Creating container", events.LogMessage_OUT}, nextMessage(t, emitter))
+	assert.Equal(logMessage{"log-guid", "Successfully created container", events.LogMessage_OUT}, nextMessage(t, emitter))
+	assert.Equal(logMessage{"log-guid", "-----> Downloading buildpacks", events.LogMessage_OUT}, nextMessage(t, emitter))
+	if assert.NotNil(options) {
+		assert.True(options.Follow)
+		assert.Nil(options.TailLines)
+	}
+}
+
+func TestStagingLogForwarderReportsStagingLifecycle(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	source := newFakePodSource()
+	source.pods = []*api.Pod{newStagingPod("staging-pod", api.PodRunning)}
+	emitter := &fakeEmitter{messages: make(chan logMessage, 10)}
+	stop := runForwarder(t, source, emitter)
+	defer stop()
+	jobHandlers, podHandlers := source.handlers()
+	stream, _ := source.stream("staging-pod")
+	stream.Write([]byte("compiling\n"))
+	nextMessage(t, emitter)
+
+	// Act
+	podHandlers[0].OnUpdate(newStagingPod("staging-pod", api.PodRunning), newStagingPod("staging-pod", api.PodFailed))
+	failed := nextMessage(t, emitter)
+	podHandlers[0].OnDelete(newStagingPod("staging-pod", api.PodFailed))
+	_, writeErr := stream.Write([]byte("after deletion\n"))
+	jobHandlers[0].OnDelete(newStagingJob("staging"))
+
+	// Assert
+	assert.Equal(logMessage{"log-guid", "Staging failed", events.LogMessage_ERR}, failed)
+	assert.Equal(io.ErrClosedPipe, writeErr)
+	assert.Equal(logMessage{"log-guid", "Successfully destroyed container", events.LogMessage_OUT}, nextMessage(t, emitter))
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/stager/cc_client"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+type instrumentedK8SClient struct {
+	client k8s.K8SStagingClient
+}
+
+// InstrumentK8SClient counts the failed calls of a staging client. Errors
+// that describe the state of a staging rather than a failed call aren't
+// counted.
+func InstrumentK8SClient(client k8s.K8SStagingClient) k8s.K8SStagingClient {
+	return &instrumentedK8SClient{client: client}
+}
+
+func (c *instrumentedK8SClient) CreateStagingNamespace(organization, space string) error {
+	return countK8SError("create_staging_namespace", c.client.CreateStagingNamespace(organization, space))
+}
+
+func (c *instrumentedK8SClient) GetStagingNamespace(space string) (*api.Namespace, bool, error) {
+	namespace, exists, err := c.client.GetStagingNamespace(space)
+	return namespace, exists, countK8SError("get_staging_namespace", err)
+}
+
+func (c *instrumentedK8SClient) RemoveStagingNamespace(space string) error {
+	return countK8SError("remove_staging_namespace", c.client.RemoveStagingNamespace(space))
+}
+
+func (c *instrumentedK8SClient) StartStaging(stagingData *k8s.StagingInfo, space string) error {
+	return countK8SError("start_staging", c.client.StartStaging(stagingData, space))
+}
+
+func (c *instrumentedK8SClient) GetStagingTask(id, space string) (*batch.Job, bool, error) {
+	job, exists, err := c.client.GetStagingTask(id, space)
+	return job, exists, countK8SError("get_staging_task", err)
+}
+
+func (c *instrumentedK8SClient) ListStagingTasks(space string) ([]*batch.Job, error) {
+	jobs, err := c.client.ListStagingTasks(space)
+	return jobs, countK8SError("list_staging_tasks", err)
+}
+
+func (c *instrumentedK8SClient) StopStaging(id, space string, gracePeriod int64) error {
+	return countK8SError("stop_staging", c.client.StopStaging(id, space, gracePeriod))
+}
+
+func (c *instrumentedK8SClient) StreamStagingLogs(id, space string, options *k8s.LogOptions) (io.ReadCloser, error) {
+	logs, err := c.client.StreamStagingLogs(id, space, options)
+	return logs, countK8SError("stream_staging_logs", err)
+}
+
+func (c *instrumentedK8SClient) RecordStagingEvent(id, space, eventType, reason, message string) error {
+	return countK8SError("record_staging_event", c.client.RecordStagingEvent(id, space, eventType, reason, message))
+}
+
+func countK8SError(operation string, err error) error {
+	switch err {
+	case nil, k8s.ErrStagingTaskExists, k8s.ErrStagingTaskNotFound, k8s.ErrStagingPodPending, k8s.ErrStagingPodGone:
+	default:
+		K8SClientErrors.WithLabelValues(operation).Inc()
+	}
+
+	return err
+}
+
+// maxFailedDeliveries bounds the staging guids remembered to count retried
+// deliveries, as some stagings never get their result delivered.
+const maxFailedDeliveries = 10000
+
+// failedDeliveries holds the staging guids whose last delivery failed. The
+// staging reports its result again until it is delivered, so a delivery for
+// one of them is a retry.
+var failedDeliveries = struct {
+	sync.Mutex
+	guids map[string]struct{}
+}{guids: map[string]struct{}{}}
+
+// recordDelivery remembers the outcome of a delivery and returns whether an
+// earlier delivery for the staging failed.
+func recordDelivery(stagingGuid string, failed bool) bool {
+	failedDeliveries.Lock()
+	defer failedDeliveries.Unlock()
+
+	_, retried := failedDeliveries.guids[stagingGuid]
+	if !failed {
+		delete(failedDeliveries.guids, stagingGuid)
+	} else if len(failedDeliveries.guids) < maxFailedDeliveries {
+		failedDeliveries.guids[stagingGuid] = struct{}{}
+	}
+
+	return retried
+}
+
+type instrumentedCcClient struct {
+	client    cc_client.CcClient
+	lifecycle string
+}
+
+// InstrumentCcClient records the latency, failures and retries of delivering
+// staging results for a lifecycle to CC.
+func InstrumentCcClient(client cc_client.CcClient, lifecycle string) cc_client.CcClient {
+	return &instrumentedCcClient{client: client, lifecycle: lifecycle}
+}
+
+func (c *instrumentedCcClient) StagingComplete(stagingGuid string, completionCallback string, payload []byte, logger lager.Logger) error {
+	start := time.Now()
+	err := c.client.StagingComplete(stagingGuid, completionCallback, payload, logger)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+
+		reason := "transport"
+		if badResponse, ok := err.(*cc_client.BadResponseError); ok {
+			reason = strconv.Itoa(badResponse.StatusCode)
+		}
+		CCClientErrors.WithLabelValues(reason).Inc()
+	}
+
+	CompletionDeliveryLatency.WithLabelValues(c.lifecycle, outcome).Observe(time.Since(start).Seconds())
+	if recordDelivery(stagingGuid, err != nil) {
+		CompletionDeliveryRetries.WithLabelValues(c.lifecycle).Inc()
+	}
+
+	return err
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/stager/cc_client"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+type failingK8SClient struct {
+	err error
+}
+
+func (c *failingK8SClient) CreateStagingNamespace(organization, space string) error {
+	return c.err
+}
+
+func (c *failingK8SClient) GetStagingNamespace(space string) (*api.Namespace, bool, error) {
+	return nil, false, c.err
+}
+
+func (c *failingK8SClient) RemoveStagingNamespace(space string) error {
+	return c.err
+}
+
+func (c *failingK8SClient) StartStaging(stagingData *k8s.StagingInfo, space string) error {
+	return c.err
+}
+
+func (c *failingK8SClient) GetStagingTask(id, space string) (*batch.Job, bool, error) {
+	return nil, false, c.err
+}
+
+func (c *failingK8SClient) ListStagingTasks(space string) ([]*batch.Job, error) {
+	return nil, c.err
+}
+
+func (c *failingK8SClient) StopStaging(id, space string, gracePeriod int64) error {
+	return c.err
+}
+
+func (c *failingK8SClient) StreamStagingLogs(id, space string, options *k8s.LogOptions) (io.ReadCloser, error) {
+	return nil, c.err
+}
+
+func (c *failingK8SClient) RecordStagingEvent(id, space, eventType, reason, message string) error {
+	return c.err
+}
+
+type failingCcClient struct {
+	err error
+}
+
+func (c *failingCcClient) StagingComplete(stagingGuid string, completionCallback string, payload []byte, logger lager.Logger) error {
+	return c.err
+}
+
+func counterValue(counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	counter.Write(metric)
+	return metric.GetCounter().GetValue()
+}
+
+func TestInstrumentedK8SClientCountsFailedCalls(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	before := counterValue(K8SClientErrors.WithLabelValues("start_staging"))
+	client := InstrumentK8SClient(&failingK8SClient{err: errors.New("connection refused")})
+
+	// Act
+	err := client.StartStaging(&k8s.StagingInfo{}, "space")
+
+	// Assert
+	assert.EqualError(err, "connection refused")
+	assert.Equal(before+1, counterValue(K8SClientErrors.WithLabelValues("start_staging")))
+}
+
+func TestInstrumentedK8SClientIgnoresStagingStateErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	before := counterValue(K8SClientErrors.WithLabelValues("start_staging"))
+	client := InstrumentK8SClient(&failingK8SClient{err: k8s.ErrStagingTaskExists})
+
+	// Act
+	err := client.StartStaging(&k8s.StagingInfo{}, "space")
+
+	// Assert
+	assert.Equal(k8s.ErrStagingTaskExists, err)
+	assert.Equal(before, counterValue(K8SClientErrors.WithLabelValues("start_staging")))
+}
+
+func TestInstrumentedCcClientCountsErrorsByStatusCode(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	before := counterValue(CCClientErrors.WithLabelValues("503"))
+	client := InstrumentCcClient(&failingCcClient{err: &cc_client.BadResponseError{StatusCode: 503}}, "buildpack")
+
+	// Act
+	err := client.StagingComplete("staging-guid", "", []byte("{}"), lager.NewLogger("test"))
+
+	// Assert
+	assert.Error(err)
+	assert.Equal(before+1, counterValue(CCClientErrors.WithLabelValues("503")))
+}
+
+func TestInstrumentedCcClientCountsRetriedDeliveries(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	before := counterValue(CompletionDeliveryRetries.WithLabelValues("docker"))
+	ccClient := &failingCcClient{err: errors.New("connection refused")}
+	client := InstrumentCcClient(ccClient, "docker")
+	logger := lager.NewLogger("test")
+
+	// Act
+	client.StagingComplete("retried-staging-guid", "", []byte("{}"), logger)
+	client.StagingComplete("retried-staging-guid", "", []byte("{}"), logger)
+	ccClient.err = nil
+	client.StagingComplete("retried-staging-guid", "", []byte("{}"), logger)
+	client.StagingComplete("retried-staging-guid", "", []byte("{}"), logger)
+	client.StagingComplete("other-staging-guid", "", []byte("{}"), logger)
+
+	// Assert
+	assert.Equal(before+2, counterValue(CompletionDeliveryRetries.WithLabelValues("docker")))
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "k8s_stager"
+
+var (
+	StagingRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "staging_requests_total",
+			Help:      "Staging requests received from CC, by outcome.",
+		},
+		[]string{"lifecycle", "stack", "outcome"},
+	)
+
+	StagingAdmissions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "staging_admissions_total",
+			Help:      "Stagings that were actually started.",
+		},
+		[]string{"lifecycle", "stack"},
+	)
+
+	StagingStartLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "staging_start_latency_seconds",
+			Help:      "Time from accepting a staging request until its staging pod started.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		},
+		[]string{"lifecycle", "stack"},
+	)
+
+	StagingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "staging_duration_seconds",
+			Help:      "Time from accepting a staging request until its completion was reported.",
+			Buckets:   prometheus.ExponentialBuckets(5, 2, 10),
+		},
+		[]string{"lifecycle", "stack", "outcome"},
+	)
+
+	CompletionDeliveryLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "completion_delivery_latency_seconds",
+			Help:      "Time taken to deliver a staging result to CC.",
+		},
+		[]string{"lifecycle", "outcome"},
+	)
+
+	CompletionDeliveryRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "completion_delivery_retries_total",
+			Help:      "Staging results delivered to CC again after an earlier delivery for the same staging failed.",
+		},
+		[]string{"lifecycle"},
+	)
+
+	K8SClientErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "k8s_client_errors_total",
+			Help:      "Failed calls to the Kubernetes API, by operation.",
+		},
+		[]string{"operation"},
+	)
+
+	CCClientErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cc_client_errors_total",
+			Help:      "Failed calls to the Cloud Controller, by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+// Register adds the stager metrics and the given collectors to the default
+// prometheus registry.
+func Register(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(
+		StagingRequests,
+		StagingAdmissions,
+		StagingStartLatency,
+		StagingDuration,
+		CompletionDeliveryLatency,
+		CompletionDeliveryRetries,
+		K8SClientErrors,
+		CCClientErrors,
+	)
+
+	prometheus.MustRegister(collectors...)
+}
+
+// Handler serves the registered metrics in the prometheus exposition format.
+func Handler() http.Handler {
+	return prometheus.Handler()
+}
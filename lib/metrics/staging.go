@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"os"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// Only buildpack stagings run as jobs
+const jobLifecycle = "buildpack"
+
+var (
+	inFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "stagings_in_flight"),
+		"Stagings that were accepted and haven't finished yet.",
+		[]string{"lifecycle"},
+		nil,
+	)
+
+	queuedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "stagings_queued"),
+		"Stagings whose pod hasn't started yet.",
+		[]string{"lifecycle"},
+		nil,
+	)
+)
+
+type stagingCollector struct {
+	cache          *k8s.StagingCache
+	dockerInFlight func() int
+}
+
+// NewStagingCollector reports the in-flight and queued stagings of the jobs
+// and pods in the staging cache. Docker stagings don't have a job, so their
+// count is taken from dockerInFlight.
+func NewStagingCollector(cache *k8s.StagingCache, dockerInFlight func() int) prometheus.Collector {
+	return &stagingCollector{cache: cache, dockerInFlight: dockerInFlight}
+}
+
+func (c *stagingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- inFlightDesc
+	ch <- queuedDesc
+}
+
+func (c *stagingCollector) Collect(ch chan<- prometheus.Metric) {
+	inFlight := 0
+	for _, job := range c.cache.ListJobs() {
+		if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+			inFlight++
+		}
+	}
+
+	queued := 0
+	for _, pod := range c.cache.ListPods(api.NamespaceAll, labels.Everything()) {
+		if pod.Status.Phase == api.PodPending {
+			queued++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(inFlight), jobLifecycle)
+	ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(c.dockerInFlight()), "docker")
+	ch <- prometheus.MustNewConstMetric(queuedDesc, prometheus.GaugeValue, float64(queued), jobLifecycle)
+}
+
+// StagingStartObserver records how long staging pods took to start after
+// their job was created. It watches the staging cache, so only one stager of
+// a deployment should run it.
+type StagingStartObserver struct {
+	cache *k8s.StagingCache
+}
+
+func NewStagingStartObserver(cache *k8s.StagingCache) *StagingStartObserver {
+	return &StagingStartObserver{cache: cache}
+}
+
+func (o *StagingStartObserver) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	o.cache.AddPodEventHandler(k8s.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj runtime.Object) {
+			o.podUpdated(oldObj.(*api.Pod), newObj.(*api.Pod))
+		},
+	})
+
+	close(ready)
+
+	<-signals
+	return nil
+}
+
+func (o *StagingStartObserver) podUpdated(oldPod, pod *api.Pod) {
+	if oldPod.Status.Phase != api.PodPending || pod.Status.Phase == api.PodPending || pod.Status.StartTime == nil {
+		return
+	}
+
+	created := pod.CreationTimestamp
+	stack := pod.Annotations[k8s.StackAnnotation]
+
+	var job *batch.Job
+	if jobName := pod.Labels["job-name"]; jobName != "" {
+		job, _ = o.cache.GetJob(pod.Namespace, jobName)
+	}
+	if job != nil {
+		created = job.CreationTimestamp
+	}
+
+	StagingStartLatency.WithLabelValues(jobLifecycle, stack).Observe(pod.Status.StartTime.Sub(created.Time).Seconds())
+}
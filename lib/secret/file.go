@@ -0,0 +1,73 @@
+package secret
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File is a secret kept in a file, such as a key of a mounted Kubernetes
+// secret. The file is read again when it changes, so rotated secrets are
+// used without restarting the stager.
+type File struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	value   string
+}
+
+// NewFile reads a secret from a file. Leading and trailing whitespace,
+// usually the newline at the end of the file, isn't part of the secret, and
+// a file without a secret is an error.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+
+	if _, err := f.Value(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Path returns the file the secret is read from.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Value returns the current secret. If the changed file can't be read or is
+// empty, for instance while it is being rewritten, the last secret read is
+// returned along with the error, and the file is read again next time.
+func (f *File) Value() (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Kubernetes replaces the files of mounted secrets through a symlink,
+	// Stat follows it
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.value, err
+	}
+
+	if !f.modTime.IsZero() && info.ModTime().Equal(f.modTime) {
+		return f.value, nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return f.value, err
+	}
+
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return f.value, fmt.Errorf("secret file %s is empty", f.path)
+	}
+
+	f.value = value
+	f.modTime = info.ModTime()
+
+	return f.value, nil
+}
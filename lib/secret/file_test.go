@@ -0,0 +1,94 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileReadsRotatedSecret(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "secret")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	assert.NoError(ioutil.WriteFile(path, []byte("first\n"), 0600))
+	secret, err := NewFile(path)
+	assert.NoError(err)
+
+	// Act
+	first, firstErr := secret.Value()
+	assert.NoError(ioutil.WriteFile(path, []byte("second\n"), 0600))
+	assert.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	second, secondErr := secret.Value()
+
+	// Assert
+	assert.NoError(firstErr)
+	assert.NoError(secondErr)
+	assert.Equal("first", first)
+	assert.Equal("second", second)
+}
+
+func TestFileKeepsLastSecretWhenFileIsGone(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "secret")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	assert.NoError(ioutil.WriteFile(path, []byte("first"), 0600))
+	secret, err := NewFile(path)
+	assert.NoError(err)
+
+	// Act
+	assert.NoError(os.Remove(path))
+	value, err := secret.Value()
+
+	// Assert
+	assert.Error(err)
+	assert.Equal("first", value)
+}
+
+func TestNewFileFailsForMissingFile(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, err := NewFile("/does/not/exist")
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestFileKeepsLastSecretWhenFileIsEmptied(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "secret")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	assert.NoError(ioutil.WriteFile(path, []byte("first\n"), 0600))
+	secret, err := NewFile(path)
+	assert.NoError(err)
+
+	// Act
+	assert.NoError(ioutil.WriteFile(path, []byte("\n"), 0600))
+	assert.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	emptied, emptiedErr := secret.Value()
+	assert.NoError(ioutil.WriteFile(path, []byte("second\n"), 0600))
+	rewritten, rewrittenErr := secret.Value()
+
+	// Assert
+	assert.Error(emptiedErr)
+	assert.Equal("first", emptied)
+	assert.NoError(rewrittenErr)
+	assert.Equal("second", rewritten)
+}
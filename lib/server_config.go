@@ -1,8 +1,23 @@
 package lib
 
 import (
+	"sync/atomic"
+	"time"
+
 	"code.cloudfoundry.org/lager"
+	"github.com/cf-furnace/k8s-stager/lib/audit"
+	"github.com/cf-furnace/k8s-stager/lib/auth"
 	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/secret"
+)
+
+// Backends stagings can run on
+const (
+	// StagingBackendKubernetes runs stagings as Kubernetes jobs.
+	StagingBackendKubernetes = "kubernetes"
+	// StagingBackendLocal runs stagings as child processes of the stager,
+	// for development and tests.
+	StagingBackendLocal = "local"
 )
 
 type ServerConfig struct {
@@ -16,15 +31,130 @@ type ServerConfig struct {
 	StagingStopGracePeriodSeconds int64
 	K8SNamespace                  string
 	Logger                        lager.Logger
-	K8SClient                     k8s.K8SStagingClient
 	SkipCertVerification          bool
 	AppLifecycleURL               string
 	CustomImageCommand            string
 	KBSClientCertFile             string
 	K8SClientKeyFile              string
 	K8SCACertFile                 string
+	K8SConnection                 string
+	Kubeconfig                    string
+	KubeconfigContext             string
+	K8SQPS                        float32
+	K8SBurst                      int
+	K8SRequestTimeout             time.Duration
+	StagingBackend                string
+	LocalStagingDir               string
+	LocalStagingCommand           string
 	CCBaseURL                     string
 	CCUsername                    string
 	CCPassword                    string
+	CCUsernameFile                *secret.File
+	CCPasswordFile                *secret.File
 	ConsulCluster                 string
+	Discovery                     string
+	DiscoveryServiceName          string
+	DiscoveryServiceTags          []string
+	DiscoveryNamespace            string
+	LeaderElection                bool
+	LeaderLockTTL                 time.Duration
+	LeaderLockRetryInterval       time.Duration
+	MetronAddress                 string
+	ReadinessCheckCC              bool
+	TLSCertFile                   string
+	TLSKeyFile                    string
+	TLSClientCAFile               string
+	StagingClientCACertFile       string
+	StagingClientCAKeyFile        string
+	StagingServerCAFile           string
+	LogSinks                      []string
+	LogFile                       string
+	LogFileMaxSizeMB              int
+	LogFileMaxBackups             int
+	SyslogAddress                 string
+	SyslogTag                     string
+	LogRedactEnvPatterns          []string
+	DebugAddress                  string
+	AuditLogFile                  string
+	AuditLogHashChain             bool
+	AuthBasic                     bool
+	AuthJWKSURL                   string
+	AuthJWKSCAFile                string
+	AuthJWTIssuer                 string
+	AuthJWTAudience               string
+	AuthJWTCCScope                string
+	AuthCCCertSubject             string
+	ConfigReloadInterval          time.Duration
+	Authenticators                []auth.Authenticator
+	AuditLog                      audit.Logger
+
+	staging atomic.Value
+}
+
+// K8SConnectionConfig describes the connection to Kubernetes.
+func (c *ServerConfig) K8SConnectionConfig() k8s.ConnectionConfig {
+	return k8s.ConnectionConfig{
+		Mode:              c.K8SConnection,
+		Endpoint:          c.K8SAPIEndpoint,
+		ClientCertFile:    c.KBSClientCertFile,
+		ClientKeyFile:     c.K8SClientKeyFile,
+		CACertFile:        c.K8SCACertFile,
+		Kubeconfig:        c.Kubeconfig,
+		KubeconfigContext: c.KubeconfigContext,
+		QPS:               c.K8SQPS,
+		Burst:             c.K8SBurst,
+		RequestTimeout:    c.K8SRequestTimeout,
+	}
+}
+
+// StagingSettings are the settings new stagings are started with. Unlike the
+// rest of the configuration they can change while the stager runs.
+type StagingSettings struct {
+	StagingImage                  string
+	AppLifecycleURL               string
+	CustomImageCommand            string
+	StagingStopGracePeriodSeconds int64
+}
+
+// Staging returns the staging settings in effect. Until they are changed
+// with SetStaging they are the ones the configuration was loaded with.
+func (c *ServerConfig) Staging() StagingSettings {
+	if settings, ok := c.staging.Load().(StagingSettings); ok {
+		return settings
+	}
+
+	return StagingSettings{
+		StagingImage:                  c.StagingImage,
+		AppLifecycleURL:               c.AppLifecycleURL,
+		CustomImageCommand:            c.CustomImageCommand,
+		StagingStopGracePeriodSeconds: c.StagingStopGracePeriodSeconds,
+	}
+}
+
+// SetStaging replaces the staging settings for stagings started from now on.
+func (c *ServerConfig) SetStaging(settings StagingSettings) {
+	c.staging.Store(settings)
+}
+
+// CCCredentials returns the CC internal API credentials. Credentials kept in
+// files are read again after they are rotated. If a rotated file can't be
+// read the last credentials are returned along with the error.
+func (c *ServerConfig) CCCredentials() (username, password string, err error) {
+	username, password = c.CCUsername, c.CCPassword
+
+	if c.CCUsernameFile != nil {
+		var fileErr error
+		if username, fileErr = c.CCUsernameFile.Value(); fileErr != nil {
+			err = fileErr
+		}
+	}
+
+	if c.CCPasswordFile != nil {
+		var fileErr error
+		if password, fileErr = c.CCPasswordFile.Value(); fileErr != nil {
+			err = fileErr
+		}
+	}
+
+	return username, password, err
 }
@@ -0,0 +1,242 @@
+package swagger
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/audit"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+
+	"code.cloudfoundry.org/lager"
+	middleware "github.com/go-openapi/runtime/middleware"
+)
+
+// auditStage records every stage request in the audit log.
+func (s *stagerAPI) auditStage(handler operations.StageHandler) operations.StageHandler {
+	return operations.StageHandlerFunc(func(params operations.StageParams) middleware.Responder {
+		start := time.Now()
+		responder := handler.Handle(params)
+
+		record := newAuditRecord(audit.OperationStage, params.HTTPRequest, params.StagingGUID, "Stage", responder, start)
+		if _, ok := responder.(*operations.StageOK); ok {
+			record.Outcome = "dry_run"
+		}
+		record.AppGuid = params.StagingRequest.AppID
+		record.Lifecycle = params.StagingRequest.Lifecycle
+		record.Image, record.Buildpacks = s.lifecycleSources(params.StagingRequest)
+
+		s.writeAuditRecord(s.requestLogger(params.HTTPRequest), record)
+
+		return responder
+	})
+}
+
+// auditStopStaging records every stop request in the audit log.
+func (s *stagerAPI) auditStopStaging(handler operations.StopStagingHandler) operations.StopStagingHandler {
+	return operations.StopStagingHandlerFunc(func(params operations.StopStagingParams) middleware.Responder {
+		start := time.Now()
+		// Looked up first, stopping deletes the job
+		appGuid := s.stagingAppGuid(params.StagingGUID, s.config.K8SNamespace)
+
+		responder := handler.Handle(params)
+
+		record := newAuditRecord(audit.OperationStop, params.HTTPRequest, params.StagingGUID, "StopStaging", responder, start)
+		record.AppGuid = appGuid
+
+		s.writeAuditRecord(s.requestLogger(params.HTTPRequest), record)
+
+		return responder
+	})
+}
+
+// auditStagingComplete records every staging result reported by a staging
+// pod in the audit log.
+func (s *stagerAPI) auditStagingComplete(handler operations.StagingCompleteHandler) operations.StagingCompleteHandler {
+	return operations.StagingCompleteHandlerFunc(func(params operations.StagingCompleteParams) middleware.Responder {
+		start := time.Now()
+		appGuid := s.stagingAppGuid(params.StagingGUID, params.StagingCompleteRequest.Space)
+
+		responder := handler.Handle(params)
+
+		record := newAuditRecord(audit.OperationComplete, params.HTTPRequest, params.StagingGUID, "StagingComplete", responder, start)
+		record.AppGuid = appGuid
+		record.Lifecycle = BuildpackLifecycleName
+		if params.StagingCompleteRequest.Failed {
+			record.Error = params.StagingCompleteRequest.FailureReason
+		}
+
+		s.writeAuditRecord(s.requestLogger(params.HTTPRequest), record)
+
+		return responder
+	})
+}
+
+// auditedOperations maps the ids of the audited API operations to the
+// operations they are recorded as.
+var auditedOperations = map[string]string{
+	"stage":           audit.OperationStage,
+	"stopStaging":     audit.OperationStop,
+	"stagingComplete": audit.OperationComplete,
+}
+
+// auditRejectedRequest records a call to an audited operation that was
+// turned away before reaching its handler.
+func (s *stagerAPI) auditRejectedRequest(logger lager.Logger, request *http.Request, operationId, stagingGuid, outcome string, start time.Time) {
+	operation, ok := auditedOperations[operationId]
+	if !ok {
+		return
+	}
+
+	record := newAuditRecord(operation, request, stagingGuid, "", nil, start)
+	record.Outcome = outcome
+
+	s.writeAuditRecord(logger, record)
+}
+
+// auditCCCallback records the delivery of a staging result to CC.
+func (s *stagerAPI) auditCCCallback(logger lager.Logger, request *http.Request, lifecycle, appGuid, stagingGuid, target string, start time.Time, err error) {
+	record := audit.Record{
+		Time:        start,
+		Operation:   audit.OperationCCCallback,
+		RequestId:   requestId(request),
+		AppGuid:     appGuid,
+		StagingGuid: stagingGuid,
+		Lifecycle:   lifecycle,
+		Target:      audit.StripCredentials(target),
+		Outcome:     audit.OutcomeSucceeded,
+		DurationMs:  durationMs(start),
+	}
+
+	if err != nil {
+		record.Outcome = audit.OutcomeFailed
+		record.Error = err.Error()
+	}
+
+	s.writeAuditRecord(logger, record)
+}
+
+func newAuditRecord(operation string, request *http.Request, stagingGuid, responderPrefix string, responder middleware.Responder, start time.Time) audit.Record {
+	record := audit.Record{
+		Time:        start,
+		Operation:   operation,
+		RequestId:   requestId(request),
+		Caller:      requestCaller(request),
+		StagingGuid: stagingGuid,
+		Outcome:     responderOutcome(responderPrefix, responder),
+		DurationMs:  durationMs(start),
+	}
+
+	if request != nil {
+		record.RemoteAddr = request.RemoteAddr
+	}
+
+	return record
+}
+
+// writeAuditRecord writes a record to the audit log. Failing to do so is
+// logged but doesn't fail the operation.
+func (s *stagerAPI) writeAuditRecord(logger lager.Logger, record audit.Record) {
+	if s.config.AuditLog == nil {
+		return
+	}
+
+	if err := s.config.AuditLog.Record(record); err != nil {
+		logger.Error(
+			"Error writing audit record.",
+			err,
+			lager.Data{
+				"StagingId": record.StagingGuid,
+				"Operation": record.Operation,
+			},
+		)
+	}
+}
+
+// responderOutcome names the response an operation got after its responder
+// type, e.g. "internal_server_error" for *operations.StageInternalServerError.
+func responderOutcome(prefix string, responder middleware.Responder) string {
+	responderType := reflect.TypeOf(responder)
+	if responderType == nil {
+		return ""
+	}
+
+	if responderType.Kind() == reflect.Ptr {
+		responderType = responderType.Elem()
+	}
+
+	name := strings.TrimPrefix(responderType.Name(), prefix)
+
+	var outcome []rune
+	for idx, r := range name {
+		if unicode.IsUpper(r) {
+			if idx > 0 {
+				outcome = append(outcome, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		outcome = append(outcome, r)
+	}
+
+	return string(outcome)
+}
+
+// lifecycleSources returns the image and buildpack URLs a staging request
+// stages with, without credentials.
+func (s *stagerAPI) lifecycleSources(request *model.StagingRequestFromCC) (string, []string) {
+	lifecycleDataJson, err := json.Marshal(request.LifecycleData)
+	if err != nil {
+		return "", nil
+	}
+
+	switch request.Lifecycle {
+	case DockerLifecycleName:
+		dockerLifecycleData := &lib.DockerLifecycle{}
+		if err := json.Unmarshal(lifecycleDataJson, dockerLifecycleData); err != nil {
+			return "", nil
+		}
+
+		return audit.StripCredentials(dockerLifecycleData.DockerImageUrl), nil
+	case BuildpackLifecycleName:
+		buildpackLifecycleData := &lib.BuildpackLifecycle{}
+		if err := json.Unmarshal(lifecycleDataJson, buildpackLifecycleData); err != nil {
+			return "", nil
+		}
+
+		var buildpacks []string
+		for _, buildpack := range buildpackLifecycleData.Buildpacks {
+			buildpacks = append(buildpacks, audit.StripCredentials(buildpack.URL))
+		}
+
+		return s.config.Staging().StagingImage, buildpacks
+	}
+
+	return "", nil
+}
+
+// stagingAppGuid returns the app guid of a buildpack staging, if its job
+// still exists.
+func (s *stagerAPI) stagingAppGuid(stagingGuid, space string) string {
+	return s.stagingAnnotations(stagingGuid, space)[k8s.AppGuidAnnotation]
+}
+
+// stagingAnnotations returns the annotations of the job of a staging, none
+// if it can't be found.
+func (s *stagerAPI) stagingAnnotations(stagingGuid, space string) map[string]string {
+	job, exists, err := s.k8sClient.GetStagingTask(stagingGuid, space)
+	if err != nil || !exists {
+		return map[string]string{}
+	}
+
+	return job.Annotations
+}
+
+func durationMs(start time.Time) float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
@@ -0,0 +1,22 @@
+package swagger
+
+import (
+	"testing"
+
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponderOutcomeNamesTheResponse(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	accepted := responderOutcome("Stage", &operations.StageAccepted{})
+	serverError := responderOutcome("StopStaging", &operations.StopStagingInternalServerError{})
+
+	// Assert
+	assert.Equal("accepted", accepted)
+	assert.Equal("internal_server_error", serverError)
+}
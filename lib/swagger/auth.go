@@ -0,0 +1,98 @@
+package swagger
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/auth"
+
+	"code.cloudfoundry.org/lager"
+	errors "github.com/go-openapi/errors"
+	middleware "github.com/go-openapi/runtime/middleware"
+	gorillacontext "github.com/gorilla/context"
+)
+
+// operationRoles lists the roles that may call an operation. Operations that
+// aren't listed are only open to CC. Staging pods may only call an operation
+// on their own staging.
+var operationRoles = map[string][]auth.Role{
+	"stagingComplete": {auth.RoleCC, auth.RoleStaging},
+}
+
+// authorizeOperations authenticates callers of the API operations and checks
+// they have a role the operation allows. It is a no-op when no authenticators
+// are configured.
+func (s *stagerAPI) authorizeOperations(apiContext *middleware.Context, handler http.Handler) http.Handler {
+	if len(s.config.Authenticators) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := s.requestLogger(r)
+
+		operationId, stagingGuid := "", ""
+		if route, ok := apiContext.RouteInfo(r); ok {
+			operationId = route.Operation.ID
+			stagingGuid = route.Params.Get("staging_guid")
+		}
+
+		principal, err := auth.Authenticate(s.config.Authenticators, r)
+		if err != nil {
+			logger.Info("Rejected unauthenticated request.", lager.Data{"Reason": err.Error()})
+			s.auditRejectedRequest(logger, r, operationId, stagingGuid, "unauthenticated", start)
+
+			w.Header().Set("WWW-Authenticate", `Basic realm="k8s-stager"`)
+			errors.ServeError(w, r, errors.Unauthenticated("basic"))
+			return
+		}
+
+		allowedRoles, ok := operationRoles[operationId]
+		if !ok {
+			allowedRoles = []auth.Role{auth.RoleCC}
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), principalKey, principal))
+		// The router only clears the state go-openapi keeps for the request
+		// it was given, not for this copy
+		defer gorillacontext.Clear(r)
+
+		for _, role := range allowedRoles {
+			if role == auth.RoleStaging && principal.StagingGuid != stagingGuid {
+				continue
+			}
+
+			if principal.HasRole(role) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		logger.Info("Rejected unauthorized request.", lager.Data{
+			"Principal": principal.Name,
+			"Operation": operationId,
+		})
+		s.auditRejectedRequest(logger, r, operationId, stagingGuid, "forbidden", start)
+
+		errors.ServeError(w, r, errors.New(http.StatusForbidden, "%s may not call %s", principal.Name, operationId))
+	})
+}
+
+// requestCaller names who made a request: the authenticated principal, or
+// the subject of the client certificate when authentication is off.
+func requestCaller(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	if principal, ok := r.Context().Value(principalKey).(*auth.Principal); ok {
+		return principal.Name
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	return ""
+}
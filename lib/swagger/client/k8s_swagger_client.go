@@ -0,0 +1,62 @@
+package client
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+
+	"github.com/go-openapi/strfmt"
+
+	"github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
+)
+
+// Default k8s swagger HTTP client.
+var Default = NewHTTPClient(nil)
+
+const (
+	// DefaultHost is the default Host
+	// found in Meta (info) section of spec file
+	DefaultHost string = "localhost"
+	// DefaultBasePath is the default BasePath
+	// found in Meta (info) section of spec file
+	DefaultBasePath string = "/v1"
+)
+
+// DefaultSchemes are the default schemes found in Meta (info) section of spec file
+var DefaultSchemes = []string{"http"}
+
+// NewHTTPClient creates a new k8s swagger HTTP client.
+func NewHTTPClient(formats strfmt.Registry) *K8sSwagger {
+	if formats == nil {
+		formats = strfmt.Default
+	}
+	transport := httptransport.New(DefaultHost, DefaultBasePath, DefaultSchemes)
+	return New(transport, formats)
+}
+
+// New creates a new k8s swagger client
+func New(transport runtime.ClientTransport, formats strfmt.Registry) *K8sSwagger {
+	cli := new(K8sSwagger)
+	cli.Transport = transport
+
+	cli.Operations = operations.New(transport, formats)
+
+	return cli
+}
+
+// K8sSwagger is a client for k8s swagger
+type K8sSwagger struct {
+	Operations *operations.Client
+
+	Transport runtime.ClientTransport
+}
+
+// SetTransport changes the transport on the client and all its subresources
+func (c *K8sSwagger) SetTransport(transport runtime.ClientTransport) {
+	c.Transport = transport
+
+	c.Operations.SetTransport(transport)
+
+}
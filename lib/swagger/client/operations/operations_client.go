@@ -0,0 +1,143 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"github.com/go-openapi/runtime"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// New creates a new operations API client.
+func New(transport runtime.ClientTransport, formats strfmt.Registry) *Client {
+	return &Client{transport: transport, formats: formats}
+}
+
+/*
+Client for operations API
+*/
+type Client struct {
+	transport runtime.ClientTransport
+	formats   strfmt.Registry
+}
+
+/*
+Stage stage API
+*/
+func (a *Client) Stage(params *StageParams) (*StageOK, *StageAccepted, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewStageParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "stage",
+		Method:             "PUT",
+		PathPattern:        "/staging/{staging_guid}",
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &StageReader{formats: a.formats},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	switch value := result.(type) {
+	case *StageOK:
+		return value, nil, nil
+	case *StageAccepted:
+		return nil, value, nil
+	}
+	return nil, nil, nil
+
+}
+
+/*
+StagingComplete staging complete API
+*/
+func (a *Client) StagingComplete(params *StagingCompleteParams) (*StagingCompleteOK, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewStagingCompleteParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "stagingComplete",
+		Method:             "POST",
+		PathPattern:        "/staging/{staging_guid}/completed",
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &StagingCompleteReader{formats: a.formats},
+	})
+	if err != nil {
+		return nil, err
+	}
+	success, ok := result.(*StagingCompleteOK)
+	if ok {
+		return success, nil
+	}
+	// unexpected success response
+	unexpectedSuccess := result.(*StagingCompleteDefault)
+	return nil, runtime.NewAPIError("unexpected success response: content available as default response in error", unexpectedSuccess, unexpectedSuccess.Code())
+}
+
+/*
+StagingLogs staging logs API
+*/
+func (a *Client) StagingLogs(params *StagingLogsParams) (*StagingLogsOK, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewStagingLogsParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "stagingLogs",
+		Method:             "GET",
+		PathPattern:        "/staging/{staging_guid}/logs",
+		ProducesMediaTypes: []string{"text/plain", "text/event-stream"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &StagingLogsReader{formats: a.formats},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*StagingLogsOK), nil
+
+}
+
+/*
+StopStaging stop staging API
+*/
+func (a *Client) StopStaging(params *StopStagingParams) (*StopStagingAccepted, error) {
+	// TODO: Validate the params before sending
+	if params == nil {
+		params = NewStopStagingParams()
+	}
+
+	result, err := a.transport.Submit(&runtime.ClientOperation{
+		ID:                 "stopStaging",
+		Method:             "DELETE",
+		PathPattern:        "/staging/{staging_guid}",
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http"},
+		Params:             params,
+		Reader:             &StopStagingReader{formats: a.formats},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*StopStagingAccepted), nil
+
+}
+
+// SetTransport changes the transport on the client
+func (a *Client) SetTransport(transport runtime.ClientTransport) {
+	a.transport = transport
+}
@@ -0,0 +1,123 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"time"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/runtime"
+	cr "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/swag"
+
+	strfmt "github.com/go-openapi/strfmt"
+
+	"github.com/cf-furnace/k8s-stager/lib/model"
+)
+
+// NewStageParams creates a new StageParams object
+// with the default values initialized.
+func NewStageParams() *StageParams {
+	var ()
+	return &StageParams{
+
+		timeout: cr.DefaultTimeout,
+	}
+}
+
+// NewStageParamsWithTimeout creates a new StageParams object
+// with the default values initialized, and the ability to set a timeout on a request
+func NewStageParamsWithTimeout(timeout time.Duration) *StageParams {
+	var ()
+	return &StageParams{
+
+		timeout: timeout,
+	}
+}
+
+/*
+StageParams contains all the parameters to send to the API endpoint
+for the stage operation typically these are written to a http.Request
+*/
+type StageParams struct {
+
+	/*DryRun
+	  Render the objects the staging would create instead of creating them
+
+	*/
+	DryRun *bool
+	/*StagingGUID*/
+	StagingGUID string
+	/*StagingRequest*/
+	StagingRequest *model.StagingRequestFromCC
+
+	timeout time.Duration
+}
+
+// WithTimeout adds the timeout to the stage params
+func (o *StageParams) WithTimeout(timeout time.Duration) *StageParams {
+	o.timeout = timeout
+	return o
+}
+
+// WithDryRun adds the dryRun to the stage params
+func (o *StageParams) WithDryRun(dryRun *bool) *StageParams {
+	o.DryRun = dryRun
+	return o
+}
+
+// WithStagingGUID adds the stagingGUID to the stage params
+func (o *StageParams) WithStagingGUID(stagingGUID string) *StageParams {
+	o.StagingGUID = stagingGUID
+	return o
+}
+
+// WithStagingRequest adds the stagingRequest to the stage params
+func (o *StageParams) WithStagingRequest(stagingRequest *model.StagingRequestFromCC) *StageParams {
+	o.StagingRequest = stagingRequest
+	return o
+}
+
+// WriteToRequest writes these params to a swagger request
+func (o *StageParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Registry) error {
+
+	if err := r.SetTimeout(o.timeout); err != nil {
+		return err
+	}
+	var res []error
+
+	if o.DryRun != nil {
+
+		// query param dry_run
+		var qrDryRun bool
+		if o.DryRun != nil {
+			qrDryRun = *o.DryRun
+		}
+		qDryRun := swag.FormatBool(qrDryRun)
+		if qDryRun != "" {
+			if err := r.SetQueryParam("dry_run", qDryRun); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	// path param staging_guid
+	if err := r.SetPathParam("staging_guid", o.StagingGUID); err != nil {
+		return err
+	}
+
+	if o.StagingRequest == nil {
+		o.StagingRequest = new(model.StagingRequestFromCC)
+	}
+
+	if err := r.SetBodyParam(o.StagingRequest); err != nil {
+		return err
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-openapi/runtime"
+
+	strfmt "github.com/go-openapi/strfmt"
+
+	"github.com/cf-furnace/k8s-stager/lib/model"
+)
+
+// StageReader is a Reader for the Stage structure.
+type StageReader struct {
+	formats strfmt.Registry
+}
+
+// ReadResponse reads a server response into the received o.
+func (o *StageReader) ReadResponse(response runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+	switch response.Code() {
+
+	case 200:
+		result := NewStageOK()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case 202:
+		result := NewStageAccepted()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case 400:
+		result := NewStageBadRequest()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 404:
+		result := NewStageNotFound()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 409:
+		result := NewStageConflict()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 500:
+		result := NewStageInternalServerError()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	default:
+		return nil, runtime.NewAPIError("unknown error", response, response.Code())
+	}
+}
+
+// NewStageOK creates a StageOK with default headers values
+func NewStageOK() *StageOK {
+	return &StageOK{}
+}
+
+/*
+StageOK handles this case with default header values.
+
+Objects the staging would create, as a Kubernetes List
+*/
+type StageOK struct {
+	Payload interface{}
+}
+
+func (o *StageOK) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageOK  %+v", 200, o.Payload)
+}
+
+func (o *StageOK) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	// response payload
+	if err := consumer.Consume(response.Body(), &o.Payload); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// NewStageAccepted creates a StageAccepted with default headers values
+func NewStageAccepted() *StageAccepted {
+	return &StageAccepted{}
+}
+
+/*
+StageAccepted handles this case with default header values.
+
+Staging request accepted
+*/
+type StageAccepted struct {
+}
+
+func (o *StageAccepted) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageAccepted ", 202)
+}
+
+func (o *StageAccepted) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStageBadRequest creates a StageBadRequest with default headers values
+func NewStageBadRequest() *StageBadRequest {
+	return &StageBadRequest{}
+}
+
+/*
+StageBadRequest handles this case with default header values.
+
+JSON unmarshalling error
+*/
+type StageBadRequest struct {
+}
+
+func (o *StageBadRequest) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageBadRequest ", 400)
+}
+
+func (o *StageBadRequest) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStageNotFound creates a StageNotFound with default headers values
+func NewStageNotFound() *StageNotFound {
+	return &StageNotFound{}
+}
+
+/*
+StageNotFound handles this case with default header values.
+
+Couldn't find backend
+*/
+type StageNotFound struct {
+}
+
+func (o *StageNotFound) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageNotFound ", 404)
+}
+
+func (o *StageNotFound) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStageConflict creates a StageConflict with default headers values
+func NewStageConflict() *StageConflict {
+	return &StageConflict{}
+}
+
+/*
+StageConflict handles this case with default header values.
+
+Staging request conflicts with an existing staging
+*/
+type StageConflict struct {
+	Payload *model.StagingResponseFromCC
+}
+
+func (o *StageConflict) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageConflict  %+v", 409, o.Payload)
+}
+
+func (o *StageConflict) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	o.Payload = new(model.StagingResponseFromCC)
+
+	// response payload
+	if err := consumer.Consume(response.Body(), o.Payload); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// NewStageInternalServerError creates a StageInternalServerError with default headers values
+func NewStageInternalServerError() *StageInternalServerError {
+	return &StageInternalServerError{}
+}
+
+/*
+StageInternalServerError handles this case with default header values.
+
+Staging error
+*/
+type StageInternalServerError struct {
+	Payload *model.StagingResponseFromCC
+}
+
+func (o *StageInternalServerError) Error() string {
+	return fmt.Sprintf("[PUT /staging/{staging_guid}][%d] stageInternalServerError  %+v", 500, o.Payload)
+}
+
+func (o *StageInternalServerError) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	o.Payload = new(model.StagingResponseFromCC)
+
+	// response payload
+	if err := consumer.Consume(response.Body(), o.Payload); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"time"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/runtime"
+	cr "github.com/go-openapi/runtime/client"
+
+	strfmt "github.com/go-openapi/strfmt"
+
+	"github.com/cf-furnace/k8s-stager/lib/model"
+)
+
+// NewStagingCompleteParams creates a new StagingCompleteParams object
+// with the default values initialized.
+func NewStagingCompleteParams() *StagingCompleteParams {
+	var ()
+	return &StagingCompleteParams{
+
+		timeout: cr.DefaultTimeout,
+	}
+}
+
+// NewStagingCompleteParamsWithTimeout creates a new StagingCompleteParams object
+// with the default values initialized, and the ability to set a timeout on a request
+func NewStagingCompleteParamsWithTimeout(timeout time.Duration) *StagingCompleteParams {
+	var ()
+	return &StagingCompleteParams{
+
+		timeout: timeout,
+	}
+}
+
+/*
+StagingCompleteParams contains all the parameters to send to the API endpoint
+for the staging complete operation typically these are written to a http.Request
+*/
+type StagingCompleteParams struct {
+
+	/*StagingCompleteRequest*/
+	StagingCompleteRequest *model.TaskCallbackResponse
+	/*StagingGUID*/
+	StagingGUID string
+
+	timeout time.Duration
+}
+
+// WithTimeout adds the timeout to the staging complete params
+func (o *StagingCompleteParams) WithTimeout(timeout time.Duration) *StagingCompleteParams {
+	o.timeout = timeout
+	return o
+}
+
+// WithStagingCompleteRequest adds the stagingCompleteRequest to the staging complete params
+func (o *StagingCompleteParams) WithStagingCompleteRequest(stagingCompleteRequest *model.TaskCallbackResponse) *StagingCompleteParams {
+	o.StagingCompleteRequest = stagingCompleteRequest
+	return o
+}
+
+// WithStagingGUID adds the stagingGUID to the staging complete params
+func (o *StagingCompleteParams) WithStagingGUID(stagingGUID string) *StagingCompleteParams {
+	o.StagingGUID = stagingGUID
+	return o
+}
+
+// WriteToRequest writes these params to a swagger request
+func (o *StagingCompleteParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Registry) error {
+
+	if err := r.SetTimeout(o.timeout); err != nil {
+		return err
+	}
+	var res []error
+
+	if o.StagingCompleteRequest == nil {
+		o.StagingCompleteRequest = new(model.TaskCallbackResponse)
+	}
+
+	if err := r.SetBodyParam(o.StagingCompleteRequest); err != nil {
+		return err
+	}
+
+	// path param staging_guid
+	if err := r.SetPathParam("staging_guid", o.StagingGUID); err != nil {
+		return err
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
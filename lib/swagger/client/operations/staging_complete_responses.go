@@ -0,0 +1,190 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-openapi/runtime"
+
+	strfmt "github.com/go-openapi/strfmt"
+
+	"github.com/cf-furnace/k8s-stager/lib/model"
+)
+
+// StagingCompleteReader is a Reader for the StagingComplete structure.
+type StagingCompleteReader struct {
+	formats strfmt.Registry
+}
+
+// ReadResponse reads a server response into the received o.
+func (o *StagingCompleteReader) ReadResponse(response runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+	switch response.Code() {
+
+	case 200:
+		result := NewStagingCompleteOK()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case 400:
+		result := NewStagingCompleteBadRequest()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 404:
+		result := NewStagingCompleteNotFound()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 503:
+		result := NewStagingCompleteServiceUnavailable()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	default:
+		result := NewStagingCompleteDefault(response.Code())
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		if response.Code()/100 == 2 {
+			return result, nil
+		}
+		return nil, result
+	}
+}
+
+// NewStagingCompleteOK creates a StagingCompleteOK with default headers values
+func NewStagingCompleteOK() *StagingCompleteOK {
+	return &StagingCompleteOK{}
+}
+
+/*
+StagingCompleteOK handles this case with default header values.
+
+Status OK
+*/
+type StagingCompleteOK struct {
+	Payload *model.StagingResponseFromCC
+}
+
+func (o *StagingCompleteOK) Error() string {
+	return fmt.Sprintf("[POST /staging/{staging_guid}/completed][%d] stagingCompleteOK  %+v", 200, o.Payload)
+}
+
+func (o *StagingCompleteOK) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	o.Payload = new(model.StagingResponseFromCC)
+
+	// response payload
+	if err := consumer.Consume(response.Body(), o.Payload); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// NewStagingCompleteBadRequest creates a StagingCompleteBadRequest with default headers values
+func NewStagingCompleteBadRequest() *StagingCompleteBadRequest {
+	return &StagingCompleteBadRequest{}
+}
+
+/*
+StagingCompleteBadRequest handles this case with default header values.
+
+Bad request
+*/
+type StagingCompleteBadRequest struct {
+}
+
+func (o *StagingCompleteBadRequest) Error() string {
+	return fmt.Sprintf("[POST /staging/{staging_guid}/completed][%d] stagingCompleteBadRequest ", 400)
+}
+
+func (o *StagingCompleteBadRequest) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingCompleteNotFound creates a StagingCompleteNotFound with default headers values
+func NewStagingCompleteNotFound() *StagingCompleteNotFound {
+	return &StagingCompleteNotFound{}
+}
+
+/*
+StagingCompleteNotFound handles this case with default header values.
+
+Can't find staging task
+*/
+type StagingCompleteNotFound struct {
+}
+
+func (o *StagingCompleteNotFound) Error() string {
+	return fmt.Sprintf("[POST /staging/{staging_guid}/completed][%d] stagingCompleteNotFound ", 404)
+}
+
+func (o *StagingCompleteNotFound) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingCompleteServiceUnavailable creates a StagingCompleteServiceUnavailable with default headers values
+func NewStagingCompleteServiceUnavailable() *StagingCompleteServiceUnavailable {
+	return &StagingCompleteServiceUnavailable{}
+}
+
+/*
+StagingCompleteServiceUnavailable handles this case with default header values.
+
+Cloud Controller unavailable
+*/
+type StagingCompleteServiceUnavailable struct {
+}
+
+func (o *StagingCompleteServiceUnavailable) Error() string {
+	return fmt.Sprintf("[POST /staging/{staging_guid}/completed][%d] stagingCompleteServiceUnavailable ", 503)
+}
+
+func (o *StagingCompleteServiceUnavailable) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingCompleteDefault creates a StagingCompleteDefault with default headers values
+func NewStagingCompleteDefault(code int) *StagingCompleteDefault {
+	return &StagingCompleteDefault{
+		_statusCode: code,
+	}
+}
+
+/*
+StagingCompleteDefault handles this case with default header values.
+
+Response code from Cloud Controller
+*/
+type StagingCompleteDefault struct {
+	_statusCode int
+}
+
+// Code gets the status code for the staging complete default response
+func (o *StagingCompleteDefault) Code() int {
+	return o._statusCode
+}
+
+func (o *StagingCompleteDefault) Error() string {
+	return fmt.Sprintf("[POST /staging/{staging_guid}/completed][%d] stagingComplete default ", o._statusCode)
+}
+
+func (o *StagingCompleteDefault) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
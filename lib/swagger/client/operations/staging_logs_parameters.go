@@ -0,0 +1,156 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"time"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/runtime"
+	cr "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/swag"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// NewStagingLogsParams creates a new StagingLogsParams object
+// with the default values initialized.
+func NewStagingLogsParams() *StagingLogsParams {
+	var ()
+	return &StagingLogsParams{
+
+		timeout: cr.DefaultTimeout,
+	}
+}
+
+// NewStagingLogsParamsWithTimeout creates a new StagingLogsParams object
+// with the default values initialized, and the ability to set a timeout on a request
+func NewStagingLogsParamsWithTimeout(timeout time.Duration) *StagingLogsParams {
+	var ()
+	return &StagingLogsParams{
+
+		timeout: timeout,
+	}
+}
+
+/*
+StagingLogsParams contains all the parameters to send to the API endpoint
+for the staging logs operation typically these are written to a http.Request
+*/
+type StagingLogsParams struct {
+
+	/*Follow*/
+	Follow *bool
+	/*Since
+	  Only return logs newer than this many seconds
+
+	*/
+	Since *int64
+	/*StagingGUID*/
+	StagingGUID string
+	/*Tail
+	  Number of lines from the end of the logs to return
+
+	*/
+	Tail *int64
+
+	timeout time.Duration
+}
+
+// WithTimeout adds the timeout to the staging logs params
+func (o *StagingLogsParams) WithTimeout(timeout time.Duration) *StagingLogsParams {
+	o.timeout = timeout
+	return o
+}
+
+// WithFollow adds the follow to the staging logs params
+func (o *StagingLogsParams) WithFollow(follow *bool) *StagingLogsParams {
+	o.Follow = follow
+	return o
+}
+
+// WithSince adds the since to the staging logs params
+func (o *StagingLogsParams) WithSince(since *int64) *StagingLogsParams {
+	o.Since = since
+	return o
+}
+
+// WithStagingGUID adds the stagingGUID to the staging logs params
+func (o *StagingLogsParams) WithStagingGUID(stagingGUID string) *StagingLogsParams {
+	o.StagingGUID = stagingGUID
+	return o
+}
+
+// WithTail adds the tail to the staging logs params
+func (o *StagingLogsParams) WithTail(tail *int64) *StagingLogsParams {
+	o.Tail = tail
+	return o
+}
+
+// WriteToRequest writes these params to a swagger request
+func (o *StagingLogsParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Registry) error {
+
+	if err := r.SetTimeout(o.timeout); err != nil {
+		return err
+	}
+	var res []error
+
+	if o.Follow != nil {
+
+		// query param follow
+		var qrFollow bool
+		if o.Follow != nil {
+			qrFollow = *o.Follow
+		}
+		qFollow := swag.FormatBool(qrFollow)
+		if qFollow != "" {
+			if err := r.SetQueryParam("follow", qFollow); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	if o.Since != nil {
+
+		// query param since
+		var qrSince int64
+		if o.Since != nil {
+			qrSince = *o.Since
+		}
+		qSince := swag.FormatInt64(qrSince)
+		if qSince != "" {
+			if err := r.SetQueryParam("since", qSince); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	// path param staging_guid
+	if err := r.SetPathParam("staging_guid", o.StagingGUID); err != nil {
+		return err
+	}
+
+	if o.Tail != nil {
+
+		// query param tail
+		var qrTail int64
+		if o.Tail != nil {
+			qrTail = *o.Tail
+		}
+		qTail := swag.FormatInt64(qrTail)
+		if qTail != "" {
+			if err := r.SetQueryParam("tail", qTail); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-openapi/runtime"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// StagingLogsReader is a Reader for the StagingLogs structure.
+type StagingLogsReader struct {
+	formats strfmt.Registry
+}
+
+// ReadResponse reads a server response into the received o.
+func (o *StagingLogsReader) ReadResponse(response runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+	switch response.Code() {
+
+	case 200:
+		result := NewStagingLogsOK()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case 400:
+		result := NewStagingLogsBadRequest()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 404:
+		result := NewStagingLogsNotFound()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 410:
+		result := NewStagingLogsGone()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 500:
+		result := NewStagingLogsInternalServerError()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 503:
+		result := NewStagingLogsServiceUnavailable()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	default:
+		return nil, runtime.NewAPIError("unknown error", response, response.Code())
+	}
+}
+
+// NewStagingLogsOK creates a StagingLogsOK with default headers values
+func NewStagingLogsOK() *StagingLogsOK {
+	return &StagingLogsOK{}
+}
+
+/*
+StagingLogsOK handles this case with default header values.
+
+Staging logs
+*/
+type StagingLogsOK struct {
+	Payload string
+}
+
+func (o *StagingLogsOK) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsOK  %+v", 200, o.Payload)
+}
+
+func (o *StagingLogsOK) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	// response payload
+	if err := consumer.Consume(response.Body(), &o.Payload); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// NewStagingLogsBadRequest creates a StagingLogsBadRequest with default headers values
+func NewStagingLogsBadRequest() *StagingLogsBadRequest {
+	return &StagingLogsBadRequest{}
+}
+
+/*
+StagingLogsBadRequest handles this case with default header values.
+
+Invalid log options
+*/
+type StagingLogsBadRequest struct {
+}
+
+func (o *StagingLogsBadRequest) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsBadRequest ", 400)
+}
+
+func (o *StagingLogsBadRequest) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingLogsNotFound creates a StagingLogsNotFound with default headers values
+func NewStagingLogsNotFound() *StagingLogsNotFound {
+	return &StagingLogsNotFound{}
+}
+
+/*
+StagingLogsNotFound handles this case with default header values.
+
+Staging task not found
+*/
+type StagingLogsNotFound struct {
+}
+
+func (o *StagingLogsNotFound) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsNotFound ", 404)
+}
+
+func (o *StagingLogsNotFound) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingLogsGone creates a StagingLogsGone with default headers values
+func NewStagingLogsGone() *StagingLogsGone {
+	return &StagingLogsGone{}
+}
+
+/*
+StagingLogsGone handles this case with default header values.
+
+Staging pod is gone
+*/
+type StagingLogsGone struct {
+}
+
+func (o *StagingLogsGone) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsGone ", 410)
+}
+
+func (o *StagingLogsGone) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingLogsInternalServerError creates a StagingLogsInternalServerError with default headers values
+func NewStagingLogsInternalServerError() *StagingLogsInternalServerError {
+	return &StagingLogsInternalServerError{}
+}
+
+/*
+StagingLogsInternalServerError handles this case with default header values.
+
+Internal error
+*/
+type StagingLogsInternalServerError struct {
+}
+
+func (o *StagingLogsInternalServerError) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsInternalServerError ", 500)
+}
+
+func (o *StagingLogsInternalServerError) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStagingLogsServiceUnavailable creates a StagingLogsServiceUnavailable with default headers values
+func NewStagingLogsServiceUnavailable() *StagingLogsServiceUnavailable {
+	return &StagingLogsServiceUnavailable{}
+}
+
+/*
+StagingLogsServiceUnavailable handles this case with default header values.
+
+Staging pod not started yet
+*/
+type StagingLogsServiceUnavailable struct {
+}
+
+func (o *StagingLogsServiceUnavailable) Error() string {
+	return fmt.Sprintf("[GET /staging/{staging_guid}/logs][%d] stagingLogsServiceUnavailable ", 503)
+}
+
+func (o *StagingLogsServiceUnavailable) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"time"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/runtime"
+	cr "github.com/go-openapi/runtime/client"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// NewStopStagingParams creates a new StopStagingParams object
+// with the default values initialized.
+func NewStopStagingParams() *StopStagingParams {
+	var ()
+	return &StopStagingParams{
+
+		timeout: cr.DefaultTimeout,
+	}
+}
+
+// NewStopStagingParamsWithTimeout creates a new StopStagingParams object
+// with the default values initialized, and the ability to set a timeout on a request
+func NewStopStagingParamsWithTimeout(timeout time.Duration) *StopStagingParams {
+	var ()
+	return &StopStagingParams{
+
+		timeout: timeout,
+	}
+}
+
+/*
+StopStagingParams contains all the parameters to send to the API endpoint
+for the stop staging operation typically these are written to a http.Request
+*/
+type StopStagingParams struct {
+
+	/*StagingGUID*/
+	StagingGUID string
+
+	timeout time.Duration
+}
+
+// WithTimeout adds the timeout to the stop staging params
+func (o *StopStagingParams) WithTimeout(timeout time.Duration) *StopStagingParams {
+	o.timeout = timeout
+	return o
+}
+
+// WithStagingGUID adds the stagingGUID to the stop staging params
+func (o *StopStagingParams) WithStagingGUID(stagingGUID string) *StopStagingParams {
+	o.StagingGUID = stagingGUID
+	return o
+}
+
+// WriteToRequest writes these params to a swagger request
+func (o *StopStagingParams) WriteToRequest(r runtime.ClientRequest, reg strfmt.Registry) error {
+
+	if err := r.SetTimeout(o.timeout); err != nil {
+		return err
+	}
+	var res []error
+
+	// path param staging_guid
+	if err := r.SetPathParam("staging_guid", o.StagingGUID); err != nil {
+		return err
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
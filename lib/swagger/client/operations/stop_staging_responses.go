@@ -0,0 +1,113 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"fmt"
+
+	"github.com/go-openapi/runtime"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// StopStagingReader is a Reader for the StopStaging structure.
+type StopStagingReader struct {
+	formats strfmt.Registry
+}
+
+// ReadResponse reads a server response into the received o.
+func (o *StopStagingReader) ReadResponse(response runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+	switch response.Code() {
+
+	case 202:
+		result := NewStopStagingAccepted()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case 404:
+		result := NewStopStagingNotFound()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	case 500:
+		result := NewStopStagingInternalServerError()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
+
+	default:
+		return nil, runtime.NewAPIError("unknown error", response, response.Code())
+	}
+}
+
+// NewStopStagingAccepted creates a StopStagingAccepted with default headers values
+func NewStopStagingAccepted() *StopStagingAccepted {
+	return &StopStagingAccepted{}
+}
+
+/*
+StopStagingAccepted handles this case with default header values.
+
+Request to stop accepted
+*/
+type StopStagingAccepted struct {
+}
+
+func (o *StopStagingAccepted) Error() string {
+	return fmt.Sprintf("[DELETE /staging/{staging_guid}][%d] stopStagingAccepted ", 202)
+}
+
+func (o *StopStagingAccepted) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStopStagingNotFound creates a StopStagingNotFound with default headers values
+func NewStopStagingNotFound() *StopStagingNotFound {
+	return &StopStagingNotFound{}
+}
+
+/*
+StopStagingNotFound handles this case with default header values.
+
+Staging task not found
+*/
+type StopStagingNotFound struct {
+}
+
+func (o *StopStagingNotFound) Error() string {
+	return fmt.Sprintf("[DELETE /staging/{staging_guid}][%d] stopStagingNotFound ", 404)
+}
+
+func (o *StopStagingNotFound) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
+// NewStopStagingInternalServerError creates a StopStagingInternalServerError with default headers values
+func NewStopStagingInternalServerError() *StopStagingInternalServerError {
+	return &StopStagingInternalServerError{}
+}
+
+/*
+StopStagingInternalServerError handles this case with default header values.
+
+Internal error
+*/
+type StopStagingInternalServerError struct {
+}
+
+func (o *StopStagingInternalServerError) Error() string {
+	return fmt.Sprintf("[DELETE /staging/{staging_guid}][%d] stopStagingInternalServerError ", 500)
+}
+
+func (o *StopStagingInternalServerError) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
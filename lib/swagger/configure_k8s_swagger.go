@@ -1,60 +1,118 @@
 package swagger
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/audit"
 	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/metrics"
+	"github.com/cf-furnace/k8s-stager/lib/model"
 	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
 
 	"code.cloudfoundry.org/lager"
-	"code.cloudfoundry.org/runtimeschema/cc_messages"
 	"code.cloudfoundry.org/stager/cc_client"
 	errors "github.com/go-openapi/errors"
 	runtime "github.com/go-openapi/runtime"
 	middleware "github.com/go-openapi/runtime/middleware"
+	k8sapi "k8s.io/kubernetes/pkg/api"
 )
 
 const (
 	DockerLifecycleName    = "docker"
 	BuildpackLifecycleName = "buildpack"
+
+	StagingConflictErrorID = "StagingConflict"
 )
 
 var (
-	serverConfig *lib.ServerConfig
-	Lifecycles   = map[string]string{
+	Lifecycles = map[string]string{
 		DockerLifecycleName:    "",
 		BuildpackLifecycleName: "",
 	}
+
+	// dockerCompletionDelay is how long docker stagings take before their
+	// result is delivered to CC.
+	dockerCompletionDelay = 2 * time.Second
 )
 
+// CcClientFactory creates a client delivering staging results to CC with the
+// given credentials.
+type CcClientFactory func(baseURL, username, password string, skipCertVerify bool) cc_client.CcClient
+
+// Dependencies are what the API handlers call besides the configuration.
+// They are injected so that the handlers can be tested against fakes.
+type Dependencies struct {
+	// K8SClient runs the buildpack stagings.
+	K8SClient k8s.K8SStagingClient
+
+	// NewCcClient creates the clients staging results are delivered with,
+	// cc_client.NewCcClient if nil.
+	NewCcClient CcClientFactory
+
+	// DockerStagings keeps the docker stagings in flight. A new one is
+	// created if nil.
+	DockerStagings *DockerStagings
+}
+
+// stagerAPI is the state the API handlers share.
+type stagerAPI struct {
+	config         *lib.ServerConfig
+	k8sClient      k8s.K8SStagingClient
+	createCcClient CcClientFactory
+	dockerStagings *DockerStagings
+}
+
+func newStagerAPI(serverConfig *lib.ServerConfig, dependencies Dependencies) *stagerAPI {
+	s := &stagerAPI{
+		config:         serverConfig,
+		k8sClient:      dependencies.K8SClient,
+		createCcClient: dependencies.NewCcClient,
+		dockerStagings: dependencies.DockerStagings,
+	}
+
+	if s.createCcClient == nil {
+		s.createCcClient = cc_client.NewCcClient
+	}
+	if s.dockerStagings == nil {
+		s.dockerStagings = NewDockerStagings()
+	}
+
+	return s
+}
+
 // ConfigureAPI configures the Stager API server
-func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.ServerConfig) http.Handler {
-	serverConfig = serverConfiguration
+func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfig *lib.ServerConfig, dependencies Dependencies) http.Handler {
+	s := newStagerAPI(serverConfig, dependencies)
 
 	// configure the api here
 	api.ServeError = errors.ServeError
 	api.JSONConsumer = runtime.JSONConsumer()
 	api.JSONProducer = runtime.JSONProducer()
+	api.TxtProducer = runtime.TextProducer()
+	api.EventStreamProducer = runtime.TextProducer()
 
 	// Since we don't have the information from the CC yet, use these values
 	// in lieu of an actual org and space
-	org := "cf-furnace"
+	org := stagingOrganization
 	space := serverConfig.K8SNamespace
 
-	api.StageHandler = operations.StageHandlerFunc(func(params operations.StageParams) middleware.Responder {
-		serverConfig.Logger.Debug("Stage called", lager.Data{
+	api.StageHandler = s.auditStage(instrumentStage(operations.StageHandlerFunc(func(params operations.StageParams) middleware.Responder {
+		logger := s.requestLogger(params.HTTPRequest)
+
+		logger.Debug("Stage called", lager.Data{
 			"StagingGuid":    params.StagingGUID,
 			"StagingRequest": params.StagingRequest,
 		})
 
 		if _, ok := Lifecycles[params.StagingRequest.Lifecycle]; !ok {
-			serverConfig.Logger.Error(
+			logger.Error(
 				"Tried to stage using an unknown lifecycle.",
 				fmt.Errorf("K8S stager cannot stage apps with the specified lifecycle"),
 				lager.Data{
@@ -66,16 +124,52 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 			return &operations.StageBadRequest{}
 		}
 
+		if !validCompletionCallback(params.StagingRequest.CompletionCallback) {
+			logger.Error(
+				"Tried to stage with an invalid completion callback.",
+				fmt.Errorf("completion callback must be an absolute http or https URL"),
+				lager.Data{
+					"StagingId":          params.StagingGUID,
+					"CompletionCallback": audit.StripCredentials(params.StagingRequest.CompletionCallback),
+				},
+			)
+
+			return &operations.StageBadRequest{}
+		}
+
+		fingerprint, err := stagingRequestFingerprint(params.StagingRequest)
+		if err != nil {
+			logger.Error(
+				"Error computing staging request fingerprint.",
+				err,
+				lager.Data{
+					"StagingId": params.StagingGUID,
+				},
+			)
+
+			return &operations.StageInternalServerError{}
+		}
+
+		if params.DryRun != nil && *params.DryRun {
+			return s.dryRunStage(logger, params, space)
+		}
+
 		// Staging a docker app is essentially a no-op since we're now actually
 		// running the docker image. There's no reason to lookup the start
 		// command or do anything ...
 		if params.StagingRequest.Lifecycle == DockerLifecycleName {
+			if existingFingerprint, exists := s.dockerStagings.add(params.StagingGUID, fingerprint); exists {
+				return existingStagingResponse(logger, params.StagingGUID, existingFingerprint, fingerprint)
+			}
+
 			go func() {
-				time.Sleep(2 * time.Second)
+				defer s.dockerStagings.remove(params.StagingGUID)
+
+				time.Sleep(dockerCompletionDelay)
 
 				dockerLifecycleData := &lib.DockerLifecycle{}
 				if lifecyleDataJson, err := json.Marshal(params.StagingRequest.LifecycleData); err != nil {
-					serverConfig.Logger.Error(
+					logger.Error(
 						"Error marshalling lifecycle data.",
 						err,
 						lager.Data{
@@ -86,7 +180,7 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 					)
 				} else {
 					if err = json.Unmarshal(lifecyleDataJson, dockerLifecycleData); err != nil {
-						serverConfig.Logger.Error(
+						logger.Error(
 							"Error marshalling lifecycle data.",
 							err,
 							lager.Data{
@@ -99,15 +193,6 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 					}
 				}
 
-				ccClient := cc_client.NewCcClient(
-					serverConfig.CCBaseURL,
-					serverConfig.CCUsername,
-					serverConfig.CCPassword,
-					serverConfig.SkipCertVerification,
-				)
-
-				var annotation cc_messages.StagingTaskAnnotation
-
 				// Based on this schema:
 				// https://github.com/cloudfoundry/cloud_controller_ng/blob/173954d8ed2d2b9624d074ba2b277f7bd47c8432/lib/cloud_controller/diego/docker/staging_completion_handler.rb#L14-L24
 				dockerCompletionPayload, err := json.Marshal(map[string]interface{}{
@@ -124,22 +209,27 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 				})
 
 				if err != nil {
-					serverConfig.Logger.Error("Error marshalling payload for CC staging complete for docker app", err)
+					logger.Error("Error marshalling payload for CC staging complete for docker app", err)
 				}
 
-				err = ccClient.StagingComplete(
+				err = s.deliverStagingComplete(
+					logger,
+					params.HTTPRequest,
+					DockerLifecycleName,
+					params.StagingRequest.AppID,
 					params.StagingGUID,
-					annotation.CompletionCallback,
-					dockerCompletionPayload,
-					serverConfig.Logger)
+					params.StagingRequest.CompletionCallback,
+					dockerCompletionPayload)
 
 				if err != nil {
-					serverConfig.Logger.Error("Error calling CC staging complete for docker app", err)
+					logger.Error("Error calling CC staging complete for docker app", err)
 				}
 
-				serverConfig.Logger.Info("Called CC staging complete for docker app")
+				logger.Info("Called CC staging complete for docker app")
 			}()
 
+			metrics.StagingAdmissions.WithLabelValues(DockerLifecycleName, "").Inc()
+
 			return &operations.StageAccepted{}
 		}
 
@@ -147,10 +237,27 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 		// since we've already dealt with the Docker one, and there's nothing else
 		// for the moment
 
-		_, namespaceExists, err := serverConfig.K8SClient.GetStagingNamespace(space)
+		job, jobExists, err := s.k8sClient.GetStagingTask(params.StagingGUID, space)
+		if err != nil {
+			logger.Error(
+				"Error looking up existing staging job.",
+				err,
+				lager.Data{
+					"StagingId": params.StagingGUID,
+				},
+			)
+
+			return &operations.StageInternalServerError{}
+		}
+
+		if jobExists {
+			return existingStagingResponse(logger, params.StagingGUID, job.Annotations[k8s.StagingFingerprintAnnotation], fingerprint)
+		}
+
+		_, namespaceExists, err := s.k8sClient.GetStagingNamespace(space)
 
 		if err != nil {
-			serverConfig.Logger.Error(
+			logger.Error(
 				"Error looking up k8s namespace.",
 				err,
 				lager.Data{
@@ -164,17 +271,17 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 		}
 
 		if !namespaceExists {
-			serverConfig.Logger.Info(
+			logger.Info(
 				"Staging namespace does not exist. Creating it.",
 				lager.Data{
 					"StagingId": params.StagingGUID,
 				},
 			)
 
-			err = serverConfig.K8SClient.CreateStagingNamespace(org, space)
+			err = s.k8sClient.CreateStagingNamespace(org, space)
 
 			if err != nil {
-				serverConfig.Logger.Error(
+				logger.Error(
 					"Error creating k8s namespace.",
 					err,
 					lager.Data{
@@ -187,7 +294,7 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 				return &operations.StageInternalServerError{}
 			}
 		} else {
-			serverConfig.Logger.Debug(
+			logger.Debug(
 				"Staging namespace already exists. Not creating again.",
 				lager.Data{
 					"StagingId": params.StagingGUID,
@@ -195,15 +302,13 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 			)
 		}
 
-		env := map[string]string{}
-		for _, envEntry := range params.StagingRequest.Environment {
-			env[envEntry.Name] = envEntry.Value
+		stagingInfo, err := newBuildpackStagingInfo(serverConfig, params.StagingGUID, params.StagingRequest, requestId(params.HTTPRequest), fingerprint)
+		if err == nil {
+			err = issueCompletionClientCertificate(serverConfig, stagingInfo)
 		}
-
-		buildpackLifecycleData := &lib.BuildpackLifecycle{}
-		if lifecyleDataJson, err := json.Marshal(params.StagingRequest.LifecycleData); err != nil {
-			serverConfig.Logger.Error(
-				"Error marshalling lifecycle data.",
+		if err != nil {
+			logger.Error(
+				"Error preparing staging job.",
 				err,
 				lager.Data{
 					"StagingId": params.StagingGUID,
@@ -213,71 +318,26 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 			)
 
 			return &operations.StageInternalServerError{}
-		} else {
-			if err = json.Unmarshal(lifecyleDataJson, buildpackLifecycleData); err != nil {
-				serverConfig.Logger.Error(
-					"Error marshalling lifecycle data.",
-					err,
-					lager.Data{
-						"StagingId":     params.StagingGUID,
-						"Org":           org,
-						"Space":         space,
-						"LifecycleData": string(lifecyleDataJson),
-					},
-				)
-
-				return &operations.StageInternalServerError{}
-			}
 		}
 
-		buildpacks := make([]*k8s.Buildpack, len(buildpackLifecycleData.Buildpacks))
-
-		for idx, buildpack := range buildpackLifecycleData.Buildpacks {
-			buildpacks[idx] = &k8s.Buildpack{
-				Id:          buildpack.Key,
-				DownloadURL: buildpack.URL,
-			}
-		}
-
-		var command []string
-
-		if len(serverConfig.CustomImageCommand) == 0 {
-			command = nil
-		} else {
-			command = strings.Split(serverConfig.CustomImageCommand, " ")
-		}
-
-		stagingInfo := &k8s.StagingInfo{
-			Id:               params.StagingGUID,
-			Image:            serverConfig.StagingImage,
-			Environment:      env,
-			Command:          command,
-			Stack:            buildpackLifecycleData.Stack,
-			Buildpacks:       buildpacks,
-			AppLifecycleURL:  serverConfig.AppLifecycleURL,
-			AppPackageURL:    buildpackLifecycleData.AppBitsDownloadURI,
-			DropletUploadURL: buildpackLifecycleData.DropletUploadURI,
-			SkipCertVerify:   serverConfig.SkipCertVerification,
-			SkipDetection:    false,
-			CompletionCallbackURL: fmt.Sprintf(
-				"http://%s:%d/v1/staging/%s/completed",
-				serverConfig.AdvertiseAddress,
-				serverConfig.Port,
-				params.StagingGUID,
-			),
-		}
-
-		serverConfig.Logger.Info(
+		logger.Info(
 			"Trying to run staging job.",
 			lager.Data{
 				"StagingId": params.StagingGUID,
 			},
 		)
 
-		err = serverConfig.K8SClient.StartStaging(stagingInfo, space)
+		err = s.k8sClient.StartStaging(stagingInfo, space)
+
+		if err == k8s.ErrStagingTaskExists {
+			// Another request for the same staging won the race to create the job
+			if job, jobExists, lookupErr := s.k8sClient.GetStagingTask(params.StagingGUID, space); lookupErr == nil && jobExists {
+				return existingStagingResponse(logger, params.StagingGUID, job.Annotations[k8s.StagingFingerprintAnnotation], fingerprint)
+			}
+		}
 
 		if err != nil {
-			serverConfig.Logger.Error(
+			logger.Error(
 				"Error running staging job.",
 				err,
 				lager.Data{
@@ -290,32 +350,50 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 			return &operations.StageInternalServerError{}
 		}
 
+		metrics.StagingAdmissions.WithLabelValues(BuildpackLifecycleName, stagingInfo.Stack).Inc()
+
+		s.recordStagingEvent(logger, params.StagingGUID, space, k8sapi.EventTypeNormal, k8s.ReasonStagingAccepted,
+			fmt.Sprintf("Accepted %s staging request %s", params.StagingRequest.Lifecycle, requestId(params.HTTPRequest)))
+
 		return &operations.StageAccepted{}
-	})
+	})))
+
+	api.StagingCompleteHandler = s.auditStagingComplete(operations.StagingCompleteHandlerFunc(func(params operations.StagingCompleteParams) middleware.Responder {
+		logger := s.requestLogger(params.HTTPRequest)
 
-	api.StagingCompleteHandler = operations.StagingCompleteHandlerFunc(func(params operations.StagingCompleteParams) middleware.Responder {
-		serverConfig.Logger.Debug("Stage complete called", lager.Data{
+		logger.Debug("Stage complete called", lager.Data{
 			"StagingGuid":            params.StagingGUID,
 			"StagingCompleteRequest": params.StagingCompleteRequest,
 		})
 
-		ccClient := cc_client.NewCcClient(
-			serverConfig.CCBaseURL,
-			serverConfig.CCUsername,
-			serverConfig.CCPassword,
-			serverConfig.SkipCertVerification,
-		)
+		// Callers are authorized for the staging in the path, the result is
+		// delivered for the task in the body
+		if params.StagingCompleteRequest.TaskGUID != params.StagingGUID {
+			logger.Info("Rejected staging result for another staging.", lager.Data{
+				"StagingId": params.StagingGUID,
+				"TaskGuid":  params.StagingCompleteRequest.TaskGUID,
+			})
 
-		var annotation cc_messages.StagingTaskAnnotation
+			return &operations.StagingCompleteBadRequest{}
+		}
 
-		err := ccClient.StagingComplete(
+		annotations := s.stagingAnnotations(params.StagingGUID, params.StagingCompleteRequest.Space)
+
+		err := s.deliverStagingComplete(
+			logger,
+			params.HTTPRequest,
+			BuildpackLifecycleName,
+			annotations[k8s.AppGuidAnnotation],
 			params.StagingCompleteRequest.TaskGUID,
-			annotation.CompletionCallback,
-			[]byte(params.StagingCompleteRequest.Result),
-			serverConfig.Logger)
+			annotations[k8s.CompletionCallbackAnnotation],
+			[]byte(params.StagingCompleteRequest.Result))
 
 		if err != nil {
-			serverConfig.Logger.Error("Error calling CC staging complete", err)
+			logger.Error("Error calling CC staging complete", err)
+
+			s.recordStagingEvent(logger, params.StagingGUID, params.StagingCompleteRequest.Space, k8sapi.EventTypeWarning, k8s.ReasonCallbackDeliveryFailed,
+				fmt.Sprintf("Delivering the staging result to CC failed: %s", err))
+
 			if _, ok := err.(*cc_client.BadResponseError); ok {
 				return &operations.StagingCompleteBadRequest{}
 			} else {
@@ -325,15 +403,25 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 			return &operations.StagingCompleteNotFound{}
 		}
 
-		serverConfig.Logger.Info("Called CC staging complete")
+		logger.Info("Called CC staging complete")
+
+		s.observeStagingDuration(params.StagingGUID, params.StagingCompleteRequest)
 
-		serverConfig.Logger.Info("Removing staging job")
+		if params.StagingCompleteRequest.Failed {
+			s.recordStagingEvent(logger, params.StagingGUID, params.StagingCompleteRequest.Space, k8sapi.EventTypeWarning, k8s.ReasonStagingFailed,
+				fmt.Sprintf("Staging failed: %s", params.StagingCompleteRequest.FailureReason))
+		} else {
+			s.recordStagingEvent(logger, params.StagingGUID, params.StagingCompleteRequest.Space, k8sapi.EventTypeNormal, k8s.ReasonStagingCompleted,
+				"Staging completed and the result was delivered to CC")
+		}
+
+		logger.Info("Removing staging job")
 
 		// Delete the job from Kubernetes
-		err = serverConfig.K8SClient.StopStaging(params.StagingGUID, params.StagingCompleteRequest.Space, serverConfig.StagingStopGracePeriodSeconds)
+		err = s.k8sClient.StopStaging(params.StagingGUID, params.StagingCompleteRequest.Space, serverConfig.Staging().StagingStopGracePeriodSeconds)
 
 		if err != nil {
-			serverConfig.Logger.Error(
+			logger.Error(
 				"Error deleting the staging job.",
 				err,
 				lager.Data{
@@ -345,16 +433,18 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 		}
 
 		return &operations.StagingCompleteOK{}
-	})
+	}))
+
+	api.StopStagingHandler = s.auditStopStaging(operations.StopStagingHandlerFunc(func(params operations.StopStagingParams) middleware.Responder {
+		logger := s.requestLogger(params.HTTPRequest)
 
-	api.StopStagingHandler = operations.StopStagingHandlerFunc(func(params operations.StopStagingParams) middleware.Responder {
-		serverConfig.Logger.Debug("Stage stop called", lager.Data{
+		logger.Debug("Stage stop called", lager.Data{
 			"StagingGuid": params.StagingGUID,
 		})
 
-		_, exists, err := serverConfig.K8SClient.GetStagingTask(params.StagingGUID, space)
+		_, exists, err := s.k8sClient.GetStagingTask(params.StagingGUID, space)
 		if err != nil {
-			serverConfig.Logger.Error(
+			logger.Error(
 				"Error looking up staging task to stop.",
 				err,
 				lager.Data{
@@ -367,21 +457,24 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 
 		if exists {
 
-			serverConfig.Logger.Info(
+			logger.Info(
 				"Trying to stop staging job.",
 				lager.Data{
 					"StagingId": params.StagingGUID,
 				},
 			)
 
-			err = serverConfig.K8SClient.StopStaging(
+			s.recordStagingEvent(logger, params.StagingGUID, space, k8sapi.EventTypeNormal, k8s.ReasonStagingCancelled,
+				fmt.Sprintf("Staging cancelled by request %s", requestId(params.HTTPRequest)))
+
+			err = s.k8sClient.StopStaging(
 				params.StagingGUID,
 				space,
-				serverConfig.StagingStopGracePeriodSeconds,
+				serverConfig.Staging().StagingStopGracePeriodSeconds,
 			)
 
 			if err != nil {
-				serverConfig.Logger.Error(
+				logger.Error(
 					"Error stopping staging task.",
 					err,
 					lager.Data{
@@ -396,13 +489,148 @@ func ConfigureAPI(api *operations.K8sSwaggerAPI, serverConfiguration *lib.Server
 		}
 
 		return &operations.StopStagingNotFound{}
+	}))
+
+	api.StagingLogsHandler = operations.StagingLogsHandlerFunc(func(params operations.StagingLogsParams) middleware.Responder {
+		logger := s.requestLogger(params.HTTPRequest)
+
+		logger.Debug("Staging logs called", lager.Data{
+			"StagingGuid": params.StagingGUID,
+			"Follow":      params.Follow,
+			"Since":       params.Since,
+			"Tail":        params.Tail,
+		})
+
+		if (params.Since != nil && *params.Since <= 0) || (params.Tail != nil && *params.Tail < 0) {
+			return &operations.StagingLogsBadRequest{}
+		}
+
+		options := &k8s.LogOptions{
+			Follow:       params.Follow != nil && *params.Follow,
+			SinceSeconds: params.Since,
+			TailLines:    params.Tail,
+		}
+
+		logs, err := s.k8sClient.StreamStagingLogs(params.StagingGUID, space, options)
+
+		switch err {
+		case nil:
+			return s.stagingLogsResponder(logger, params.StagingGUID, space, options, logs)
+		case k8s.ErrStagingPodPending:
+			if options.Follow {
+				return s.stagingLogsResponder(logger, params.StagingGUID, space, options, nil)
+			}
+
+			return &operations.StagingLogsServiceUnavailable{}
+		case k8s.ErrStagingTaskNotFound:
+			return &operations.StagingLogsNotFound{}
+		case k8s.ErrStagingPodGone:
+			return &operations.StagingLogsGone{}
+		default:
+			logger.Error(
+				"Error opening staging logs.",
+				err,
+				lager.Data{
+					"StagingId": params.StagingGUID,
+				},
+			)
+
+			return &operations.StagingLogsInternalServerError{}
+		}
 	})
 
 	api.ServerShutdown = func() {
 		serverConfig.Logger.Info("Server is shutting down.", lager.Data{})
 	}
 
-	return setupGlobalMiddleware(api.Serve(setupMiddlewares))
+	return setupGlobalMiddleware(serverConfig.Logger, api.Serve(func(handler http.Handler) http.Handler {
+		return s.authorizeOperations(api.Context(), setupMiddlewares(handler))
+	}))
+}
+
+// recordStagingEvent records an event on a staging job. Failing to do so
+// doesn't fail the staging.
+func (s *stagerAPI) recordStagingEvent(logger lager.Logger, stagingGuid, space, eventType, reason, message string) {
+	err := s.k8sClient.RecordStagingEvent(stagingGuid, space, eventType, reason, message)
+	if err != nil {
+		logger.Error(
+			"Error recording staging event.",
+			err,
+			lager.Data{
+				"StagingId": stagingGuid,
+				"Reason":    reason,
+			},
+		)
+	}
+}
+
+// observeStagingDuration records how long a buildpack staging took, using
+// the creation time of its job as the time the request was accepted.
+func (s *stagerAPI) observeStagingDuration(stagingGuid string, result *model.TaskCallbackResponse) {
+	job, exists, err := s.k8sClient.GetStagingTask(stagingGuid, result.Space)
+	if err != nil || !exists {
+		return
+	}
+
+	outcome := "succeeded"
+	if result.Failed {
+		outcome = "failed"
+	}
+
+	metrics.StagingDuration.WithLabelValues(
+		BuildpackLifecycleName,
+		job.Annotations[k8s.StackAnnotation],
+		outcome,
+	).Observe(time.Since(job.CreationTimestamp.Time).Seconds())
+}
+
+// stagingRequestFingerprint identifies a staging request by its lifecycle and
+// lifecycle data, which is what CC resends when it retries a request.
+func stagingRequestFingerprint(request *model.StagingRequestFromCC) (string, error) {
+	// encoding/json sorts map keys, so equal lifecycle data always yields
+	// the same document
+	fingerprintJson, err := json.Marshal(map[string]interface{}{
+		"lifecycle":      request.Lifecycle,
+		"lifecycle_data": request.LifecycleData,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(fingerprintJson)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// existingStagingResponse answers a stage request for a staging guid that is
+// already known: a retry of the same request is accepted again without side
+// effects, anything else is a conflict.
+func existingStagingResponse(logger lager.Logger, stagingGuid, existingFingerprint, fingerprint string) middleware.Responder {
+	if existingFingerprint == fingerprint {
+		logger.Info(
+			"Staging already in progress for identical request. Not starting again.",
+			lager.Data{
+				"StagingId": stagingGuid,
+			},
+		)
+
+		return &operations.StageAccepted{}
+	}
+
+	logger.Info(
+		"Staging already in progress for a different request.",
+		lager.Data{
+			"StagingId":           stagingGuid,
+			"ExistingFingerprint": existingFingerprint,
+			"Fingerprint":         fingerprint,
+		},
+	)
+
+	return operations.NewStageConflict().WithPayload(&model.StagingResponseFromCC{
+		Error: &model.StagingError{
+			ID:      StagingConflictErrorID,
+			Message: fmt.Sprintf("staging %s is already in progress with different lifecycle data", stagingGuid),
+		},
+	})
 }
 
 // The TLS configuration before HTTPS server starts.
@@ -418,6 +646,6 @@ func setupMiddlewares(handler http.Handler) http.Handler {
 
 // The middleware configuration happens before anything, this middleware also applies to serving the swagger.json document.
 // So this is a good place to plug in a panic handling middleware, logging and metrics
-func setupGlobalMiddleware(handler http.Handler) http.Handler {
-	return handler
+func setupGlobalMiddleware(logger lager.Logger, handler http.Handler) http.Handler {
+	return requestMiddleware(logger, handler)
 }
@@ -0,0 +1,574 @@
+package swagger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/auth"
+	"github.com/cf-furnace/k8s-stager/lib/client"
+	"github.com/cf-furnace/k8s-stager/lib/fakes"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	apiclient "github.com/cf-furnace/k8s-stager/lib/swagger/client"
+	clientops "github.com/cf-furnace/k8s-stager/lib/swagger/client/operations"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/stager/cc_client"
+	"github.com/go-openapi/loads"
+	"github.com/stretchr/testify/assert"
+	k8sapi "k8s.io/kubernetes/pkg/api"
+)
+
+const (
+	testSpace       = "furnace-staging"
+	testStagingGuid = "9a8b7c6d-1234-5678-9abc-def012345678-0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+
+	buildpackStagingRequest = `{
+		"app_id": "app-guid",
+		"log_guid": "log-guid",
+		"lifecycle": "buildpack",
+		"lifecycle_data": {
+			"app_bits_download_uri": "https://blobstore.example.com/app",
+			"droplet_upload_uri": "https://blobstore.example.com/droplet",
+			"buildpacks": [{"key": "ruby", "url": "https://blobstore.example.com/ruby"}],
+			"stack": "cflinuxfs2"
+		},
+		"environment": [{"name": "VCAP_APPLICATION", "value": "{}"}],
+		"completion_callback": "https://cc.example.com/internal/v3/staging/guid/build_completed"
+	}`
+
+	dockerStagingRequest = `{
+		"app_id": "app-guid",
+		"lifecycle": "docker",
+		"lifecycle_data": {"docker_image": "cloudfoundry/diego-docker-app"}
+	}`
+)
+
+// apiTest serves the API against fake Kubernetes and CC clients, and calls
+// it with the generated client.
+type apiTest struct {
+	server   *httptest.Server
+	client   *apiclient.K8sSwagger
+	stagings *fakes.StagingClient
+	cc       *fakes.CcClient
+}
+
+// principalAuthenticator authenticates every request as the same principal.
+type principalAuthenticator auth.Principal
+
+func (a principalAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	principal := auth.Principal(a)
+	return &principal, nil
+}
+
+func newAPITest(t *testing.T, cc *fakes.CcClient, authenticators ...auth.Authenticator) *apiTest {
+	spec, err := loads.Analyzed(SwaggerJSON, "")
+	assert.NoError(t, err)
+
+	stagings := fakes.NewStagingClient("stager-0")
+	config := &lib.ServerConfig{
+		Logger:           lager.NewLogger("test"),
+		StagerId:         "stager-0",
+		K8SNamespace:     testSpace,
+		StagingImage:     "cffurnace/stager",
+		AppLifecycleURL:  "https://blobstore.example.com/buildpack_app_lifecycle.tgz",
+		AdvertiseAddress: "10.0.0.1",
+		Port:             8080,
+		CCBaseURL:        "https://cc.example.com",
+		Authenticators:   authenticators,
+	}
+
+	handler := ConfigureAPI(operations.NewK8sSwaggerAPI(spec), config, Dependencies{
+		K8SClient: stagings,
+		NewCcClient: func(baseURL, username, password string, skipCertVerify bool) cc_client.CcClient {
+			return cc
+		},
+	})
+
+	server := httptest.NewServer(handler)
+	stager, err := client.New(client.Config{URL: server.URL})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return &apiTest{server: server, client: stager, stagings: stagings, cc: cc}
+}
+
+func (a *apiTest) close() {
+	a.server.Close()
+}
+
+// stagingRequest decodes a staging request the way CC sends it.
+func stagingRequest(t *testing.T, body string) *model.StagingRequestFromCC {
+	request := &model.StagingRequestFromCC{}
+	if err := json.Unmarshal([]byte(body), request); err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+func (a *apiTest) stage(t *testing.T, body string) error {
+	_, _, err := a.client.Operations.Stage(clientops.NewStageParams().
+		WithStagingGUID(testStagingGuid).
+		WithStagingRequest(stagingRequest(t, body)))
+	return err
+}
+
+func (a *apiTest) completeStaging(taskGuid string, failureReason string) error {
+	result := &model.TaskCallbackResponse{
+		TaskGUID:      taskGuid,
+		Space:         testSpace,
+		Result:        `{"droplet": true}`,
+		Failed:        failureReason != "",
+		FailureReason: failureReason,
+	}
+
+	_, err := a.client.Operations.StagingComplete(clientops.NewStagingCompleteParams().
+		WithStagingGUID(testStagingGuid).
+		WithStagingCompleteRequest(result))
+	return err
+}
+
+func (a *apiTest) stopStaging() error {
+	_, err := a.client.Operations.StopStaging(clientops.NewStopStagingParams().WithStagingGUID(testStagingGuid))
+	return err
+}
+
+func (a *apiTest) stagingLogs(since, tail *int64) (*clientops.StagingLogsOK, error) {
+	return a.client.Operations.StagingLogs(clientops.NewStagingLogsParams().
+		WithStagingGUID(testStagingGuid).
+		WithSince(since).
+		WithTail(tail))
+}
+
+func TestStageCreatesNamespaceAndJob(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+
+	// Act
+	_, accepted, err := api.client.Operations.Stage(clientops.NewStageParams().
+		WithStagingGUID(testStagingGuid).
+		WithStagingRequest(stagingRequest(t, buildpackStagingRequest)))
+
+	// Assert
+	assert.NoError(err)
+	assert.NotNil(accepted)
+	assert.Equal([]string{testSpace}, api.stagings.Namespaces())
+
+	job := api.stagings.Job(testStagingGuid, testSpace)
+	if assert.NotNil(job) {
+		assert.Equal("app-guid", job.Annotations[k8s.AppGuidAnnotation])
+		assert.Equal("cffurnace/stager", job.Spec.Template.Spec.Containers[0].Image)
+	}
+
+	events := api.stagings.Events()
+	if assert.Len(events, 1) {
+		assert.Equal(k8s.ReasonStagingAccepted, events[0].Reason)
+	}
+}
+
+func TestStageAcceptsRetryOfSameRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+	api.stagings.StartStagingErr = errors.New("must not start twice")
+
+	// Act
+	err := api.stage(t, buildpackStagingRequest)
+
+	// Assert
+	assert.NoError(err)
+}
+
+func TestStageRejectsDifferentRequestForSameStaging(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.stage(t, strings.Replace(buildpackStagingRequest, "cflinuxfs2", "cflinuxfs3", 1))
+
+	// Assert
+	if assert.IsType(&clientops.StageConflict{}, err) {
+		assert.Equal(StagingConflictErrorID, err.(*clientops.StageConflict).Payload.Error.ID)
+	}
+}
+
+func TestStageRejectsUnknownLifecycle(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+
+	// Act
+	err := api.stage(t, `{"lifecycle": "windows"}`)
+
+	// Assert
+	assert.IsType(&clientops.StageBadRequest{}, err)
+	assert.Empty(api.stagings.Namespaces())
+}
+
+func TestStageRejectsInvalidCompletionCallback(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+
+	// Act
+	err := api.stage(t, strings.Replace(buildpackStagingRequest, "https://cc.example.com/internal", "/internal", 1))
+
+	// Assert
+	assert.IsType(&clientops.StageBadRequest{}, err)
+}
+
+func TestStageFailsWhenKubernetesFails(t *testing.T) {
+	failures := map[string]func(*fakes.StagingClient){
+		"looking up the job":       func(c *fakes.StagingClient) { c.GetStagingTaskErr = errors.New("timeout") },
+		"looking up the namespace": func(c *fakes.StagingClient) { c.GetStagingNamespaceErr = errors.New("timeout") },
+		"creating the namespace":   func(c *fakes.StagingClient) { c.CreateStagingNamespaceErr = errors.New("forbidden") },
+		"starting the staging job": func(c *fakes.StagingClient) { c.StartStagingErr = errors.New("quota exceeded") },
+	}
+
+	for name, fail := range failures {
+		// Arrange
+		assert := assert.New(t)
+		api := newAPITest(t, fakes.NewCcClient())
+		fail(api.stagings)
+
+		// Act
+		err := api.stage(t, buildpackStagingRequest)
+
+		// Assert
+		assert.IsType(&clientops.StageInternalServerError{}, err, name)
+		api.close()
+	}
+}
+
+func TestStageDryRunCreatesNothing(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	dryRun := true
+
+	// Act
+	rendered, _, err := api.client.Operations.Stage(clientops.NewStageParams().
+		WithStagingGUID(testStagingGuid).
+		WithDryRun(&dryRun).
+		WithStagingRequest(stagingRequest(t, buildpackStagingRequest)))
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(api.stagings.Namespaces())
+	assert.Nil(api.stagings.Job(testStagingGuid, testSpace))
+
+	if !assert.NotNil(rendered) {
+		return
+	}
+	var list struct {
+		Kind  string
+		Items []struct{ Kind string }
+	}
+	encoded, _ := json.Marshal(rendered.Payload)
+	assert.NoError(json.Unmarshal(encoded, &list))
+	assert.Equal("List", list.Kind)
+	if assert.Len(list.Items, 2) {
+		assert.Equal("Namespace", list.Items[0].Kind)
+		assert.Equal("Job", list.Items[1].Kind)
+	}
+}
+
+func TestStageDeliversDockerResultToCC(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dockerCompletionDelay = 0
+	defer func() { dockerCompletionDelay = 2 * time.Second }()
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+
+	// Act
+	err := api.stage(t, dockerStagingRequest)
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(api.stagings.Namespaces())
+
+	select {
+	case <-api.cc.Delivered():
+	case <-time.After(5 * time.Second):
+		t.Fatal("docker staging result was not delivered")
+	}
+
+	deliveries := api.cc.Deliveries()
+	if assert.Len(deliveries, 1) {
+		assert.Equal(testStagingGuid, deliveries[0].StagingGuid)
+		assert.Contains(string(deliveries[0].Payload), "cloudfoundry/diego-docker-app")
+	}
+}
+
+func TestStagingCompleteDeliversResultAndRemovesJob(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.completeStaging(testStagingGuid, "")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{testStagingGuid}, api.stagings.Stopped())
+
+	deliveries := api.cc.Deliveries()
+	if assert.Len(deliveries, 1) {
+		assert.Equal("https://cc.example.com/internal/v3/staging/guid/build_completed", deliveries[0].CompletionCallback)
+		assert.Equal(`{"droplet": true}`, string(deliveries[0].Payload))
+	}
+
+	events := api.stagings.Events()
+	if assert.Len(events, 2) {
+		assert.Equal(k8s.ReasonStagingCompleted, events[1].Reason)
+	}
+}
+
+func TestStagingCompleteOnlyAcceptsPodOfSameStaging(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	otherPod := newAPITest(t, fakes.NewCcClient(), principalAuthenticator{
+		Name:        "other-staging-guid",
+		Roles:       []auth.Role{auth.RoleStaging},
+		StagingGuid: "other-staging-guid",
+	})
+	defer otherPod.close()
+	ownPod := newAPITest(t, fakes.NewCcClient(), principalAuthenticator{
+		Name:        testStagingGuid,
+		Roles:       []auth.Role{auth.RoleStaging},
+		StagingGuid: testStagingGuid,
+	})
+	defer ownPod.close()
+
+	// Act
+	otherErr := otherPod.completeStaging(testStagingGuid, "")
+	ownErr := ownPod.completeStaging(testStagingGuid, "")
+
+	// Assert
+	if assert.IsType(&clientops.StagingCompleteDefault{}, otherErr) {
+		assert.Equal(http.StatusForbidden, otherErr.(*clientops.StagingCompleteDefault).Code())
+	}
+	assert.Empty(otherPod.cc.Deliveries())
+	assert.NoError(ownErr)
+	assert.Len(ownPod.cc.Deliveries(), 1)
+}
+
+func TestStagingCompleteRejectsResultOfAnotherTask(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.completeStaging("other-staging-guid", "")
+
+	// Assert
+	assert.IsType(&clientops.StagingCompleteBadRequest{}, err)
+	assert.Empty(api.cc.Deliveries())
+	assert.Empty(api.stagings.Stopped())
+}
+
+func TestStagingCompleteRecordsFailedStaging(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.completeStaging(testStagingGuid, "no buildpack")
+
+	// Assert
+	assert.NoError(err)
+
+	events := api.stagings.Events()
+	if assert.Len(events, 2) {
+		assert.Equal(k8sapi.EventTypeWarning, events[1].Type)
+		assert.Equal(k8s.ReasonStagingFailed, events[1].Reason)
+	}
+}
+
+func TestStagingCompleteReportsRejectedResult(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient(&cc_client.BadResponseError{StatusCode: http.StatusUnprocessableEntity}))
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.completeStaging(testStagingGuid, "")
+
+	// Assert
+	assert.IsType(&clientops.StagingCompleteBadRequest{}, err)
+	assert.Empty(api.stagings.Stopped())
+
+	events := api.stagings.Events()
+	if assert.Len(events, 2) {
+		assert.Equal(k8s.ReasonCallbackDeliveryFailed, events[1].Reason)
+	}
+}
+
+func TestStagingCompleteReportsUnreachableCC(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient(errors.New("connection refused")))
+	defer api.close()
+
+	// Act
+	err := api.completeStaging(testStagingGuid, "")
+
+	// Assert
+	assert.IsType(&clientops.StagingCompleteServiceUnavailable{}, err)
+}
+
+func TestStagingCompleteReportsJobRemovalFailure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+	api.stagings.StopStagingErr = errors.New("timeout")
+
+	// Act
+	err := api.completeStaging(testStagingGuid, "")
+
+	// Assert
+	assert.IsType(&clientops.StagingCompleteServiceUnavailable{}, err)
+	assert.Len(api.cc.Deliveries(), 1)
+}
+
+func TestStopStagingStopsRunningStaging(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+
+	// Act
+	err := api.stopStaging()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{testStagingGuid}, api.stagings.Stopped())
+	assert.Nil(api.stagings.Job(testStagingGuid, testSpace))
+
+	events := api.stagings.Events()
+	if assert.Len(events, 2) {
+		assert.Equal(k8s.ReasonStagingCancelled, events[1].Reason)
+	}
+}
+
+func TestStopStagingReportsUnknownStaging(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+
+	// Act
+	err := api.stopStaging()
+
+	// Assert
+	assert.IsType(&clientops.StopStagingNotFound{}, err)
+	assert.Empty(api.stagings.Stopped())
+}
+
+func TestStopStagingFailsWhenKubernetesFails(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	lookupFails := newAPITest(t, fakes.NewCcClient())
+	defer lookupFails.close()
+	lookupFails.stagings.GetStagingTaskErr = errors.New("timeout")
+	stopFails := newAPITest(t, fakes.NewCcClient())
+	defer stopFails.close()
+	stopFails.stage(t, buildpackStagingRequest)
+	stopFails.stagings.StopStagingErr = errors.New("timeout")
+
+	// Act
+	lookupErr := lookupFails.stopStaging()
+	stopErr := stopFails.stopStaging()
+
+	// Assert
+	assert.IsType(&clientops.StopStagingInternalServerError{}, lookupErr)
+	assert.IsType(&clientops.StopStagingInternalServerError{}, stopErr)
+}
+
+func TestStagingLogsStreamsLogs(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	api.stage(t, buildpackStagingRequest)
+	api.stagings.SetLogs(testStagingGuid, "Downloading buildpacks\nStaging complete\n")
+
+	// Act
+	logs, err := api.stagingLogs(nil, nil)
+
+	// Assert
+	assert.NoError(err)
+	if assert.NotNil(logs) {
+		assert.Equal("Downloading buildpacks\nStaging complete\n", logs.Payload)
+	}
+}
+
+func TestStagingLogsReportsUnavailableLogs(t *testing.T) {
+	responses := map[error]error{
+		k8s.ErrStagingTaskNotFound: &clientops.StagingLogsNotFound{},
+		k8s.ErrStagingPodPending:   &clientops.StagingLogsServiceUnavailable{},
+		k8s.ErrStagingPodGone:      &clientops.StagingLogsGone{},
+		errors.New("timeout"):      &clientops.StagingLogsInternalServerError{},
+	}
+
+	for err, response := range responses {
+		// Arrange
+		assert := assert.New(t)
+		api := newAPITest(t, fakes.NewCcClient())
+		api.stagings.StreamStagingLogsErr = err
+
+		// Act
+		_, logsErr := api.stagingLogs(nil, nil)
+
+		// Assert
+		assert.IsType(response, logsErr, err.Error())
+		api.close()
+	}
+}
+
+func TestStagingLogsRejectsInvalidOptions(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	api := newAPITest(t, fakes.NewCcClient())
+	defer api.close()
+	zero, negative := int64(0), int64(-1)
+
+	// Act
+	_, sinceErr := api.stagingLogs(&zero, nil)
+	_, tailErr := api.stagingLogs(nil, &negative)
+
+	// Assert
+	assert.IsType(&clientops.StagingLogsBadRequest{}, sinceErr)
+	assert.IsType(&clientops.StagingLogsBadRequest{}, tailErr)
+}
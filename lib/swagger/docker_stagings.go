@@ -0,0 +1,46 @@
+package swagger
+
+import "sync"
+
+// DockerStagings are the docker stagings waiting for their result to be
+// delivered to CC. Docker stagings don't create a job, so their fingerprints
+// are kept here instead of in job annotations.
+type DockerStagings struct {
+	lock         sync.Mutex
+	fingerprints map[string]string
+}
+
+// NewDockerStagings creates an empty set of docker stagings.
+func NewDockerStagings() *DockerStagings {
+	return &DockerStagings{fingerprints: map[string]string{}}
+}
+
+// InFlight returns the number of docker stagings waiting for their result
+// to be delivered to CC.
+func (d *DockerStagings) InFlight() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.fingerprints)
+}
+
+// add records a staging unless one with the same guid is in flight, whose
+// fingerprint is returned then.
+func (d *DockerStagings) add(stagingGuid, fingerprint string) (string, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if existingFingerprint, exists := d.fingerprints[stagingGuid]; exists {
+		return existingFingerprint, true
+	}
+
+	d.fingerprints[stagingGuid] = fingerprint
+	return "", false
+}
+
+func (d *DockerStagings) remove(stagingGuid string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.fingerprints, stagingGuid)
+}
@@ -0,0 +1,58 @@
+package swagger
+
+import (
+	"testing"
+
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFingerprintedRequest(stack string) *model.StagingRequestFromCC {
+	return &model.StagingRequestFromCC{
+		AppID:     "app-guid",
+		Lifecycle: BuildpackLifecycleName,
+		LifecycleData: map[string]interface{}{
+			"app_bits_download_uri": "https://blobstore.example.com/app",
+			"stack":                 stack,
+		},
+	}
+}
+
+func TestStagingRequestFingerprintIgnoresRetriedFields(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	retried := newFingerprintedRequest("cflinuxfs2")
+	retried.AppID = "other-app-guid"
+	retried.MemoryMb = 2048
+
+	// Act
+	fingerprint, err := stagingRequestFingerprint(newFingerprintedRequest("cflinuxfs2"))
+	retriedFingerprint, retriedErr := stagingRequestFingerprint(retried)
+	otherFingerprint, otherErr := stagingRequestFingerprint(newFingerprintedRequest("cflinuxfs3"))
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(retriedErr)
+	assert.NoError(otherErr)
+	assert.Equal(fingerprint, retriedFingerprint)
+	assert.NotEqual(fingerprint, otherFingerprint)
+}
+
+func TestExistingStagingResponseAcceptsRetryAndRejectsConflict(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logger := lager.NewLogger("test")
+
+	// Act
+	retry := existingStagingResponse(logger, "staging-guid", "fingerprint", "fingerprint")
+	conflict := existingStagingResponse(logger, "staging-guid", "fingerprint", "other-fingerprint")
+
+	// Assert
+	assert.IsType(&operations.StageAccepted{}, retry)
+	if assert.IsType(&operations.StageConflict{}, conflict) {
+		assert.Equal(StagingConflictErrorID, conflict.(*operations.StageConflict).Payload.Error.ID)
+	}
+}
@@ -0,0 +1,117 @@
+package swagger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+
+	"code.cloudfoundry.org/lager"
+	runtime "github.com/go-openapi/runtime"
+	middleware "github.com/go-openapi/runtime/middleware"
+)
+
+const (
+	eventStreamMime = "text/event-stream"
+
+	stagingPodPollInterval = time.Second
+)
+
+// stagingLogsResponder streams the logs of a staging pod to the client,
+// either as chunked plain text or as server-sent events, depending on the
+// negotiated content type. If logs is nil, the staging pod hasn't started yet
+// and the responder waits for it before streaming.
+func (s *stagerAPI) stagingLogsResponder(logger lager.Logger, stagingGuid, space string, options *k8s.LogOptions, logs io.ReadCloser) middleware.Responder {
+	return middleware.ResponderFunc(func(rw http.ResponseWriter, producer runtime.Producer) {
+		logger = logger.Session("staging-logs", lager.Data{"StagingId": stagingGuid})
+
+		eventStream := strings.HasPrefix(rw.Header().Get(runtime.HeaderContentType), eventStreamMime)
+		if eventStream {
+			rw.Header().Set("Cache-Control", "no-cache")
+		} else {
+			rw.Header().Set(runtime.HeaderContentType, "text/plain; charset=utf-8")
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		flush(rw)
+
+		var clientGone <-chan bool
+		if notifier, ok := rw.(http.CloseNotifier); ok {
+			clientGone = notifier.CloseNotify()
+		}
+
+		if logs == nil {
+			var err error
+			logs, err = s.waitForStagingLogs(stagingGuid, space, options, clientGone)
+			if err != nil {
+				logger.Info("Stopped waiting for staging pod.", lager.Data{"Reason": err.Error()})
+				if eventStream {
+					fmt.Fprintf(rw, "event: error\ndata: %s\n\n", err.Error())
+				}
+				return
+			}
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		defer logs.Close()
+
+		// Closing the stream unblocks the reader below when the client leaves
+		go func() {
+			select {
+			case <-clientGone:
+				logs.Close()
+			case <-done:
+			}
+		}()
+
+		reader := bufio.NewReader(logs)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 {
+				if eventStream {
+					_, err = fmt.Fprintf(rw, "data: %s\n\n", strings.TrimRight(line, "\r\n"))
+				} else {
+					_, err = io.WriteString(rw, line)
+				}
+				flush(rw)
+			}
+
+			if err != nil {
+				if err != io.EOF {
+					logger.Debug("Staging log stream ended.", lager.Data{"Reason": err.Error()})
+				}
+				return
+			}
+		}
+	})
+}
+
+// waitForStagingLogs polls until the staging pod has started and its logs
+// can be opened, the staging is gone, or the client disconnects.
+func (s *stagerAPI) waitForStagingLogs(stagingGuid, space string, options *k8s.LogOptions, clientGone <-chan bool) (io.ReadCloser, error) {
+	ticker := time.NewTicker(stagingPodPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-clientGone:
+			return nil, fmt.Errorf("client disconnected")
+		case <-ticker.C:
+			logs, err := s.k8sClient.StreamStagingLogs(stagingGuid, space, options)
+			if err != k8s.ErrStagingPodPending {
+				return logs, err
+			}
+		}
+	}
+}
+
+func flush(rw http.ResponseWriter) {
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
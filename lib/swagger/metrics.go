@@ -0,0 +1,122 @@
+package swagger
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib/metrics"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/stager/cc_client"
+	middleware "github.com/go-openapi/runtime/middleware"
+)
+
+// instrumentStage counts staging requests by the response they got.
+func instrumentStage(handler operations.StageHandler) operations.StageHandler {
+	return operations.StageHandlerFunc(func(params operations.StageParams) middleware.Responder {
+		responder := handler.Handle(params)
+
+		var outcome string
+		switch responder.(type) {
+		case *operations.StageAccepted:
+			outcome = "accepted"
+		case *operations.StageOK:
+			outcome = "dry_run"
+		case *operations.StageConflict:
+			outcome = "conflict"
+		case *operations.StageBadRequest:
+			outcome = "bad_request"
+		default:
+			outcome = "error"
+		}
+
+		metrics.StagingRequests.WithLabelValues(
+			params.StagingRequest.Lifecycle,
+			lifecycleStack(params.StagingRequest),
+			outcome,
+		).Inc()
+
+		return responder
+	})
+}
+
+// lifecycleStack returns the stack a staging request is for, if its
+// lifecycle has one.
+func lifecycleStack(request *model.StagingRequestFromCC) string {
+	lifecycleData, ok := request.LifecycleData.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	stack, _ := lifecycleData["stack"].(string)
+	return stack
+}
+
+// newCcClient returns a client with the current CC credentials, the ones
+// that were last rotated in. The credentials are only sent to completion
+// callbacks on the CC host.
+func (s *stagerAPI) newCcClient(logger lager.Logger, lifecycle, completionCallback string) cc_client.CcClient {
+	username, password, err := s.config.CCCredentials()
+	if err != nil {
+		logger.Error("Error reading rotated CC credentials, using the previous ones.", err)
+	}
+
+	if completionCallback != "" && !sameHost(completionCallback, s.config.CCBaseURL) {
+		username, password = "", ""
+	}
+
+	return metrics.InstrumentCcClient(
+		s.createCcClient(
+			s.config.CCBaseURL,
+			username,
+			password,
+			s.config.SkipCertVerification,
+		),
+		lifecycle,
+	)
+}
+
+// validCompletionCallback tells whether a completion callback CC sent is
+// usable, if it sent one at all.
+func validCompletionCallback(completionCallback string) bool {
+	if completionCallback == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(completionCallback)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func sameHost(first, second string) bool {
+	firstURL, err := url.Parse(first)
+	if err != nil {
+		return false
+	}
+
+	secondURL, err := url.Parse(second)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(firstURL.Host, secondURL.Host)
+}
+
+// deliverStagingComplete sends a staging result to CC, or to the completion
+// callback CC asked for. The delivery is recorded in the audit log.
+func (s *stagerAPI) deliverStagingComplete(logger lager.Logger, request *http.Request, lifecycle, appGuid, stagingGuid, completionCallback string, payload []byte) error {
+	ccClient := s.newCcClient(logger, lifecycle, completionCallback)
+	target := s.config.CCBaseURL
+	if completionCallback != "" {
+		target = completionCallback
+	}
+
+	start := time.Now()
+	err := ccClient.StagingComplete(stagingGuid, completionCallback, payload, logger)
+	s.auditCCCallback(logger, request, lifecycle, appGuid, stagingGuid, target, start, err)
+
+	return err
+}
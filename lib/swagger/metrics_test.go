@@ -0,0 +1,66 @@
+package swagger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliverStagingCompleteKeepsCredentialsFromOtherHosts(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	var username string
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _, _ = r.BasicAuth()
+	}))
+	defer callback.Close()
+
+	s := newStagerAPI(&lib.ServerConfig{
+		Logger:     lager.NewLogger("test"),
+		CCBaseURL:  "https://cc.example.com",
+		CCUsername: "internal_user",
+		CCPassword: "internal_password",
+	}, Dependencies{})
+	request := httptest.NewRequest("POST", "/v1/staging/guid/completed", nil)
+
+	// Act
+	err := s.deliverStagingComplete(s.config.Logger, request, BuildpackLifecycleName, "app-guid", "guid", callback.URL+"/completed", []byte("{}"))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("", username)
+}
+
+func TestDeliverStagingCompleteAuthenticatesToCC(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	var path, username string
+	cc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		username, _, _ = r.BasicAuth()
+	}))
+	defer cc.Close()
+
+	s := newStagerAPI(&lib.ServerConfig{
+		Logger:     lager.NewLogger("test"),
+		CCBaseURL:  cc.URL,
+		CCUsername: "internal_user",
+		CCPassword: "internal_password",
+	}, Dependencies{})
+	request := httptest.NewRequest("POST", "/v1/staging/guid/completed", nil)
+
+	// Act
+	err := s.deliverStagingComplete(s.config.Logger, request, BuildpackLifecycleName, "app-guid", "guid", "", []byte("{}"))
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("/internal/staging/guid/completed", path)
+	assert.Equal("internal_user", username)
+}
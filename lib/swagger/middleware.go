@@ -0,0 +1,146 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	uuid "github.com/nu7hatch/gouuid"
+)
+
+const (
+	// VcapRequestIdHeader is the request id header set by CC and the
+	// gorouter. It is preferred over RequestIdHeader and always returned.
+	VcapRequestIdHeader = "X-Vcap-Request-Id"
+	RequestIdHeader     = "X-Request-Id"
+)
+
+type contextKey int
+
+const (
+	requestIdKey contextKey = iota
+	requestLoggerKey
+	principalKey
+)
+
+// requestId returns the id of a request that went through the global
+// middleware.
+func requestId(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	id, _ := r.Context().Value(requestIdKey).(string)
+	return id
+}
+
+// requestLogger returns the logger session of a request, which adds the
+// request id to everything it logs.
+func (s *stagerAPI) requestLogger(r *http.Request) lager.Logger {
+	if r != nil {
+		if logger, ok := r.Context().Value(requestLoggerKey).(lager.Logger); ok {
+			return logger
+		}
+	}
+
+	return s.config.Logger
+}
+
+// requestMiddleware tags every request with an id, logs it once it has been
+// served and turns panics into logged 500 responses.
+func requestMiddleware(serverLogger lager.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(VcapRequestIdHeader)
+		if id == "" {
+			id = r.Header.Get(RequestIdHeader)
+		}
+		if id == "" {
+			if generated, err := uuid.NewV4(); err == nil {
+				id = generated.String()
+			}
+		}
+
+		logger := serverLogger.Session("request", lager.Data{"RequestId": id})
+
+		ctx := context.WithValue(r.Context(), requestIdKey, id)
+		ctx = context.WithValue(ctx, requestLoggerKey, logger)
+		r = r.WithContext(ctx)
+
+		w.Header().Set(VcapRequestIdHeader, id)
+		// A handler that writes nothing sends an empty 200 response
+		writer := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.Error(
+					"Recovered from panic while serving request.",
+					fmt.Errorf("%v", recovered),
+					lager.Data{
+						"Method": r.Method,
+						"Path":   r.URL.Path,
+						"Stack":  string(debug.Stack()),
+					},
+				)
+
+				// Too late to change the response once it has started
+				if !writer.wroteHeader {
+					writer.WriteHeader(http.StatusInternalServerError)
+				}
+			}
+
+			logger.Info("Served request.", lager.Data{
+				"Method":  r.Method,
+				"Path":    r.URL.Path,
+				"Status":  writer.status,
+				"Latency": time.Since(start).String(),
+			})
+		}()
+
+		handler.ServeHTTP(writer, r)
+	})
+}
+
+// statusResponseWriter remembers the status of a response. It keeps the
+// flushing and close notification the log streams rely on.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+
+	return make(chan bool)
+}
@@ -0,0 +1,80 @@
+package swagger
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMiddlewarePropagatesRequestId(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	var handlerRequestId string
+	handler := requestMiddleware(lager.NewLogger("test"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerRequestId = requestId(r)
+	}))
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.Header.Set(RequestIdHeader, "request-id")
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal("request-id", handlerRequestId)
+	assert.Equal("request-id", recorder.Header().Get(VcapRequestIdHeader))
+}
+
+func TestRequestMiddlewareGeneratesRequestId(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	handler := requestMiddleware(lager.NewLogger("test"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/staging/guid/logs", nil))
+
+	// Assert
+	assert.NotEmpty(recorder.Header().Get(VcapRequestIdHeader))
+}
+
+func TestRequestMiddlewareTurnsPanicsIntoServerErrors(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	handler := requestMiddleware(lager.NewLogger("test"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/v1/staging/guid", nil))
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, recorder.Code)
+}
+
+func TestRequestMiddlewareLogsStatusOfEmptyResponse(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	logs := &bytes.Buffer{}
+	logger := lager.NewLogger("test")
+	logger.RegisterSink(lager.NewWriterSink(logs, lager.INFO))
+	handler := requestMiddleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/staging/guid/logs", nil))
+
+	// Assert
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Contains(logs.String(), `"Status":200`)
+}
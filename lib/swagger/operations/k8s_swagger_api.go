@@ -43,11 +43,17 @@ type K8sSwaggerAPI struct {
 
 	// JSONProducer registers a producer for a "application/json" mime type
 	JSONProducer runtime.Producer
+	// TxtProducer registers a producer for a "text/plain" mime type
+	TxtProducer runtime.Producer
+	// EventStreamProducer registers a producer for a "text/event-stream" mime type
+	EventStreamProducer runtime.Producer
 
 	// StageHandler sets the operation handler for the stage operation
 	StageHandler StageHandler
 	// StagingCompleteHandler sets the operation handler for the staging complete operation
 	StagingCompleteHandler StagingCompleteHandler
+	// StagingLogsHandler sets the operation handler for the staging logs operation
+	StagingLogsHandler StagingLogsHandler
 	// StopStagingHandler sets the operation handler for the stop staging operation
 	StopStagingHandler StopStagingHandler
 
@@ -108,6 +114,14 @@ func (o *K8sSwaggerAPI) Validate() error {
 		unregistered = append(unregistered, "JSONProducer")
 	}
 
+	if o.TxtProducer == nil {
+		unregistered = append(unregistered, "TxtProducer")
+	}
+
+	if o.EventStreamProducer == nil {
+		unregistered = append(unregistered, "EventStreamProducer")
+	}
+
 	if o.StageHandler == nil {
 		unregistered = append(unregistered, "StageHandler")
 	}
@@ -116,6 +130,10 @@ func (o *K8sSwaggerAPI) Validate() error {
 		unregistered = append(unregistered, "StagingCompleteHandler")
 	}
 
+	if o.StagingLogsHandler == nil {
+		unregistered = append(unregistered, "StagingLogsHandler")
+	}
+
 	if o.StopStagingHandler == nil {
 		unregistered = append(unregistered, "StopStagingHandler")
 	}
@@ -165,6 +183,12 @@ func (o *K8sSwaggerAPI) ProducersFor(mediaTypes []string) map[string]runtime.Pro
 		case "application/json":
 			result["application/json"] = o.JSONProducer
 
+		case "text/event-stream":
+			result["text/event-stream"] = o.EventStreamProducer
+
+		case "text/plain":
+			result["text/plain"] = o.TxtProducer
+
 		}
 	}
 	return result
@@ -203,6 +227,11 @@ func (o *K8sSwaggerAPI) initHandlerCache() {
 	}
 	o.handlers["POST"]["/staging/{staging_guid}/completed"] = NewStagingComplete(o.context, o.StagingCompleteHandler)
 
+	if o.handlers["GET"] == nil {
+		o.handlers[strings.ToUpper("GET")] = make(map[string]http.Handler)
+	}
+	o.handlers["GET"]["/staging/{staging_guid}/logs"] = NewStagingLogs(o.context, o.StagingLogsHandler)
+
 	if o.handlers["DELETE"] == nil {
 		o.handlers[strings.ToUpper("DELETE")] = make(map[string]http.Handler)
 	}
@@ -210,6 +239,15 @@ func (o *K8sSwaggerAPI) initHandlerCache() {
 
 }
 
+// Context returns the middleware context for the k8s swagger API
+func (o *K8sSwaggerAPI) Context() *middleware.Context {
+	if o.context == nil {
+		o.context = middleware.NewRoutableContext(o.spec, o, nil)
+	}
+
+	return o.context
+}
+
 // Serve creates a http handler to serve the API over HTTP
 // can be used directly in http.ListenAndServe(":8000", api.Serve(nil))
 func (o *K8sSwaggerAPI) Serve(builder middleware.Builder) http.Handler {
@@ -10,6 +10,7 @@ import (
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/runtime"
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/swag"
 
 	strfmt "github.com/go-openapi/strfmt"
 
@@ -32,6 +33,10 @@ type StageParams struct {
 	// HTTP Request Object
 	HTTPRequest *http.Request
 
+	/*Render the objects the staging would create instead of creating them
+	  In: query
+	*/
+	DryRun *bool
 	/*
 	  Required: true
 	  In: path
@@ -50,6 +55,13 @@ func (o *StageParams) BindRequest(r *http.Request, route *middleware.MatchedRout
 	var res []error
 	o.HTTPRequest = r
 
+	qs := runtime.Values(r.URL.Query())
+
+	qDryRun, qhkDryRun, _ := qs.GetOK("dry_run")
+	if err := o.bindDryRun(qDryRun, qhkDryRun, route.Formats); err != nil {
+		res = append(res, err)
+	}
+
 	rStagingGUID, rhkStagingGUID, _ := route.Params.GetOK("staging_guid")
 	if err := o.bindStagingGUID(rStagingGUID, rhkStagingGUID, route.Formats); err != nil {
 		res = append(res, err)
@@ -85,6 +97,24 @@ func (o *StageParams) BindRequest(r *http.Request, route *middleware.MatchedRout
 	return nil
 }
 
+func (o *StageParams) bindDryRun(rawData []string, hasKey bool, formats strfmt.Registry) error {
+	var raw string
+	if len(rawData) > 0 {
+		raw = rawData[len(rawData)-1]
+	}
+	if raw == "" { // empty values pass all other validations
+		return nil
+	}
+
+	value, err := swag.ConvertBool(raw)
+	if err != nil {
+		return errors.InvalidType("dry_run", "query", "bool", raw)
+	}
+	o.DryRun = &value
+
+	return nil
+}
+
 func (o *StageParams) bindStagingGUID(rawData []string, hasKey bool, formats strfmt.Registry) error {
 	var raw string
 	if len(rawData) > 0 {
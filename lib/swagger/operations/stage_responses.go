@@ -11,6 +11,42 @@ import (
 	"github.com/cf-furnace/k8s-stager/lib/model"
 )
 
+/*StageOK Objects the staging would create, as a Kubernetes List
+
+swagger:response stageOK
+*/
+type StageOK struct {
+
+	// In: body
+	Payload interface{} `json:"body,omitempty"`
+}
+
+// NewStageOK creates StageOK with default headers values
+func NewStageOK() *StageOK {
+	return &StageOK{}
+}
+
+// WithPayload adds the payload to the stage o k response
+func (o *StageOK) WithPayload(payload interface{}) *StageOK {
+	o.Payload = payload
+	return o
+}
+
+// SetPayload sets the payload to the stage o k response
+func (o *StageOK) SetPayload(payload interface{}) {
+	o.Payload = payload
+}
+
+// WriteResponse to the client
+func (o *StageOK) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(200)
+	if err := producer.Produce(rw, o.Payload); err != nil {
+		panic(err) // let the recovery middleware deal with this
+	}
+
+}
+
 /*StageAccepted Staging request accepted
 
 swagger:response stageAccepted
@@ -65,6 +101,43 @@ func (o *StageNotFound) WriteResponse(rw http.ResponseWriter, producer runtime.P
 	rw.WriteHeader(404)
 }
 
+/*StageConflict Staging request conflicts with an existing staging
+
+swagger:response stageConflict
+*/
+type StageConflict struct {
+
+	// In: body
+	Payload *model.StagingResponseFromCC `json:"body,omitempty"`
+}
+
+// NewStageConflict creates StageConflict with default headers values
+func NewStageConflict() *StageConflict {
+	return &StageConflict{}
+}
+
+// WithPayload adds the payload to the stage conflict response
+func (o *StageConflict) WithPayload(payload *model.StagingResponseFromCC) *StageConflict {
+	o.Payload = payload
+	return o
+}
+
+// SetPayload sets the payload to the stage conflict response
+func (o *StageConflict) SetPayload(payload *model.StagingResponseFromCC) {
+	o.Payload = payload
+}
+
+// WriteResponse to the client
+func (o *StageConflict) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(409)
+	if o.Payload != nil {
+		if err := producer.Produce(rw, o.Payload); err != nil {
+			panic(err) // let the recovery middleware deal with this
+		}
+	}
+}
+
 /*StageInternalServerError Staging error
 
 swagger:response stageInternalServerError
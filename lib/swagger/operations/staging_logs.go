@@ -0,0 +1,53 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the generate command
+
+import (
+	"net/http"
+
+	middleware "github.com/go-openapi/runtime/middleware"
+)
+
+// StagingLogsHandlerFunc turns a function with the right signature into a staging logs handler
+type StagingLogsHandlerFunc func(StagingLogsParams) middleware.Responder
+
+// Handle executing the request and returning a response
+func (fn StagingLogsHandlerFunc) Handle(params StagingLogsParams) middleware.Responder {
+	return fn(params)
+}
+
+// StagingLogsHandler interface for that can handle valid staging logs params
+type StagingLogsHandler interface {
+	Handle(StagingLogsParams) middleware.Responder
+}
+
+// NewStagingLogs creates a new http.Handler for the staging logs operation
+func NewStagingLogs(ctx *middleware.Context, handler StagingLogsHandler) *StagingLogs {
+	return &StagingLogs{Context: ctx, Handler: handler}
+}
+
+/*StagingLogs swagger:route GET /staging/{staging_guid}/logs stagingLogs
+
+StagingLogs staging logs API
+
+*/
+type StagingLogs struct {
+	Context *middleware.Context
+	Handler StagingLogsHandler
+}
+
+func (o *StagingLogs) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	route, _ := o.Context.RouteInfo(r)
+	var Params = NewStagingLogsParams()
+
+	if err := o.Context.BindValidRequest(r, route, &Params); err != nil { // bind params
+		o.Context.Respond(rw, r, route.Produces, route, err)
+		return
+	}
+
+	res := o.Handler.Handle(Params) // actually handle the request
+
+	o.Context.Respond(rw, r, route.Produces, route, res)
+
+}
@@ -0,0 +1,149 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"net/http"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/swag"
+
+	strfmt "github.com/go-openapi/strfmt"
+)
+
+// NewStagingLogsParams creates a new StagingLogsParams object
+// with the default values initialized.
+func NewStagingLogsParams() StagingLogsParams {
+	var ()
+	return StagingLogsParams{}
+}
+
+// StagingLogsParams contains all the bound params for the staging logs operation
+// typically these are obtained from a http.Request
+//
+// swagger:parameters stagingLogs
+type StagingLogsParams struct {
+
+	// HTTP Request Object
+	HTTPRequest *http.Request
+
+	/*
+	  In: query
+	*/
+	Follow *bool
+	/*Only return logs newer than this many seconds
+	  In: query
+	*/
+	Since *int64
+	/*
+	  Required: true
+	  In: path
+	*/
+	StagingGUID string
+	/*Number of lines from the end of the logs to return
+	  In: query
+	*/
+	Tail *int64
+}
+
+// BindRequest both binds and validates a request, it assumes that complex things implement a Validatable(strfmt.Registry) error interface
+// for simple values it will use straight method calls
+func (o *StagingLogsParams) BindRequest(r *http.Request, route *middleware.MatchedRoute) error {
+	var res []error
+	o.HTTPRequest = r
+
+	qs := runtime.Values(r.URL.Query())
+
+	qFollow, qhkFollow, _ := qs.GetOK("follow")
+	if err := o.bindFollow(qFollow, qhkFollow, route.Formats); err != nil {
+		res = append(res, err)
+	}
+
+	qSince, qhkSince, _ := qs.GetOK("since")
+	if err := o.bindSince(qSince, qhkSince, route.Formats); err != nil {
+		res = append(res, err)
+	}
+
+	rStagingGUID, rhkStagingGUID, _ := route.Params.GetOK("staging_guid")
+	if err := o.bindStagingGUID(rStagingGUID, rhkStagingGUID, route.Formats); err != nil {
+		res = append(res, err)
+	}
+
+	qTail, qhkTail, _ := qs.GetOK("tail")
+	if err := o.bindTail(qTail, qhkTail, route.Formats); err != nil {
+		res = append(res, err)
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
+
+func (o *StagingLogsParams) bindFollow(rawData []string, hasKey bool, formats strfmt.Registry) error {
+	var raw string
+	if len(rawData) > 0 {
+		raw = rawData[len(rawData)-1]
+	}
+	if raw == "" { // empty values pass all other validations
+		return nil
+	}
+
+	value, err := swag.ConvertBool(raw)
+	if err != nil {
+		return errors.InvalidType("follow", "query", "bool", raw)
+	}
+	o.Follow = &value
+
+	return nil
+}
+
+func (o *StagingLogsParams) bindSince(rawData []string, hasKey bool, formats strfmt.Registry) error {
+	var raw string
+	if len(rawData) > 0 {
+		raw = rawData[len(rawData)-1]
+	}
+	if raw == "" { // empty values pass all other validations
+		return nil
+	}
+
+	value, err := swag.ConvertInt64(raw)
+	if err != nil {
+		return errors.InvalidType("since", "query", "int64", raw)
+	}
+	o.Since = &value
+
+	return nil
+}
+
+func (o *StagingLogsParams) bindStagingGUID(rawData []string, hasKey bool, formats strfmt.Registry) error {
+	var raw string
+	if len(rawData) > 0 {
+		raw = rawData[len(rawData)-1]
+	}
+
+	o.StagingGUID = raw
+
+	return nil
+}
+
+func (o *StagingLogsParams) bindTail(rawData []string, hasKey bool, formats strfmt.Registry) error {
+	var raw string
+	if len(rawData) > 0 {
+		raw = rawData[len(rawData)-1]
+	}
+	if raw == "" { // empty values pass all other validations
+		return nil
+	}
+
+	value, err := swag.ConvertInt64(raw)
+	if err != nil {
+		return errors.InvalidType("tail", "query", "int64", raw)
+	}
+	o.Tail = &value
+
+	return nil
+}
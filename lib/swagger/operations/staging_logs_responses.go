@@ -0,0 +1,136 @@
+package operations
+
+// This file was generated by the swagger tool.
+// Editing this file might prove futile when you re-run the swagger generate command
+
+import (
+	"net/http"
+
+	"github.com/go-openapi/runtime"
+)
+
+/*StagingLogsOK Staging logs
+
+swagger:response stagingLogsOK
+*/
+type StagingLogsOK struct {
+
+	// In: body
+	Payload string `json:"body,omitempty"`
+}
+
+// NewStagingLogsOK creates StagingLogsOK with default headers values
+func NewStagingLogsOK() *StagingLogsOK {
+	return &StagingLogsOK{}
+}
+
+// WithPayload adds the payload to the staging logs o k response
+func (o *StagingLogsOK) WithPayload(payload string) *StagingLogsOK {
+	o.Payload = payload
+	return o
+}
+
+// SetPayload sets the payload to the staging logs o k response
+func (o *StagingLogsOK) SetPayload(payload string) {
+	o.Payload = payload
+}
+
+// WriteResponse to the client
+func (o *StagingLogsOK) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(200)
+	if err := producer.Produce(rw, o.Payload); err != nil {
+		panic(err) // let the recovery middleware deal with this
+	}
+
+}
+
+/*StagingLogsBadRequest Invalid log options
+
+swagger:response stagingLogsBadRequest
+*/
+type StagingLogsBadRequest struct {
+}
+
+// NewStagingLogsBadRequest creates StagingLogsBadRequest with default headers values
+func NewStagingLogsBadRequest() *StagingLogsBadRequest {
+	return &StagingLogsBadRequest{}
+}
+
+// WriteResponse to the client
+func (o *StagingLogsBadRequest) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(400)
+}
+
+/*StagingLogsNotFound Staging task not found
+
+swagger:response stagingLogsNotFound
+*/
+type StagingLogsNotFound struct {
+}
+
+// NewStagingLogsNotFound creates StagingLogsNotFound with default headers values
+func NewStagingLogsNotFound() *StagingLogsNotFound {
+	return &StagingLogsNotFound{}
+}
+
+// WriteResponse to the client
+func (o *StagingLogsNotFound) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(404)
+}
+
+/*StagingLogsGone Staging pod is gone
+
+swagger:response stagingLogsGone
+*/
+type StagingLogsGone struct {
+}
+
+// NewStagingLogsGone creates StagingLogsGone with default headers values
+func NewStagingLogsGone() *StagingLogsGone {
+	return &StagingLogsGone{}
+}
+
+// WriteResponse to the client
+func (o *StagingLogsGone) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(410)
+}
+
+/*StagingLogsInternalServerError Internal error
+
+swagger:response stagingLogsInternalServerError
+*/
+type StagingLogsInternalServerError struct {
+}
+
+// NewStagingLogsInternalServerError creates StagingLogsInternalServerError with default headers values
+func NewStagingLogsInternalServerError() *StagingLogsInternalServerError {
+	return &StagingLogsInternalServerError{}
+}
+
+// WriteResponse to the client
+func (o *StagingLogsInternalServerError) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(500)
+}
+
+/*StagingLogsServiceUnavailable Staging pod not started yet
+
+swagger:response stagingLogsServiceUnavailable
+*/
+type StagingLogsServiceUnavailable struct {
+}
+
+// NewStagingLogsServiceUnavailable creates StagingLogsServiceUnavailable with default headers values
+func NewStagingLogsServiceUnavailable() *StagingLogsServiceUnavailable {
+	return &StagingLogsServiceUnavailable{}
+}
+
+// WriteResponse to the client
+func (o *StagingLogsServiceUnavailable) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.WriteHeader(503)
+}
@@ -0,0 +1,238 @@
+package swagger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+	"github.com/cf-furnace/k8s-stager/lib/swagger/operations"
+	"github.com/cf-furnace/k8s-stager/lib/tlsconfig"
+
+	"code.cloudfoundry.org/lager"
+	middleware "github.com/go-openapi/runtime/middleware"
+	k8sruntime "k8s.io/kubernetes/pkg/runtime"
+)
+
+// stagingOrganization is used in lieu of the org of a staging, CC doesn't
+// send it yet.
+const stagingOrganization = "cf-furnace"
+
+// stagingClientCertificateValidity is how long the client certificate of a
+// staging pod is valid, longer than a staging takes.
+const stagingClientCertificateValidity = 24 * time.Hour
+
+// placeholderCredential stands in for the client certificate and key of a
+// rendered staging, which are only issued when it is staged.
+const placeholderCredential = "ISSUED WHEN STAGED"
+
+// RenderStaging returns the objects a staging request creates in Kubernetes
+// with a configuration, without creating them: the staging namespace, the
+// secret with the completion credentials if there are any, and the job.
+// Docker stagings don't create any. No client certificate is issued, the
+// secret holds placeholders instead.
+func RenderStaging(c *lib.ServerConfig, stagingGuid string, request *model.StagingRequestFromCC, requestId string) ([]k8sruntime.Object, error) {
+	if _, ok := Lifecycles[request.Lifecycle]; !ok {
+		return nil, fmt.Errorf("unknown lifecycle %q", request.Lifecycle)
+	}
+
+	if request.Lifecycle == DockerLifecycleName {
+		return []k8sruntime.Object{}, nil
+	}
+
+	fingerprint, err := stagingRequestFingerprint(request)
+	if err != nil {
+		return nil, err
+	}
+
+	stagingInfo, err := newBuildpackStagingInfo(c, stagingGuid, request, requestId, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.StagingClientCACertFile != "" {
+		stagingInfo.CompletionClientCert = placeholderCredential
+		stagingInfo.CompletionClientKey = placeholderCredential
+	}
+
+	secret, err := k8s.NewStagingSecret(stagingInfo, c.K8SNamespace, c.StagerId)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := k8s.NewStagingJob(stagingInfo, c.K8SNamespace, c.StagerId)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []k8sruntime.Object{k8s.NewStagingNamespace(stagingOrganization, c.K8SNamespace, c.StagerId)}
+	if secret != nil {
+		objects = append(objects, secret)
+	}
+
+	return append(objects, job), nil
+}
+
+// dryRunStage answers a stage request with the objects it would create, as
+// a Kubernetes List. The staging namespace is only included if it doesn't
+// exist yet. The objects aren't sent to Kubernetes for a server-side dry
+// run, which the supported versions don't have, so they aren't validated.
+func (s *stagerAPI) dryRunStage(logger lager.Logger, params operations.StageParams, space string) middleware.Responder {
+	objects, err := RenderStaging(s.config, params.StagingGUID, params.StagingRequest, requestId(params.HTTPRequest))
+	if err != nil {
+		logger.Error(
+			"Error rendering staging objects.",
+			err,
+			lager.Data{
+				"StagingId": params.StagingGUID,
+			},
+		)
+
+		return &operations.StageInternalServerError{}
+	}
+
+	if len(objects) > 0 {
+		_, namespaceExists, err := s.k8sClient.GetStagingNamespace(space)
+		if err != nil {
+			logger.Error(
+				"Error looking up k8s namespace.",
+				err,
+				lager.Data{
+					"StagingId": params.StagingGUID,
+					"Space":     space,
+				},
+			)
+
+			return &operations.StageInternalServerError{}
+		}
+
+		if namespaceExists {
+			objects = objects[1:]
+		}
+	}
+
+	rendered, err := k8s.RenderStagingObjects(objects, k8s.RenderJSON)
+	if err != nil {
+		logger.Error(
+			"Error encoding staging objects.",
+			err,
+			lager.Data{
+				"StagingId": params.StagingGUID,
+			},
+		)
+
+		return &operations.StageInternalServerError{}
+	}
+
+	return &operations.StageOK{Payload: json.RawMessage(rendered)}
+}
+
+// newBuildpackStagingInfo describes the staging job of a buildpack staging
+// request, with the staging settings as they are now. The completion client
+// certificate is left to issueCompletionClientCertificate.
+func newBuildpackStagingInfo(c *lib.ServerConfig, stagingGuid string, request *model.StagingRequestFromCC, requestId, fingerprint string) (*k8s.StagingInfo, error) {
+	env := map[string]string{}
+	for _, envEntry := range request.Environment {
+		env[envEntry.Name] = envEntry.Value
+	}
+
+	buildpackLifecycleData := &lib.BuildpackLifecycle{}
+	lifecyleDataJson, err := json.Marshal(request.LifecycleData)
+	if err == nil {
+		err = json.Unmarshal(lifecyleDataJson, buildpackLifecycleData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading lifecycle data: %s", err)
+	}
+
+	buildpacks := make([]*k8s.Buildpack, len(buildpackLifecycleData.Buildpacks))
+
+	for idx, buildpack := range buildpackLifecycleData.Buildpacks {
+		buildpacks[idx] = &k8s.Buildpack{
+			Id:          buildpack.Key,
+			DownloadURL: buildpack.URL,
+		}
+	}
+
+	// Settings can be reloaded at any time, the staging uses them as they
+	// are now
+	settings := c.Staging()
+
+	var command []string
+
+	if len(settings.CustomImageCommand) == 0 {
+		command = nil
+	} else {
+		command = strings.Split(settings.CustomImageCommand, " ")
+	}
+
+	scheme := "http"
+	if c.TLSCertFile != "" {
+		scheme = "https"
+	}
+
+	var serverCA []byte
+	if c.StagingServerCAFile != "" {
+		if serverCA, err = ioutil.ReadFile(c.StagingServerCAFile); err != nil {
+			return nil, fmt.Errorf("reading staging server CA: %s", err)
+		}
+	}
+
+	return &k8s.StagingInfo{
+		Id:               stagingGuid,
+		Image:            settings.StagingImage,
+		Environment:      env,
+		Command:          command,
+		Stack:            buildpackLifecycleData.Stack,
+		Buildpacks:       buildpacks,
+		AppLifecycleURL:  settings.AppLifecycleURL,
+		AppPackageURL:    buildpackLifecycleData.AppBitsDownloadURI,
+		DropletUploadURL: buildpackLifecycleData.DropletUploadURI,
+		SkipCertVerify:   c.SkipCertVerification,
+		SkipDetection:    false,
+		CompletionCallbackURL: fmt.Sprintf(
+			"%s://%s:%d/v1/staging/%s/completed",
+			scheme,
+			c.AdvertiseAddress,
+			c.Port,
+			stagingGuid,
+		),
+		Fingerprint: fingerprint,
+		LogGuid:     request.LogGUID,
+		RequestId:   requestId,
+		AppGuid:     request.AppID,
+
+		CCCompletionCallback: request.CompletionCallback,
+
+		CompletionServerCA: string(serverCA),
+	}, nil
+}
+
+// issueCompletionClientCertificate issues the client certificate a staging
+// pod reports its result with, if staging pods get one. The certificate
+// names the staging, so that it can't be used to report the result of
+// another one.
+func issueCompletionClientCertificate(c *lib.ServerConfig, stagingInfo *k8s.StagingInfo) error {
+	if c.StagingClientCACertFile == "" {
+		return nil
+	}
+
+	clientCert, clientKey, err := tlsconfig.IssueClientCertificate(
+		c.StagingClientCACertFile,
+		c.StagingClientCAKeyFile,
+		stagingInfo.Id,
+		stagingClientCertificateValidity,
+	)
+	if err != nil {
+		return fmt.Errorf("issuing staging client certificate: %s", err)
+	}
+
+	stagingInfo.CompletionClientCert = string(clientCert)
+	stagingInfo.CompletionClientKey = string(clientKey)
+
+	return nil
+}
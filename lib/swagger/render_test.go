@@ -0,0 +1,95 @@
+package swagger
+
+import (
+	"testing"
+
+	"github.com/cf-furnace/k8s-stager/lib"
+	"github.com/cf-furnace/k8s-stager/lib/k8s"
+	"github.com/cf-furnace/k8s-stager/lib/model"
+
+	"github.com/stretchr/testify/assert"
+	k8sapi "k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+)
+
+func TestRenderStagingRendersNamespaceAndJob(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	c := &lib.ServerConfig{
+		StagerId:         "stager-0",
+		K8SNamespace:     "furnace-staging",
+		StagingImage:     "cffurnace/stager",
+		AppLifecycleURL:  "https://blobstore.example.com/buildpack_app_lifecycle.tgz",
+		AdvertiseAddress: "10.0.0.1",
+		Port:             8080,
+	}
+	request := &model.StagingRequestFromCC{
+		AppID:     "app-guid",
+		Lifecycle: BuildpackLifecycleName,
+		LifecycleData: map[string]interface{}{
+			"app_bits_download_uri": "https://cc.example.com/app-bits",
+			"droplet_upload_uri":    "https://cc.example.com/droplet",
+			"stack":                 "cflinuxfs2",
+		},
+	}
+
+	// Act
+	objects, err := RenderStaging(c, "app-guid-staging-guid", request, "request-id")
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(objects, 2)
+	assert.Equal("cf-staging-furnace-staging", objects[0].(*k8sapi.Namespace).Name)
+
+	job := objects[1].(*batch.Job)
+	assert.Equal("cflinuxfs2", job.Annotations["cloudfoundry.org/stack"])
+	assert.Equal("request-id", job.Annotations["cloudfoundry.org/request-id"])
+
+	env := map[string]string{}
+	for _, envVar := range job.Spec.Template.Spec.Containers[0].Env {
+		env[envVar.Name] = envVar.Value
+	}
+	assert.Equal("https://cc.example.com/app-bits", env["CF_APP_PACKAGE"])
+	assert.Equal("http://10.0.0.1:8080/v1/staging/app-guid-staging-guid/completed", env["CF_COMPLETION_CALLBACK_URL"])
+}
+
+func TestRenderStagingRendersNothingForDocker(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	request := &model.StagingRequestFromCC{
+		Lifecycle:     DockerLifecycleName,
+		LifecycleData: map[string]interface{}{"docker_image": "busybox"},
+	}
+
+	// Act
+	objects, err := RenderStaging(&lib.ServerConfig{}, "guid", request, "")
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(objects)
+}
+
+func TestRenderStagingDoesNotIssueClientCertificate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	c := &lib.ServerConfig{
+		K8SNamespace: "furnace-staging",
+		// Issuing a certificate would fail, the CA doesn't exist
+		StagingClientCACertFile: "/nonexistent/ca.crt",
+		StagingClientCAKeyFile:  "/nonexistent/ca.key",
+	}
+	request := &model.StagingRequestFromCC{
+		Lifecycle:     BuildpackLifecycleName,
+		LifecycleData: map[string]interface{}{"stack": "cflinuxfs2"},
+	}
+
+	// Act
+	objects, err := RenderStaging(c, "app-guid-staging-guid", request, "")
+
+	// Assert
+	if assert.NoError(err) && assert.Len(objects, 3) {
+		secret := objects[1].(*k8sapi.Secret)
+		assert.Equal(placeholderCredential, string(secret.Data[k8s.CompletionClientCertKey]))
+		assert.Equal(placeholderCredential, string(secret.Data[k8s.CompletionClientKeyKey]))
+	}
+}
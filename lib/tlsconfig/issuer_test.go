@@ -0,0 +1,88 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCA(t *testing.T, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "staging-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "ca-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func TestIssueClientCertificateSignsWithCA(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	writeCA(t, dir)
+
+	// Act
+	certPEM, keyPEM, err := IssueClientCertificate(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"), "staging-guid", time.Hour)
+
+	// Assert
+	assert.NoError(err)
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if !assert.NoError(err) {
+		return
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	assert.NoError(err)
+	assert.Equal("staging-guid", leaf.Subject.CommonName)
+
+	roots, err := LoadCertPool(filepath.Join(dir, "ca.pem"))
+	assert.NoError(err)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.NoError(err)
+}
+
+func TestIssueClientCertificateFailsWithoutCA(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	_, _, err := IssueClientCertificate("/nonexistent/ca.pem", "/nonexistent/ca-key.pem", "staging-guid", time.Hour)
+
+	// Assert
+	assert.Error(err)
+}
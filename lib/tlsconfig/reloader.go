@@ -0,0 +1,171 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Reloader serves a certificate, key and optional client CA bundle from disk
+// and picks up changes to them without restarting the server.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	pollInterval time.Duration
+	logger       lager.Logger
+
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    map[string]time.Time
+}
+
+func NewReloader(certFile, keyFile, clientCAFile string, pollInterval time.Duration, logger lager.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		pollInterval: pollInterval,
+		logger:       logger.Session("tls-reloader"),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that always uses the most
+// recently loaded files. Client certificates are verified against the client
+// CA bundle if one was given, but not required; see RequireClientCertificate.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+			}
+
+			if r.clientCAs != nil {
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				config.ClientCAs = r.clientCAs
+			}
+
+			return config, nil
+		},
+	}
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.certificate, nil
+}
+
+// Run reloads the files whenever one of them changes. A change that can't be
+// loaded is logged and the previous files stay in use.
+func (r *Reloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.load(); err != nil {
+				r.logger.Error("Error reloading TLS files.", err)
+			} else {
+				r.logger.Info("Reloaded TLS files.")
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+
+	return files
+}
+
+func (r *Reloader) changed() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		if clientCAs, err = LoadCertPool(r.clientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+
+	return nil
+}
+
+// RequireClientCertificate rejects requests that didn't present a client
+// certificate signed by the client CA.
+func RequireClientCertificate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,148 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCertificate(t *testing.T, dir, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+}
+
+func servedCommonName(t *testing.T, reloader *Reloader) string {
+	certificate, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestReloaderPicksUpChangedCertificate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	writeCertificate(t, dir, "first", time.Now().Add(-time.Minute))
+	reloader, err := NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "", time.Hour, lager.NewLogger("test"))
+	assert.NoError(err)
+
+	// Act
+	writeCertificate(t, dir, "second", time.Now())
+	changed := reloader.changed()
+	err = reloader.load()
+
+	// Assert
+	assert.True(changed)
+	assert.NoError(err)
+	assert.Equal("second", servedCommonName(t, reloader))
+}
+
+func TestReloaderRejectsInvalidClientCA(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	writeCertificate(t, dir, "server", time.Now())
+	ioutil.WriteFile(filepath.Join(dir, "ca.pem"), []byte("not a certificate"), 0600)
+
+	// Act
+	_, err = NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem"), time.Hour, lager.NewLogger("test"))
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestRequireClientCertificateRejectsUnverifiedRequests(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	handler := RequireClientCertificate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := httptest.NewRequest("PUT", "/v1/staging/guid", nil)
+	request.TLS = &tls.ConnectionState{}
+	recorder := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(http.StatusForbidden, recorder.Code)
+}
+
+func TestReloaderTLSConfigServesCertificate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	writeCertificate(t, dir, "server", time.Now())
+	reloader, err := NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "", time.Hour, lager.NewLogger("test"))
+	assert.NoError(err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	// Act
+	response, err := client.Get(server.URL)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("server", response.TLS.PeerCertificates[0].Subject.CommonName)
+}